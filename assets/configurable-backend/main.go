@@ -0,0 +1,417 @@
+// configurable-backend is a test app whose failures can be programmed at
+// runtime through an admin API, for testing retries and failure handling.
+//
+// Requests are grouped by key, the first segment of the request path ("/"
+// uses the key "default"). Each key has its own attempt counter and its own
+// sequence of programmed steps. Every request to a key consumes the next
+// step; once the sequence is exhausted requests succeed with a 200.
+//
+// Admin API:
+//
+//	PUT    /admin/keys/<key>/steps  program the steps for a key, e.g.
+//	                                [{"status": 503, "count": 2}, {"reset": true}]
+//	GET    /admin/keys/<key>        attempts and the log of requests for a key
+//	POST   /admin/reset             forget every key
+//	PUT    /admin/instances/<index>/failure
+//	                                make every request to instance <index> take
+//	                                the given step, e.g. {"status": 503, "duration": "60s"}
+//	GET    /admin/instances/<index>/failure
+//	                                when instance <index> started failing, and
+//	                                when it received each request since
+//	DELETE /admin/instances/<index>/failure
+//	                                stop failing requests to instance <index>
+//	POST   /admin/request           send a request from this app and report the
+//	                                outcome, e.g. {"method": "POST", "url": "http://..."}
+//
+// A step may set:
+//
+//	status  respond with this status code
+//	delay   wait this long (e.g. "2s") before acting
+//	reset   close the connection without responding
+//	abort   send the headers and part of the body, then close the connection
+//	chunks  stream the response in this many chunks
+//	interval  wait this long (e.g. "1s") between chunks
+//	count   repeat the step this many times
+//
+// Instance failures are applied by the instance whose CF_INSTANCE_INDEX
+// matches; other instances answer 409 Conflict, so callers should retry until
+// the request reaches the right instance. With a duration, the failure stops
+// on its own, so an instance that the router stops sending traffic to still
+// recovers.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Step struct {
+	Status   int    `json:"status,omitempty"`
+	Delay    string `json:"delay,omitempty"`
+	Reset    bool   `json:"reset,omitempty"`
+	Abort    bool   `json:"abort,omitempty"`
+	Chunks   int    `json:"chunks,omitempty"`
+	Interval string `json:"interval,omitempty"`
+	Count    int    `json:"count,omitempty"`
+}
+
+type InstanceFailure struct {
+	Step
+	Duration string `json:"duration,omitempty"`
+}
+
+type FailureReport struct {
+	StartedAt      time.Time   `json:"started_at"`
+	Until          time.Time   `json:"until"`
+	FailedRequests []time.Time `json:"failed_requests"`
+}
+
+type Attempt struct {
+	Number int       `json:"number"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	Action string    `json:"action"`
+	Time   time.Time `json:"time"`
+}
+
+type KeyState struct {
+	Key      string    `json:"key"`
+	Attempts int       `json:"attempts"`
+	Log      []Attempt `json:"log"`
+	Pending  []Step    `json:"pending"`
+}
+
+type RelayRequest struct {
+	Method  string `json:"method"`
+	URL     string `json:"url"`
+	Body    string `json:"body"`
+	Timeout string `json:"timeout"`
+}
+
+type RelayResponse struct {
+	Status     int     `json:"status"`
+	Body       string  `json:"body"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+const defaultRelayTimeout = 60 * time.Second
+
+type Backend struct {
+	lock         sync.Mutex
+	keys         map[string]*KeyState
+	failure      *Step
+	failureUntil time.Time
+	report       FailureReport
+}
+
+func main() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		log.Fatal("invalid required env var PORT")
+	}
+
+	backend := &Backend{keys: map[string]*KeyState{}}
+	log.Printf("Listening on %s...", port)
+	log.Fatal(http.ListenAndServe(":"+port, backend))
+}
+
+func (b *Backend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/admin/") {
+		b.serveAdmin(w, r)
+		return
+	}
+
+	key := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[0]
+	if key == "" {
+		key = "default"
+	}
+
+	step, attempt := b.nextStep(key, r)
+
+	if step.Delay != "" {
+		delay, err := time.ParseDuration(step.Delay)
+		if err == nil {
+			time.Sleep(delay)
+		}
+	}
+
+	switch {
+	case step.Reset:
+		resetConnection(w)
+	case step.Abort:
+		abortResponse(w)
+	case step.Chunks > 0:
+		streamResponse(w, step)
+	case step.Status != 0:
+		w.WriteHeader(step.Status)
+		fmt.Fprintf(w, `{"key": %q, "attempt": %d, "status": %d, "instance_index": %q, "instance_guid": %q}`,
+			key, attempt, step.Status, os.Getenv("CF_INSTANCE_INDEX"), os.Getenv("INSTANCE_GUID"))
+	default:
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"key": %q, "attempt": %d, "instance_index": %q, "instance_guid": %q}`,
+			key, attempt, os.Getenv("CF_INSTANCE_INDEX"), os.Getenv("INSTANCE_GUID"))
+	}
+}
+
+func (b *Backend) nextStep(key string, r *http.Request) (Step, int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	state := b.state(key)
+	state.Attempts++
+
+	var step Step
+	if b.failing() {
+		step = *b.failure
+		b.report.FailedRequests = append(b.report.FailedRequests, time.Now())
+	} else if len(state.Pending) > 0 {
+		step = state.Pending[0]
+		if step.Count > 1 {
+			state.Pending[0].Count--
+		} else {
+			state.Pending = state.Pending[1:]
+		}
+	}
+
+	state.Log = append(state.Log, Attempt{
+		Number: state.Attempts,
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Action: describe(step),
+		Time:   time.Now(),
+	})
+	log.Printf("key=%s attempt=%d method=%s action=%s", key, state.Attempts, r.Method, describe(step))
+
+	return step, state.Attempts
+}
+
+func (b *Backend) failing() bool {
+	if b.failure == nil {
+		return false
+	}
+	if !b.failureUntil.IsZero() && time.Now().After(b.failureUntil) {
+		b.failure = nil
+		return false
+	}
+	return true
+}
+
+func (b *Backend) state(key string) *KeyState {
+	state, ok := b.keys[key]
+	if !ok {
+		state = &KeyState{Key: key, Log: []Attempt{}, Pending: []Step{}}
+		b.keys[key] = state
+	}
+	return state
+}
+
+func (b *Backend) serveAdmin(w http.ResponseWriter, r *http.Request) {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if len(segments) == 2 && segments[1] == "request" && r.Method == "POST" {
+		relay(w, r)
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch {
+	case len(segments) == 2 && segments[1] == "reset" && r.Method == "POST":
+		b.keys = map[string]*KeyState{}
+		w.WriteHeader(http.StatusNoContent)
+	case len(segments) == 4 && segments[1] == "keys" && segments[3] == "steps" && r.Method == "PUT":
+		var steps []Step
+		if err := json.NewDecoder(r.Body).Decode(&steps); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, step := range steps {
+			for _, duration := range []string{step.Delay, step.Interval} {
+				if duration == "" {
+					continue
+				}
+				if _, err := time.ParseDuration(duration); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+		}
+		b.state(segments[2]).Pending = steps
+		writeJSON(w, b.keys[segments[2]])
+	case len(segments) == 3 && segments[1] == "keys" && r.Method == "GET":
+		writeJSON(w, b.state(segments[2]))
+	case len(segments) == 4 && segments[1] == "instances" && segments[3] == "failure":
+		if segments[2] != os.Getenv("CF_INSTANCE_INDEX") {
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprintf(w, `{"instance_index": %q}`, os.Getenv("CF_INSTANCE_INDEX"))
+			return
+		}
+		b.serveInstanceFailure(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (b *Backend) serveInstanceFailure(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "PUT":
+		var failure InstanceFailure
+		if err := json.NewDecoder(r.Body).Decode(&failure); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		b.failureUntil = time.Time{}
+		if failure.Duration != "" {
+			duration, err := time.ParseDuration(failure.Duration)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			b.failureUntil = time.Now().Add(duration)
+		}
+		b.failure = &failure.Step
+		b.report = FailureReport{StartedAt: time.Now(), Until: b.failureUntil, FailedRequests: []time.Time{}}
+		writeJSON(w, failure)
+	case "GET":
+		writeJSON(w, b.report)
+	case "DELETE":
+		b.failure = nil
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// relay sends a request on behalf of the caller, so that requests with any
+// method can be made from inside the container, through its sidecar.
+func relay(w http.ResponseWriter, r *http.Request) {
+	var relayReq RelayRequest
+	if err := json.NewDecoder(r.Body).Decode(&relayReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if relayReq.Method == "" {
+		relayReq.Method = "GET"
+	}
+	timeout := defaultRelayTimeout
+	if relayReq.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(relayReq.Timeout)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	req, err := http.NewRequest(relayReq.Method, relayReq.URL, strings.NewReader(relayReq.Body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DisableKeepAlives: true},
+	}
+
+	start := time.Now()
+	var relayResp RelayResponse
+	resp, err := client.Do(req)
+	if err == nil {
+		var body []byte
+		body, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		relayResp.Status = resp.StatusCode
+		relayResp.Body = string(body)
+	}
+	if err != nil {
+		relayResp.Error = err.Error()
+	}
+	relayResp.DurationMS = float64(time.Since(start)) / float64(time.Millisecond)
+
+	writeJSON(w, relayResp)
+}
+
+func describe(step Step) string {
+	actions := []string{}
+	if step.Delay != "" {
+		actions = append(actions, "delay "+step.Delay)
+	}
+	switch {
+	case step.Reset:
+		actions = append(actions, "reset")
+	case step.Abort:
+		actions = append(actions, "abort")
+	case step.Chunks > 0:
+		actions = append(actions, fmt.Sprintf("stream %d chunks every %s", step.Chunks, step.Interval))
+	case step.Status != 0:
+		actions = append(actions, fmt.Sprintf("status %d", step.Status))
+	default:
+		actions = append(actions, "status 200")
+	}
+	return strings.Join(actions, ", ")
+}
+
+// resetConnection closes the client connection without a response. Setting
+// linger to zero makes the kernel send a RST rather than a FIN.
+func resetConnection(w http.ResponseWriter) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// streamResponse writes one line per chunk, flushing each chunk so it is
+// sent immediately.
+func streamResponse(w http.ResponseWriter, step Step) {
+	interval, _ := time.ParseDuration(step.Interval)
+	status := step.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(status)
+	flusher, _ := w.(http.Flusher)
+	for i := 1; i <= step.Chunks; i++ {
+		if i > 1 {
+			time.Sleep(interval)
+		}
+		fmt.Fprintf(w, "chunk %d of %d\n", i, step.Chunks)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// abortResponse promises a body it never finishes sending.
+func abortResponse(w http.ResponseWriter) {
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	buf.WriteString("HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nContent-Length: 1024\r\n\r\npartial body")
+	buf.Flush()
+	conn.Close()
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
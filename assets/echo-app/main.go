@@ -0,0 +1,94 @@
+// echo-app is a test app that describes the request it received: its
+// headers and the client identity forwarded by the sidecar in
+// X-Forwarded-Client-Cert.
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+type Echo struct {
+	Method               string              `json:"method"`
+	Host                 string              `json:"host"`
+	Path                 string              `json:"path"`
+	Proto                string              `json:"proto"`
+	RemoteAddr           string              `json:"remote_addr"`
+	Headers              map[string][]string `json:"headers"`
+	ForwardedClientCerts []map[string]string `json:"forwarded_client_certs"`
+	InstanceIndex        string              `json:"instance_index"`
+	InstanceGUID         string              `json:"instance_guid"`
+	InstanceIP           string              `json:"instance_ip"`
+}
+
+func main() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		log.Fatal("invalid required env var PORT")
+	}
+
+	http.HandleFunc("/", echo)
+
+	log.Printf("Listening on %s...", port)
+	log.Fatal(http.ListenAndServe(":"+port, nil))
+}
+
+func echo(w http.ResponseWriter, r *http.Request) {
+	resp := Echo{
+		Method:               r.Method,
+		Host:                 r.Host,
+		Path:                 r.URL.Path,
+		Proto:                r.Proto,
+		RemoteAddr:           r.RemoteAddr,
+		Headers:              r.Header,
+		ForwardedClientCerts: parseXFCC(r.Header.Get("X-Forwarded-Client-Cert")),
+		InstanceIndex:        os.Getenv("CF_INSTANCE_INDEX"),
+		InstanceGUID:         os.Getenv("INSTANCE_GUID"),
+		InstanceIP:           os.Getenv("CF_INSTANCE_INTERNAL_IP"),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseXFCC parses an Envoy X-Forwarded-Client-Cert header, which holds one
+// comma separated element per hop, each a semicolon separated list of
+// key=value pairs such as By=...;Hash=...;URI=spiffe://...
+func parseXFCC(header string) []map[string]string {
+	elements := []map[string]string{}
+	if header == "" {
+		return elements
+	}
+
+	for _, element := range splitUnquoted(header, ',') {
+		fields := map[string]string{}
+		for _, pair := range splitUnquoted(element, ';') {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			fields[strings.TrimSpace(kv[0])] = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		}
+		elements = append(elements, fields)
+	}
+	return elements
+}
+
+func splitUnquoted(s string, separator rune) []string {
+	parts := []string{}
+	quoted := false
+	start := 0
+	for i, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == separator && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
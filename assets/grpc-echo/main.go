@@ -0,0 +1,314 @@
+// grpc-echo is a gRPC test app served over cleartext HTTP/2 (h2c) by the
+// vendored golang.org/x/net/http2 server. It approximates the gRPC wire
+// protocol rather than using grpc-go: calls are length-prefixed messages on
+// HTTP/2 streams with grpc-status trailers, but the messages are encoded as
+// JSON (content type application/grpc+json) instead of protobuf.
+//
+// It implements the service echo.Echo:
+//
+//   - Unary answers one request, optionally failing with a given gRPC status.
+//   - ServerStream answers one request with Count responses.
+//   - BidiStream answers every request as it arrives.
+//   - Relay makes a unary call to Target and reports what it got back, so
+//     that a test can reach apps on internal routes through this app.
+//
+// Every response names the instance that served it.
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+const contentType = "application/grpc+json"
+
+// gRPC status codes, see https://github.com/grpc/grpc/blob/master/doc/statuscodes.md
+const (
+	codeOK            = 0
+	codeUnknown       = 2
+	codeInvalidArg    = 3
+	codeUnimplemented = 12
+	codeUnavailable   = 14
+)
+
+type Request struct {
+	Message       string `json:"message"`
+	Count         int    `json:"count,omitempty"`
+	StatusCode    int    `json:"status_code,omitempty"`
+	StatusMessage string `json:"status_message,omitempty"`
+	Target        string `json:"target,omitempty"`
+	Method        string `json:"method,omitempty"`
+}
+
+type Response struct {
+	Message       string   `json:"message"`
+	Sequence      int      `json:"sequence"`
+	InstanceIndex string   `json:"instance_index"`
+	InstanceGUID  string   `json:"instance_guid"`
+	Relayed       *Relayed `json:"relayed,omitempty"`
+	Proto         string   `json:"proto"`
+}
+
+// Relayed is the outcome of the call made by Relay.
+type Relayed struct {
+	Responses     []Response `json:"responses"`
+	StatusCode    int        `json:"status_code"`
+	StatusMessage string     `json:"status_message"`
+	Error         string     `json:"error,omitempty"`
+}
+
+func main() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		log.Fatal("invalid required env var PORT")
+	}
+
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Listening for h2c on %s...", port)
+	log.Fatal(serveH2C(listener, http.HandlerFunc(serve)))
+}
+
+// serveH2C only serves HTTP/2 with prior knowledge over cleartext, as gRPC
+// does.
+func serveH2C(listener net.Listener, handler http.Handler) error {
+	server := &http2.Server{}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go server.ServeConn(conn, &http2.ServeConnOpts{Handler: handler})
+	}
+}
+
+func serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "gRPC requests must be POSTs", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+
+	var code int
+	var message string
+	switch r.URL.Path {
+	case "/echo.Echo/Unary":
+		code, message = unary(w, r)
+	case "/echo.Echo/ServerStream":
+		code, message = serverStream(w, r)
+	case "/echo.Echo/BidiStream":
+		code, message = bidiStream(w, r)
+	case "/echo.Echo/Relay":
+		code, message = relay(w, r)
+	default:
+		code, message = codeUnimplemented, fmt.Sprintf("unknown method %s", r.URL.Path)
+	}
+
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	w.Header().Set("Grpc-Message", message)
+}
+
+func unary(w http.ResponseWriter, r *http.Request) (int, string) {
+	var req Request
+	if err := readMessage(r.Body, &req); err != nil {
+		return codeInvalidArg, err.Error()
+	}
+	if req.StatusCode != codeOK {
+		return req.StatusCode, req.StatusMessage
+	}
+
+	if err := writeMessage(w, newResponse(r, req.Message, 1)); err != nil {
+		return codeUnknown, err.Error()
+	}
+	return codeOK, ""
+}
+
+func serverStream(w http.ResponseWriter, r *http.Request) (int, string) {
+	var req Request
+	if err := readMessage(r.Body, &req); err != nil {
+		return codeInvalidArg, err.Error()
+	}
+
+	for i := 1; i <= req.Count; i++ {
+		if err := writeMessage(w, newResponse(r, req.Message, i)); err != nil {
+			return codeUnknown, err.Error()
+		}
+	}
+	if req.StatusCode != codeOK {
+		return req.StatusCode, req.StatusMessage
+	}
+	return codeOK, ""
+}
+
+func bidiStream(w http.ResponseWriter, r *http.Request) (int, string) {
+	// Send the response headers straight away so that the client can start
+	// reading responses while it is still sending requests.
+	w.(http.Flusher).Flush()
+
+	for i := 1; ; i++ {
+		var req Request
+		err := readMessage(r.Body, &req)
+		if err == io.EOF {
+			return codeOK, ""
+		}
+		if err != nil {
+			return codeInvalidArg, err.Error()
+		}
+
+		if err := writeMessage(w, newResponse(r, req.Message, i)); err != nil {
+			return codeUnknown, err.Error()
+		}
+	}
+}
+
+func relay(w http.ResponseWriter, r *http.Request) (int, string) {
+	var req Request
+	if err := readMessage(r.Body, &req); err != nil {
+		return codeInvalidArg, err.Error()
+	}
+	if req.Method == "" {
+		req.Method = "Unary"
+	}
+
+	relayed := call(req.Target, req.Method, Request{
+		Message:       req.Message,
+		Count:         req.Count,
+		StatusCode:    req.StatusCode,
+		StatusMessage: req.StatusMessage,
+	})
+
+	resp := newResponse(r, req.Message, 1)
+	resp.Relayed = &relayed
+	if err := writeMessage(w, resp); err != nil {
+		return codeUnknown, err.Error()
+	}
+	return codeOK, ""
+}
+
+// relayClient is shared by every relayed call so that they are multiplexed
+// over the same connection, as a gRPC client channel would be.
+var relayClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.DialTimeout(network, addr, 30*time.Second)
+		},
+	},
+}
+
+// call makes a gRPC call over h2c to target, e.g. http://app.apps.internal:8080.
+func call(target, method string, req Request) Relayed {
+	var body bytes.Buffer
+	if err := writeMessage(&body, req); err != nil {
+		return Relayed{StatusCode: codeInvalidArg, Error: err.Error()}
+	}
+
+	httpReq, err := http.NewRequest("POST", target+"/echo.Echo/"+method, &body)
+	if err != nil {
+		return Relayed{StatusCode: codeInvalidArg, Error: err.Error()}
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	httpReq.Header.Set("TE", "trailers")
+
+	res, err := relayClient.Do(httpReq)
+	if err != nil {
+		return Relayed{StatusCode: codeUnavailable, Error: err.Error()}
+	}
+	defer res.Body.Close()
+
+	relayed := Relayed{Responses: []Response{}}
+	for {
+		var resp Response
+		err := readMessage(res.Body, &resp)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			relayed.Error = err.Error()
+			break
+		}
+		relayed.Responses = append(relayed.Responses, resp)
+	}
+
+	status := res.Trailer.Get("Grpc-Status")
+	relayed.StatusMessage = res.Trailer.Get("Grpc-Message")
+	if status == "" {
+		// A response with no messages may carry its status in the headers.
+		status = res.Header.Get("Grpc-Status")
+		relayed.StatusMessage = res.Header.Get("Grpc-Message")
+	}
+	if status == "" {
+		relayed.StatusCode = codeUnknown
+		relayed.Error = fmt.Sprintf("no grpc-status in response with HTTP status %d", res.StatusCode)
+		return relayed
+	}
+	relayed.StatusCode, _ = strconv.Atoi(status)
+	return relayed
+}
+
+func newResponse(r *http.Request, message string, sequence int) Response {
+	return Response{
+		Message:       message,
+		Sequence:      sequence,
+		InstanceIndex: os.Getenv("CF_INSTANCE_INDEX"),
+		InstanceGUID:  os.Getenv("INSTANCE_GUID"),
+		Proto:         r.Proto,
+	}
+}
+
+// readMessage reads one length-prefixed gRPC message. It returns io.EOF if
+// the stream ended cleanly before the message started.
+func readMessage(r io.Reader, message interface{}) error {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("truncated message prefix")
+		}
+		return err
+	}
+	if prefix[0] != 0 {
+		return fmt.Errorf("compressed messages are not supported")
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return fmt.Errorf("truncated message: %s", err)
+	}
+	return json.Unmarshal(payload, message)
+}
+
+// writeMessage writes one length-prefixed gRPC message and flushes it.
+func writeMessage(w io.Writer, message interface{}) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	var prefix [5]byte
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(payload)))
+	if _, err := w.Write(append(prefix[:], payload...)); err != nil {
+		return err
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+// streaming-app is a test app that streams responses and consumes large
+// request bodies.
+//
+//   - GET /chunked?chunks=N&interval=D writes N lines "chunk i of N", flushing
+//     each one, D apart, using chunked transfer encoding.
+//   - GET /events?events=N&interval=D writes N Server-Sent Events, D apart.
+//   - GET /download?bytes=N writes N bytes of a fixed pattern with a
+//     Content-Length.
+//   - POST /upload reads the whole request body and reports its length and
+//     SHA-256.
+//   - GET /trailers?chunks=N streams N lines and then sends the SHA-256 of
+//     the body in the X-Body-Sha256 trailer.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// patternPeriod is prime so that the pattern does not line up with buffer
+// sizes, which makes dropped or repeated blocks detectable.
+const patternPeriod = 251
+
+type Upload struct {
+	Bytes  int64  `json:"bytes"`
+	Sha256 string `json:"sha256"`
+}
+
+func main() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		log.Fatal("invalid required env var PORT")
+	}
+
+	http.HandleFunc("/chunked", chunked)
+	http.HandleFunc("/events", events)
+	http.HandleFunc("/download", download)
+	http.HandleFunc("/upload", upload)
+	http.HandleFunc("/trailers", trailers)
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "streaming-app")
+	})
+
+	log.Printf("Listening on %s...", port)
+	log.Fatal(http.ListenAndServe(":"+port, nil))
+}
+
+func chunked(w http.ResponseWriter, r *http.Request) {
+	chunks := intParam(r, "chunks", 5)
+	interval := durationParam(r, "interval", time.Second)
+
+	w.Header().Set("Content-Type", "text/plain")
+	for i := 1; i <= chunks; i++ {
+		if i > 1 {
+			time.Sleep(interval)
+		}
+		fmt.Fprintf(w, "chunk %d of %d\n", i, chunks)
+		w.(http.Flusher).Flush()
+	}
+}
+
+func events(w http.ResponseWriter, r *http.Request) {
+	count := intParam(r, "events", 5)
+	interval := durationParam(r, "interval", time.Second)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	for i := 1; i <= count; i++ {
+		if i > 1 {
+			time.Sleep(interval)
+		}
+		fmt.Fprintf(w, "id: %d\nevent: tick\ndata: event %d of %d\n\n", i, i, count)
+		w.(http.Flusher).Flush()
+	}
+}
+
+func download(w http.ResponseWriter, r *http.Request) {
+	size, err := strconv.ParseInt(r.URL.Query().Get("bytes"), 10, 64)
+	if err != nil || size < 0 {
+		http.Error(w, "bytes must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	if _, err := io.CopyN(w, &pattern{}, size); err != nil {
+		log.Printf("download: %s", err)
+	}
+}
+
+func upload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" && r.Method != "PUT" {
+		http.Error(w, "uploads must be POSTs or PUTs", http.StatusMethodNotAllowed)
+		return
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("reading body: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Upload{Bytes: size, Sha256: hex.EncodeToString(hash.Sum(nil))})
+}
+
+func trailers(w http.ResponseWriter, r *http.Request) {
+	chunks := intParam(r, "chunks", 5)
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Trailer", "X-Body-Sha256")
+
+	hash := sha256.New()
+	body := io.MultiWriter(w, hash)
+	for i := 1; i <= chunks; i++ {
+		fmt.Fprintf(body, "chunk %d of %d\n", i, chunks)
+		w.(http.Flusher).Flush()
+	}
+	w.Header().Set("X-Body-Sha256", hex.EncodeToString(hash.Sum(nil)))
+}
+
+// pattern is an endless reader of the bytes 0, 1, ..., patternPeriod-1, 0, 1, ...
+type pattern struct {
+	offset int64
+}
+
+func (p *pattern) Read(buf []byte) (int, error) {
+	for i := range buf {
+		buf[i] = byte(p.offset % patternPeriod)
+		p.offset++
+	}
+	return len(buf), nil
+}
+
+func intParam(r *http.Request, name string, defaultValue int) int {
+	value, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func durationParam(r *http.Request, name string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(r.URL.Query().Get(name))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
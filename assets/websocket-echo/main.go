@@ -0,0 +1,146 @@
+// websocket-echo is a WebSocket test app.
+//
+//   - /echo upgrades to a WebSocket and echoes every message it receives.
+//   - /relay/<host:port> upgrades to a WebSocket, dials /echo on host:port,
+//     and relays messages both ways, so that a test can reach apps on
+//     internal routes through this app.
+//   - Any other path answers a plain GET with the instance that served it.
+//
+// When the app is stopped it closes every open WebSocket with a close frame
+// before exiting.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/net/websocket"
+)
+
+type Info struct {
+	InstanceIndex string `json:"instance_index"`
+	InstanceGUID  string `json:"instance_guid"`
+}
+
+// connections tracks the open WebSockets so that they can be closed cleanly
+// on shutdown.
+type connections struct {
+	lock  sync.Mutex
+	conns map[*websocket.Conn]bool
+}
+
+func (c *connections) add(conn *websocket.Conn) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.conns[conn] = true
+}
+
+func (c *connections) remove(conn *websocket.Conn) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.conns, conn)
+}
+
+func (c *connections) closeAll() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for conn := range c.conns {
+		conn.Close()
+	}
+}
+
+var open = &connections{conns: map[*websocket.Conn]bool{}}
+
+func main() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		log.Fatal("invalid required env var PORT")
+	}
+
+	http.Handle("/echo", websocket.Handler(echo))
+	http.Handle("/relay/", websocket.Handler(relay))
+	http.HandleFunc("/", info)
+
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+		<-signals
+		log.Print("Closing open WebSockets...")
+		open.closeAll()
+		os.Exit(0)
+	}()
+
+	log.Printf("Listening on %s...", port)
+	log.Fatal(http.ListenAndServe(":"+port, nil))
+}
+
+func info(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Info{
+		InstanceIndex: os.Getenv("CF_INSTANCE_INDEX"),
+		InstanceGUID:  os.Getenv("INSTANCE_GUID"),
+	})
+}
+
+func echo(ws *websocket.Conn) {
+	open.add(ws)
+	defer open.remove(ws)
+
+	for {
+		var message string
+		if err := websocket.Message.Receive(ws, &message); err != nil {
+			if err != io.EOF {
+				log.Printf("echo: %s", err)
+			}
+			return
+		}
+		if err := websocket.Message.Send(ws, message); err != nil {
+			log.Printf("echo: %s", err)
+			return
+		}
+	}
+}
+
+func relay(ws *websocket.Conn) {
+	open.add(ws)
+	defer open.remove(ws)
+
+	target := strings.TrimPrefix(ws.Request().URL.Path, "/relay/")
+	upstream, err := websocket.Dial(fmt.Sprintf("ws://%s/echo", target), "", fmt.Sprintf("http://%s", target))
+	if err != nil {
+		log.Printf("relay: %s", err)
+		websocket.Message.Send(ws, fmt.Sprintf("relay failed: %s", err))
+		return
+	}
+	open.add(upstream)
+	defer open.remove(upstream)
+
+	done := make(chan struct{}, 2)
+	go pipe(upstream, ws, done)
+	go pipe(ws, upstream, done)
+	<-done
+
+	upstream.Close()
+	ws.Close()
+}
+
+func pipe(dst, src *websocket.Conn, done chan<- struct{}) {
+	defer func() { done <- struct{}{} }()
+	for {
+		var message string
+		if err := websocket.Message.Receive(src, &message); err != nil {
+			return
+		}
+		if err := websocket.Message.Send(dst, message); err != nil {
+			return
+		}
+	}
+}
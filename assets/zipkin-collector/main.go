@@ -0,0 +1,298 @@
+// zipkin-collector is a test app that implements enough of the Zipkin API
+// to collect spans from Envoy and serve them back to the tests. Spans are
+// kept in memory.
+//
+//   - POST /api/v2/spans accepts a JSON list of Zipkin v2 spans.
+//   - POST /api/v1/spans accepts a JSON list of Zipkin v1 spans, which are
+//     converted to v2.
+//   - GET /api/v2/trace/<trace id> returns the spans of a trace.
+//   - GET /api/v2/traces?serviceName=NAME&limit=N returns recent traces,
+//     optionally only those with a span from the service.
+//   - GET /api/v2/services returns the names of the services seen.
+//   - DELETE /api/v2/spans forgets every span.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Endpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+	IPv4        string `json:"ipv4,omitempty"`
+	Port        int    `json:"port,omitempty"`
+}
+
+type Span struct {
+	TraceID        string            `json:"traceId"`
+	ID             string            `json:"id"`
+	ParentID       string            `json:"parentId,omitempty"`
+	Name           string            `json:"name,omitempty"`
+	Kind           string            `json:"kind,omitempty"`
+	Timestamp      int64             `json:"timestamp,omitempty"`
+	Duration       int64             `json:"duration,omitempty"`
+	Shared         bool              `json:"shared,omitempty"`
+	LocalEndpoint  *Endpoint         `json:"localEndpoint,omitempty"`
+	RemoteEndpoint *Endpoint         `json:"remoteEndpoint,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"`
+}
+
+type V1Annotation struct {
+	Timestamp int64     `json:"timestamp"`
+	Value     string    `json:"value"`
+	Endpoint  *Endpoint `json:"endpoint,omitempty"`
+}
+
+type V1BinaryAnnotation struct {
+	Key      string      `json:"key"`
+	Value    interface{} `json:"value"`
+	Endpoint *Endpoint   `json:"endpoint,omitempty"`
+}
+
+type V1Span struct {
+	TraceID           string               `json:"traceId"`
+	ID                string               `json:"id"`
+	ParentID          string               `json:"parentId,omitempty"`
+	Name              string               `json:"name"`
+	Timestamp         int64                `json:"timestamp,omitempty"`
+	Duration          int64                `json:"duration,omitempty"`
+	Annotations       []V1Annotation       `json:"annotations"`
+	BinaryAnnotations []V1BinaryAnnotation `json:"binaryAnnotations"`
+}
+
+type store struct {
+	lock   sync.Mutex
+	traces map[string][]Span
+	order  []string
+}
+
+var spans = &store{traces: map[string][]Span{}}
+
+func (s *store) add(newSpans []Span) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, span := range newSpans {
+		traceID := normalizeID(span.TraceID)
+		span.TraceID = traceID
+		if _, ok := s.traces[traceID]; !ok {
+			s.order = append(s.order, traceID)
+		}
+		s.traces[traceID] = append(s.traces[traceID], span)
+	}
+}
+
+// trace returns the spans of a trace. Tracers that only support 64-bit trace
+// IDs report the low 64 bits of a 128-bit ID, so those are tried too.
+func (s *store) trace(traceID string) []Span {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	traceID = normalizeID(traceID)
+	trace := s.traces[traceID]
+	if len(trace) == 0 && len(traceID) == 32 {
+		trace = s.traces[traceID[16:]]
+	}
+	return append([]Span{}, trace...)
+}
+
+// recent returns up to limit traces, most recent first, that have a span
+// from serviceName if it is set.
+func (s *store) recent(serviceName string, limit int) [][]Span {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	traces := [][]Span{}
+	for i := len(s.order) - 1; i >= 0 && len(traces) < limit; i-- {
+		trace := s.traces[s.order[i]]
+		if serviceName == "" || hasService(trace, serviceName) {
+			traces = append(traces, append([]Span{}, trace...))
+		}
+	}
+	return traces
+}
+
+func (s *store) services() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	seen := map[string]bool{}
+	for _, trace := range s.traces {
+		for _, span := range trace {
+			if span.LocalEndpoint != nil && span.LocalEndpoint.ServiceName != "" {
+				seen[span.LocalEndpoint.ServiceName] = true
+			}
+		}
+	}
+	names := []string{}
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *store) reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.traces = map[string][]Span{}
+	s.order = nil
+}
+
+func main() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		log.Fatal("invalid required env var PORT")
+	}
+
+	http.HandleFunc("/api/v2/spans", v2Spans)
+	http.HandleFunc("/api/v1/spans", v1Spans)
+	http.HandleFunc("/api/v2/trace/", trace)
+	http.HandleFunc("/api/v2/traces", traces)
+	http.HandleFunc("/api/v2/services", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, spans.services())
+	})
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "zipkin-collector")
+	})
+
+	log.Printf("Listening on %s...", port)
+	log.Fatal(http.ListenAndServe(":"+port, nil))
+}
+
+func v2Spans(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		if !acceptsJSON(w, r) {
+			return
+		}
+		var newSpans []Span
+		if err := json.NewDecoder(r.Body).Decode(&newSpans); err != nil {
+			http.Error(w, fmt.Sprintf("invalid spans: %s", err), http.StatusBadRequest)
+			return
+		}
+		spans.add(newSpans)
+		w.WriteHeader(http.StatusAccepted)
+	case "DELETE":
+		spans.reset()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func v1Spans(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !acceptsJSON(w, r) {
+		return
+	}
+
+	var v1 []V1Span
+	if err := json.NewDecoder(r.Body).Decode(&v1); err != nil {
+		http.Error(w, fmt.Sprintf("invalid spans: %s", err), http.StatusBadRequest)
+		return
+	}
+	newSpans := []Span{}
+	for _, span := range v1 {
+		newSpans = append(newSpans, fromV1(span))
+	}
+	spans.add(newSpans)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func trace(w http.ResponseWriter, r *http.Request) {
+	traceID := strings.TrimPrefix(r.URL.Path, "/api/v2/trace/")
+	trace := spans.trace(traceID)
+	if len(trace) == 0 {
+		http.Error(w, fmt.Sprintf("trace %s not found", traceID), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, trace)
+}
+
+func traces(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+	writeJSON(w, spans.recent(r.URL.Query().Get("serviceName"), limit))
+}
+
+// fromV1 converts a v1 span, which records its kind and endpoints in
+// annotations, to a v2 span.
+func fromV1(v1 V1Span) Span {
+	span := Span{
+		TraceID:   v1.TraceID,
+		ID:        v1.ID,
+		ParentID:  v1.ParentID,
+		Name:      v1.Name,
+		Timestamp: v1.Timestamp,
+		Duration:  v1.Duration,
+		Tags:      map[string]string{},
+	}
+
+	for _, annotation := range v1.Annotations {
+		switch annotation.Value {
+		case "cs", "cr":
+			span.Kind = "CLIENT"
+		case "sr", "ss":
+			span.Kind = "SERVER"
+		}
+		if annotation.Endpoint != nil && span.LocalEndpoint == nil {
+			span.LocalEndpoint = annotation.Endpoint
+		}
+	}
+	for _, annotation := range v1.BinaryAnnotations {
+		span.Tags[annotation.Key] = fmt.Sprint(annotation.Value)
+		if annotation.Endpoint != nil && span.LocalEndpoint == nil {
+			span.LocalEndpoint = annotation.Endpoint
+		}
+	}
+	return span
+}
+
+func acceptsJSON(w http.ResponseWriter, r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "" && !strings.HasPrefix(contentType, "application/json") {
+		http.Error(w, fmt.Sprintf("unsupported content type %s, only JSON spans are supported", contentType), http.StatusUnsupportedMediaType)
+		return false
+	}
+	return true
+}
+
+func hasService(trace []Span, serviceName string) bool {
+	for _, span := range trace {
+		if span.LocalEndpoint != nil && span.LocalEndpoint.ServiceName == serviceName {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeID lowercases a trace ID, drops the high 64 bits of a 128-bit ID
+// when they are zero and pads shorter IDs to 64 bits, as Zipkin does.
+func normalizeID(id string) string {
+	id = strings.ToLower(id)
+	if len(id) == 32 && strings.Trim(id[:16], "0") == "" {
+		return id[16:]
+	}
+	if len(id) < 16 {
+		return strings.Repeat("0", 16-len(id)) + id
+	}
+	return id
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
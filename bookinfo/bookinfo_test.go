@@ -5,30 +5,30 @@ import (
 	"os"
 	"time"
 
+	"code.cloudfoundry.org/istio-acceptance-tests/browser"
+	"code.cloudfoundry.org/istio-acceptance-tests/capability"
 	"code.cloudfoundry.org/istio-acceptance-tests/config"
-	"github.com/sclevine/agouti"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	. "github.com/sclevine/agouti/matchers"
 )
 
 var _ = Describe("Bookinfo", func() {
 	var (
-		page *agouti.Page
+		page browser.Browser
 		c    config.Config
 	)
 
 	BeforeEach(func() {
-		var err error
-		page, err = agoutiDriver.NewPage()
-		Expect(err).NotTo(HaveOccurred())
+		requireCapabilities(capability.Docker)
+		page = newPage()
 		SetDefaultEventuallyPollingInterval(3 * time.Second)
 		SetDefaultEventuallyTimeout(20 * time.Second)
 
 		configPath := os.Getenv("CONFIG")
 		Expect(configPath).NotTo(BeEmpty())
 
+		var err error
 		c, err = config.NewConfig(configPath)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(c.Validate()).To(Succeed())
@@ -38,23 +38,26 @@ var _ = Describe("Bookinfo", func() {
 	})
 
 	AfterEach(func() {
-		Expect(page.Destroy()).To(Succeed())
+		if page != nil {
+			Expect(page.Destroy()).To(Succeed())
+			page = nil
+		}
 	})
 
 	var _ = Describe("Bookinfo Pages", func() {
 		Context("Product Page", func() {
 			BeforeEach(func() {
-				productPage := fmt.Sprintf("http://productpage.%s", c.IstioDomain)
+				productPage := fmt.Sprintf("%s://productpage.%s", appScheme, c.IstioDomain)
 				Expect(page.Navigate(productPage)).To(Succeed())
-				Eventually(load(page), defaultTimeout, time.Second).Should(ContainSubstring("Simple Bookstore App"))
+				Eventually(load(page), convergenceTimeout, time.Second).Should(ContainSubstring("Simple Bookstore App"))
 			})
 
 			It("has the correct content", func() {
-				Expect(page.FindByLink("Normal user")).To(BeFound())
-				Expect(page.FindByLink("Test user")).To(BeFound())
+				Expect(page.HasLink("Normal user")).To(BeTrue())
+				Expect(page.HasLink("Test user")).To(BeTrue())
 
-				Expect(page).To(HaveTitle("Simple Bookstore App"))
-				Expect(page.Find("h3")).To(HaveText("Hello! This is a simple bookstore application consisting of three services as shown below"))
+				Expect(page.Title()).To(Equal("Simple Bookstore App"))
+				Expect(page.Text("h3")).To(Equal("Hello! This is a simple bookstore application consisting of three services as shown below"))
 			})
 
 			It("has the correct internal addresses", func() {
@@ -70,31 +73,31 @@ var _ = Describe("Bookinfo", func() {
 
 			Context("Normal user", func() {
 				BeforeEach(func() {
-					Expect(page.FindByLink("Normal user").Click()).To(Succeed())
+					Expect(page.ClickLink("Normal user")).To(Succeed())
 				})
 
 				It("navigates to the product page for the Comedy of Errors", func() {
-					Eventually(load(page), defaultTimeout, time.Second).Should(ContainSubstring("The Comedy of Errors"))
+					Eventually(load(page), convergenceTimeout, time.Second).Should(ContainSubstring("The Comedy of Errors"))
 				})
 
 				It("displays details successfully", func() {
-					Eventually(load(page), defaultTimeout, time.Second).Should(ContainSubstring("1234567890"))
+					Eventually(load(page), convergenceTimeout, time.Second).Should(ContainSubstring("1234567890"))
 				})
 
 				It("displays reviews successfully", func() {
-					Eventually(load(page), defaultTimeout, time.Second).Should(ContainSubstring("An extremely entertaining play by Shakespeare."))
+					Eventually(load(page), convergenceTimeout, time.Second).Should(ContainSubstring("An extremely entertaining play by Shakespeare."))
 				})
 
 				It("displays red ratings successfully", func() {
-					Eventually(load(page), defaultTimeout, time.Second).Should(ContainSubstring(`font color="red"`))
-					Eventually(load(page), defaultTimeout, time.Second).Should(ContainSubstring("glyphicon glyphicon-star"))
+					Eventually(load(page), convergenceTimeout, time.Second).Should(ContainSubstring(`font color="red"`))
+					Eventually(load(page), convergenceTimeout, time.Second).Should(ContainSubstring("glyphicon glyphicon-star"))
 				})
 			})
 		})
 	})
 })
 
-func load(page *agouti.Page) func() string {
+func load(page browser.Browser) func() string {
 	return func() string {
 		err := page.Refresh()
 		Expect(err).NotTo(HaveOccurred())
@@ -0,0 +1,168 @@
+package bookinfo
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/browser"
+	"code.cloudfoundry.org/istio-acceptance-tests/capability"
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"code.cloudfoundry.org/istio-acceptance-tests/helpers"
+	"code.cloudfoundry.org/istio-acceptance-tests/topology"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/types"
+)
+
+// The error texts the product page and reviews show in place of a service
+// they cannot reach.
+const (
+	detailsUnavailable = "Sorry, product details are currently unavailable for this book."
+	reviewsUnavailable = "Sorry, product reviews are currently unavailable for this book."
+	ratingsUnavailable = "Ratings service is currently unavailable"
+)
+
+// faultScenario takes one dependency of the product page away. The page is
+// expected to keep rendering, with one of the Degraded texts in place of the
+// missing content.
+type faultScenario struct {
+	Description string
+	Break       func()
+	Restore     func()
+	Degraded    []string
+}
+
+var _ = Describe("Graceful Degradation", func() {
+	var (
+		c          config.Config
+		cc         *capi.Client
+		page       browser.Browser
+		productURL string
+		appGUIDs   map[string]string
+		restore    func()
+	)
+
+	BeforeEach(func() {
+		requireCapabilities(capability.Docker)
+
+		configPath := os.Getenv("CONFIG")
+		Expect(configPath).NotTo(BeEmpty())
+
+		var err error
+		c, err = config.NewConfig(configPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Validate()).To(Succeed())
+
+		cc = capi.NewClient(c)
+		appGUIDs = map[string]string{}
+		for _, app := range []string{"productpage", "details", "reviews", "ratings"} {
+			appGUIDs[app] = helpers.AppGUID(cc, spaceGUID, faultsDeployment.App(app))
+		}
+
+		page = newPage()
+		productURL = fmt.Sprintf("%s://%s.%s/productpage?u=normal", appScheme, faultsDeployment.App("productpage"), c.IstioDomain)
+		Expect(page.Navigate(productURL)).To(Succeed())
+		Eventually(load(page), convergenceTimeout, time.Second).Should(beHealthy())
+	})
+
+	AfterEach(func() {
+		if restore != nil {
+			restore()
+			restore = nil
+		}
+		if page != nil {
+			Expect(page.Destroy()).To(Succeed())
+			page = nil
+		}
+	})
+
+	// The scenarios share one copy of bookinfo, so they run one after
+	// another in a single spec.
+	It("keeps rendering the product page while a dependency is unavailable, and recovers", func() {
+		maxResponseTime := c.GetBookinfoFaultPolicy().GetMaxDegradedResponseTime()
+		reviewsToRatings := capi.NewPolicy(appGUIDs["reviews"], appGUIDs["ratings"], "tcp", topology.BookinfoPort)
+
+		for _, scenario := range []faultScenario{
+			{
+				Description: "ratings is stopped",
+				Break:       func() { stopApp(cc, appGUIDs["ratings"]) },
+				Restore:     func() { startApp(cc, appGUIDs["ratings"]) },
+				Degraded:    []string{ratingsUnavailable},
+			},
+			{
+				Description: "the reviews to ratings network policy is removed",
+				Break: func() {
+					Expect(cc.DeletePolicies([]capi.Policy{reviewsToRatings})).To(Succeed())
+				},
+				Restore: func() {
+					Expect(cc.CreatePolicies([]capi.Policy{reviewsToRatings})).To(Succeed())
+				},
+				// reviews may give up on ratings after the product page has
+				// given up on reviews
+				Degraded: []string{ratingsUnavailable, reviewsUnavailable},
+			},
+			{
+				Description: "details crashes",
+				Break: func() {
+					Expect(cc.SetProcessCommand(appGUIDs["details"], "web", "false")).To(Succeed())
+					stopApp(cc, appGUIDs["details"])
+					startApp(cc, appGUIDs["details"])
+				},
+				Restore: func() {
+					Expect(cc.SetProcessCommand(appGUIDs["details"], "web", "")).To(Succeed())
+					stopApp(cc, appGUIDs["details"])
+					startApp(cc, appGUIDs["details"])
+				},
+				Degraded: []string{detailsUnavailable},
+			},
+		} {
+			By(fmt.Sprintf("degrading when %s", scenario.Description))
+			restore = scenario.Restore
+			scenario.Break()
+
+			degraded := []types.GomegaMatcher{}
+			for _, text := range scenario.Degraded {
+				degraded = append(degraded, ContainSubstring(text))
+			}
+			Eventually(load(page), convergenceTimeout, time.Second).Should(SatisfyAny(degraded...))
+
+			for i := 0; i < 3; i++ {
+				start := time.Now()
+				html := load(page)()
+				elapsed := time.Since(start)
+
+				Expect(html).To(ContainSubstring("Simple Bookstore App"))
+				Expect(html).To(SatisfyAny(degraded...))
+				Expect(elapsed).To(BeNumerically("<=", maxResponseTime),
+					fmt.Sprintf("the product page took %s to render when %s", elapsed, scenario.Description))
+			}
+
+			By(fmt.Sprintf("recovering once %s no longer holds", scenario.Description))
+			restore = nil
+			scenario.Restore()
+			Eventually(load(page), convergenceTimeout, time.Second).Should(beHealthy())
+		}
+	})
+})
+
+// beHealthy matches a product page showing details, reviews and ratings.
+func beHealthy() types.GomegaMatcher {
+	return SatisfyAll(
+		ContainSubstring("1234567890"),
+		ContainSubstring("An extremely entertaining play by Shakespeare."),
+		ContainSubstring(`font color="red"`),
+	)
+}
+
+func stopApp(cc *capi.Client, appGUID string) {
+	_, err := cc.StopApp(appGUID)
+	Expect(err).NotTo(HaveOccurred())
+}
+
+func startApp(cc *capi.Client, appGUID string) {
+	_, err := cc.StartApp(appGUID)
+	Expect(err).NotTo(HaveOccurred())
+}
@@ -1,12 +1,21 @@
 package bookinfo
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"code.cloudfoundry.org/istio-acceptance-tests/browser"
+	"code.cloudfoundry.org/istio-acceptance-tests/capability"
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
 	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"code.cloudfoundry.org/istio-acceptance-tests/helpers"
+	"code.cloudfoundry.org/istio-acceptance-tests/httpclient"
+	"code.cloudfoundry.org/istio-acceptance-tests/topology"
 	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
 	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
 	"github.com/sclevine/agouti"
@@ -18,10 +27,88 @@ import (
 
 var (
 	agoutiDriver   *agouti.WebDriver
+	bookinfoDriver string
+	spaceGUID      string
 	TestSetup      *workflowhelpers.ReproducibleTestSuiteSetup
-	defaultTimeout = 120 * time.Second
+
+	// capabilities are decided on by node 1, which only deploys what the
+	// foundation can run, and shared with the other nodes.
+	capabilities capability.Set
+
+	// Timeouts for each kind of operation, set from the config before any
+	// spec runs.
+	pushTimeout        time.Duration
+	cfTimeout          time.Duration
+	convergenceTimeout time.Duration
+	requestTimeout     time.Duration
+
+	// httpClient makes every request to apps, with the TLS settings and
+	// request timeout from the config. appScheme is the scheme of requests
+	// to the product page.
+	httpClient *http.Client
+	appScheme  string
+
+	// mainDeployment is the bookinfo deployment most specs read from.
+	// faultsDeployment is a copy that the fault specs break and restore, so
+	// that they do not disturb specs running in parallel. Both are set by
+	// setDeployments on every node.
+	mainDeployment   topology.Bookinfo
+	faultsDeployment topology.Bookinfo
+
+	// existingSpaceContents is what the existing space held before the
+	// suite deployed bookinfo, when the config names one. It is only set on
+	// node 1, along with cleanupNames.
+	existingSpaceContents *helpers.SpaceContents
+	cleanupNames          map[string]bool
+
+	// cleanupClient deletes what the suite leaves behind once the apps are
+	// gone. faultsDomainCreated is whether the suite created the faults
+	// deployment's services domain, which is shared with the whole
+	// foundation. Both are only set on node 1.
+	cleanupClient       *capi.Client
+	faultsDomainCreated bool
 )
 
+// bookinfoNames returns the names of the apps the suite pushes and the hosts
+// of the routes it creates. They are fixed, rather than generated with the
+// name prefix, since the bookinfo apps find each other by hostname.
+func bookinfoNames(c config.Config) map[string]bool {
+	names := map[string]bool{
+		shiftingProductPageApp: true,
+		shiftingReviewsRoute:   true,
+		tracingCollectorApp:    true,
+	}
+	for _, name := range append(mainDeployment.Apps(), faultsDeployment.Apps()...) {
+		names[name] = true
+	}
+	for _, version := range reviewsVersions {
+		names[shiftingReviewsApp(version)] = true
+	}
+	if c.TracingCollector.Hostname != "" {
+		names[c.TracingCollector.Hostname] = true
+	}
+	return names
+}
+
+// cfRunner runs cf commands for the topology package the way the suites do.
+func cfRunner(args ...string) error {
+	timeout := cfTimeout
+	if args[0] == "push" || args[0] == "restage" {
+		timeout = pushTimeout
+	}
+	session := cf.Cf(args...).Wait(timeout)
+	if session.ExitCode() != 0 {
+		return fmt.Errorf("cf %s exited with %d", strings.Join(args, " "), session.ExitCode())
+	}
+	return nil
+}
+
+// suiteState is what node 1 passes on to the other nodes.
+type suiteState struct {
+	SpaceGUID    string
+	Capabilities capability.Set
+}
+
 func TestBookinfo(t *testing.T) {
 	RegisterFailHandler(Fail)
 
@@ -36,37 +123,86 @@ var _ = SynchronizedBeforeSuite(func() []byte {
 	c, err := config.NewConfig(configPath)
 	Expect(err).ToNot(HaveOccurred())
 	Expect(c.Validate()).To(Succeed())
-	if c.CFInternalAppsDomain == "" {
-		createCmd := cf.Cf("curl", "/v2/shared_domains", "-d", fmt.Sprintf("{\"name\": \"%s\", \"internal\": true}", config.DefaultInternalAppsDomain))
-		Expect(createCmd.Wait(defaultTimeout)).To(Exit(0))
-		c.CFInternalAppsDomain = config.DefaultInternalAppsDomain
+	setTimeouts(c)
+	c.CFInternalAppsDomain = helpers.EnsureInternalAppsDomain(c, cfTimeout)
+	setDeployments(c)
+	cleanupClient = capi.NewClient(c)
+
+	if c.GetUseExistingSpace() {
+		existingSpaceGUID := helpers.SpaceGUID(cleanupClient, c.GetExistingOrganization(), c.GetExistingSpace())
+		contents := helpers.SnapshotSpace(cleanupClient, existingSpaceGUID)
+		existingSpaceContents = &contents
+		cleanupNames = bookinfoNames(c)
 	}
 
 	TestSetup = workflowhelpers.NewTestSuiteSetup(c)
 	TestSetup.Setup()
 
-	workflowhelpers.AsUser(TestSetup.AdminUserContext(), defaultTimeout, func() {
-		Expect(cf.Cf("enable-feature-flag", "diego_docker").Wait(defaultTimeout)).To(Exit(0))
-	})
+	// The flag is off by default, so it is enabled before capabilities are
+	// detected, unless docker is declared unavailable.
+	if docker := c.GetCapabilities().Docker; docker == nil || *docker {
+		workflowhelpers.AsUser(TestSetup.AdminUserContext(), cfTimeout, func() {
+			Expect(cf.Cf("enable-feature-flag", "diego_docker").Wait(cfTimeout)).To(Exit(0))
+		})
+	}
+	capabilities = capability.NewDetector(c, capi.NewClient(c)).Resolve()
+
+	testSpaceGUID := helpers.SpaceGUID(capi.NewClient(c), TestSetup.TestSpace.OrganizationName(), TestSetup.TestSpace.SpaceName())
+	state, err := json.Marshal(suiteState{SpaceGUID: testSpaceGUID, Capabilities: capabilities})
+	Expect(err).NotTo(HaveOccurred())
+	if !capabilities.Available(capability.Docker) {
+		return state
+	}
+	trafficShifting := capabilities.Require(trafficShiftingCapabilities...) == nil
+
+	Expect(mainDeployment.Push(cfRunner, c)).To(Succeed())
+
+	faultsDomainCreated = true
+	helpers.EnsureInternalDomain(cleanupClient, faultsDeployment.ServicesDomain)
+	Expect(faultsDeployment.Push(cfRunner, c)).To(Succeed())
+
+	if trafficShifting {
+		pushTrafficShifting(c)
+	}
+
+	if c.TracingCollector.Hostname != "" {
+		pushTracingCollector(c)
+	}
+
+	workflowhelpers.AsUser(TestSetup.AdminUserContext(), cfTimeout, func() {
+		Expect(cf.Cf("target", "-o", TestSetup.TestSpace.OrganizationName(), "-s", TestSetup.TestSpace.SpaceName()).Wait(cfTimeout)).To(Exit(0))
+		Expect(mainDeployment.AddPolicies(cfRunner)).To(Succeed())
+		Expect(faultsDeployment.AddPolicies(cfRunner)).To(Succeed())
+		if trafficShifting {
+			addTrafficShiftingPolicies()
+		}
 
-	Expect(cf.Cf("push", "productpage", "-o", c.ProductPageDockerWithTag, "-d", c.IstioDomain).Wait(defaultTimeout)).To(Exit(0))
-	Expect(cf.Cf("push", "ratings", "-o", c.RatingsDockerWithTag, "-d", c.CFInternalAppsDomain).Wait(defaultTimeout)).To(Exit(0))
-	Expect(cf.Cf("push", "reviews", "-o", c.ReviewsDockerWithTag, "-d", c.CFInternalAppsDomain, "-u", "none").Wait(defaultTimeout)).To(Exit(0))
-	Expect(cf.Cf("push", "details", "-o", c.DetailsDockerWithTag, "-d", c.CFInternalAppsDomain).Wait(defaultTimeout)).To(Exit(0))
-	Expect(cf.Cf("set-env", "productpage", "SERVICES_DOMAIN", c.CFInternalAppsDomain).Wait(defaultTimeout)).To(Exit(0))
-	Expect(cf.Cf("restage", "productpage").Wait(defaultTimeout)).To(Exit(0))
-	Expect(cf.Cf("set-env", "reviews", "SERVICES_DOMAIN", c.CFInternalAppsDomain).Wait(defaultTimeout)).To(Exit(0))
-	Expect(cf.Cf("restage", "reviews").Wait(defaultTimeout)).To(Exit(0))
-
-	workflowhelpers.AsUser(TestSetup.AdminUserContext(), defaultTimeout, func() {
-		Expect(cf.Cf("target", "-o", TestSetup.TestSpace.OrganizationName(), "-s", TestSetup.TestSpace.SpaceName()).Wait(defaultTimeout)).To(Exit(0))
-		Expect(cf.Cf("add-network-policy", "productpage", "--destination-app", "details", "--protocol", "tcp", "--port", "9080").Wait(defaultTimeout)).To(Exit(0))
-		Expect(cf.Cf("add-network-policy", "productpage", "--destination-app", "reviews", "--protocol", "tcp", "--port", "9080").Wait(defaultTimeout)).To(Exit(0))
-		Expect(cf.Cf("add-network-policy", "reviews", "--destination-app", "ratings", "--protocol", "tcp", "--port", "9080").Wait(defaultTimeout)).To(Exit(0))
+		if c.TracingCollector.Hostname != "" {
+			for _, app := range []string{"productpage", "details", "reviews", "ratings"} {
+				Expect(cf.Cf("add-network-policy", app, "--destination-app", tracingCollectorApp, "--protocol", "tcp", "--port", "8080").Wait(cfTimeout)).To(Exit(0))
+			}
+		}
 	})
 
-	return []byte{}
+	return state
 }, func(data []byte) {
+	var state suiteState
+	Expect(json.Unmarshal(data, &state)).To(Succeed())
+	spaceGUID = state.SpaceGUID
+	capabilities = state.Capabilities
+
+	c, err := config.NewConfig(os.Getenv("CONFIG"))
+	Expect(err).ToNot(HaveOccurred())
+	setTimeouts(c)
+	setDeployments(c)
+	httpClient, err = httpclient.New(c)
+	Expect(err).NotTo(HaveOccurred())
+	appScheme = c.GetAppScheme()
+	bookinfoDriver = c.GetBookinfoDriver()
+	if bookinfoDriver != config.BookinfoDriverChrome {
+		return
+	}
+
 	agoutiDriver = agouti.ChromeDriver(
 		agouti.ChromeOptions("args", []string{
 			"--headless",
@@ -84,5 +220,55 @@ var _ = SynchronizedAfterSuite(func() {
 		TestSetup.Teardown()
 	}
 }, func() {
-	Expect(agoutiDriver.Stop()).To(Succeed())
+	fmt.Println(capabilities.Summary())
+
+	if existingSpaceContents != nil {
+		helpers.DeleteCreatedSince(cleanupClient, spaceGUID, *existingSpaceContents, func(name string) bool {
+			return cleanupNames[name]
+		})
+	}
+
+	if faultsDomainCreated {
+		Expect(topology.DeleteInternalDomain(cleanupClient, faultsDeployment.ServicesDomain)).To(Succeed())
+	}
+
+	if agoutiDriver != nil {
+		Expect(agoutiDriver.Stop()).To(Succeed())
+	}
 })
+
+// setDeployments builds the bookinfo deployments, whose services are on the
+// internal apps domain, or on a subdomain of it for the faults copy.
+func setDeployments(c config.Config) {
+	internalAppsDomain := c.CFInternalAppsDomain
+	if internalAppsDomain == "" {
+		internalAppsDomain = config.DefaultInternalAppsDomain
+	}
+	mainDeployment = topology.NewBookinfo("", internalAppsDomain)
+	faultsDeployment = topology.NewBookinfo("faults", internalAppsDomain)
+}
+
+func setTimeouts(c config.Config) {
+	pushTimeout = c.GetPushTimeout()
+	cfTimeout = c.GetCFAPITimeout()
+	convergenceTimeout = c.GetRouteConvergenceTimeout()
+	requestTimeout = c.GetHTTPRequestTimeout()
+}
+
+// requireCapabilities skips the spec unless the foundation has all of names.
+func requireCapabilities(names ...capability.Name) {
+	if err := capabilities.Require(names...); err != nil {
+		Skip(err.Error())
+	}
+}
+
+// newPage returns a page to drive with the configured bookinfo driver.
+func newPage() browser.Browser {
+	if bookinfoDriver == config.BookinfoDriverHTTP {
+		return browser.NewHTTP(httpClient)
+	}
+
+	page, err := agoutiDriver.NewPage()
+	Expect(err).NotTo(HaveOccurred())
+	return browser.NewAgouti(page)
+}
@@ -0,0 +1,195 @@
+package bookinfo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capability"
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+)
+
+const (
+	tracingCollectorApp      = "zipkin-collector"
+	tracingCollectorPath     = "../assets/zipkin-collector"
+	tracingCollectorManifest = "../assets/zipkin-collector/manifest.yml"
+)
+
+// span is a Zipkin v2 span as served by the collector.
+type span struct {
+	TraceID       string `json:"traceId"`
+	ID            string `json:"id"`
+	ParentID      string `json:"parentId"`
+	Name          string `json:"name"`
+	Kind          string `json:"kind"`
+	LocalEndpoint *struct {
+		ServiceName string `json:"serviceName"`
+	} `json:"localEndpoint"`
+}
+
+func (s span) serviceName() string {
+	if s.LocalEndpoint == nil {
+		return ""
+	}
+	return s.LocalEndpoint.ServiceName
+}
+
+// belongsTo is whether the span was reported for app, which Envoy records
+// in the service name or, for calls to it, in the span name.
+func (s span) belongsTo(app string) bool {
+	return strings.Contains(strings.ToLower(s.serviceName()), app) ||
+		strings.HasPrefix(strings.ToLower(s.Name), app+".")
+}
+
+var _ = Describe("Tracing", func() {
+	var (
+		c            config.Config
+		collectorURL string
+	)
+
+	BeforeEach(func() {
+		requireCapabilities(capability.Docker)
+
+		configPath := os.Getenv("CONFIG")
+		Expect(configPath).NotTo(BeEmpty())
+
+		var err error
+		c, err = config.NewConfig(configPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Validate()).To(Succeed())
+
+		if c.TracingCollector.Hostname == "" {
+			Skip("skipping tracing test, no tracing_collector hostname supplied")
+		}
+		collectorURL = fmt.Sprintf("%s://%s.%s", appScheme, tracingCollectorApp, c.IstioDomain)
+	})
+
+	It("records a single trace across productpage, details, reviews and ratings", func() {
+		traceID := randomHexID(16)
+		rootSpanID := randomHexID(8)
+
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s://productpage.%s/productpage?u=normal", appScheme, c.IstioDomain), nil)
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("X-B3-TraceId", traceID)
+		req.Header.Set("X-B3-SpanId", rootSpanID)
+		req.Header.Set("X-B3-Sampled", "1")
+
+		res, err := httpClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		res.Body.Close()
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+		var trace []span
+		Eventually(func() []string {
+			trace = getTrace(collectorURL, traceID)
+			return tracedApps(trace)
+		}, convergenceTimeout, 5*time.Second).Should(ConsistOf("productpage", "details", "reviews", "ratings"))
+
+		By("linking every span to the trace")
+		spansByID := map[string]span{}
+		for _, s := range trace {
+			spansByID[s.ID] = s
+		}
+		for _, s := range trace {
+			if s.ParentID != "" && s.ParentID != rootSpanID {
+				Expect(spansByID).To(HaveKey(s.ParentID), fmt.Sprintf("span %s (%s) has an unknown parent", s.ID, s.Name))
+			}
+		}
+
+		By("nesting each call under its caller")
+		for caller, callee := range map[string][]string{
+			"productpage": {"details", "reviews"},
+			"reviews":     {"ratings"},
+		} {
+			for _, app := range callee {
+				Expect(hasAncestor(trace, spansByID, app, caller)).To(BeTrue(),
+					fmt.Sprintf("no span of %s descends from a span of %s:\n%s", app, caller, describeTrace(trace)))
+			}
+		}
+	})
+})
+
+func pushTracingCollector(c config.Config) {
+	domain := c.TracingCollector.Domain
+	if domain == "" {
+		domain = c.CFInternalAppsDomain
+	}
+
+	Expect(cf.Cf("push", tracingCollectorApp,
+		"-s", "cflinuxfs3",
+		"-d", domain,
+		"--hostname", c.TracingCollector.Hostname,
+		"-f", tracingCollectorManifest,
+		"-p", tracingCollectorPath).Wait(pushTimeout)).To(Exit(0))
+	Expect(cf.Cf("map-route", tracingCollectorApp, c.IstioDomain, "--hostname", tracingCollectorApp).Wait(cfTimeout)).To(Exit(0))
+}
+
+func getTrace(collectorURL, traceID string) []span {
+	res, err := httpClient.Get(fmt.Sprintf("%s/api/v2/trace/%s", collectorURL, traceID))
+	Expect(err).NotTo(HaveOccurred())
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+	var trace []span
+	Expect(json.NewDecoder(res.Body).Decode(&trace)).To(Succeed())
+	return trace
+}
+
+func tracedApps(trace []span) []string {
+	apps := []string{}
+	for _, app := range []string{"productpage", "details", "reviews", "ratings"} {
+		for _, s := range trace {
+			if s.belongsTo(app) {
+				apps = append(apps, app)
+				break
+			}
+		}
+	}
+	return apps
+}
+
+// hasAncestor is whether some span of app has a span of caller among its
+// ancestors.
+func hasAncestor(trace []span, spansByID map[string]span, app, caller string) bool {
+	for _, s := range trace {
+		if !s.belongsTo(app) {
+			continue
+		}
+		visited := map[string]bool{}
+		for parent, ok := spansByID[s.ParentID]; ok && !visited[parent.ID]; parent, ok = spansByID[parent.ParentID] {
+			if parent.belongsTo(caller) {
+				return true
+			}
+			visited[parent.ID] = true
+		}
+	}
+	return false
+}
+
+func describeTrace(trace []span) string {
+	lines := []string{}
+	for _, s := range trace {
+		lines = append(lines, fmt.Sprintf("  id=%s parent=%s kind=%s service=%s name=%s", s.ID, s.ParentID, s.Kind, s.serviceName(), s.Name))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func randomHexID(bytes int) string {
+	id := make([]byte, bytes)
+	_, err := rand.Read(id)
+	Expect(err).NotTo(HaveOccurred())
+	return hex.EncodeToString(id)
+}
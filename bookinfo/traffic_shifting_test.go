@@ -0,0 +1,223 @@
+package bookinfo
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capability"
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"code.cloudfoundry.org/istio-acceptance-tests/helpers"
+	"code.cloudfoundry.org/istio-acceptance-tests/matchers"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+)
+
+// The traffic shifting scenario runs its own productpage and one app per
+// reviews version, so that shifting the weights of its reviews route does not
+// change what the other specs see. Its services are resolved on the internal
+// istio domain, where details and ratings are mapped next to reviews.
+const (
+	shiftingProductPageApp = "productpage-shifting"
+	shiftingReviewsRoute   = "reviews"
+
+	// minShiftingSamples keeps routes weighted to a single version from being
+	// judged on a handful of pages.
+	minShiftingSamples = 20
+
+	// settledPages is how many product pages in a row have to show only
+	// versions with a weight before the new weights count as propagated.
+	settledPages = 10
+)
+
+var reviewsVersions = []string{"v1", "v2", "v3"}
+
+// trafficShiftingCapabilities are what the scenario needs. Its apps are only
+// deployed on foundations that have them all.
+var trafficShiftingCapabilities = []capability.Name{
+	capability.Docker,
+	capability.InternalRoutes,
+	capability.SidecarProxying,
+	capability.WeightedRouting,
+}
+
+func shiftingReviewsApp(version string) string {
+	return "reviews-" + version
+}
+
+func weightedRoutingConfidence(c config.Config) float64 {
+	if c.WeightedRoutingConfidence == 0 {
+		return matchers.DefaultConfidence
+	}
+	return c.WeightedRoutingConfidence
+}
+
+func shiftingDomain(c config.Config) string {
+	if c.CFInternalIstioDomain == "" {
+		return config.DefaultInternalIstioDomain
+	}
+	return c.CFInternalIstioDomain
+}
+
+var _ = Describe("Traffic Shifting", func() {
+	var (
+		c          config.Config
+		cc         *capi.Client
+		routeGUID  string
+		appGUIDs   map[string]string
+		productURL string
+	)
+
+	BeforeEach(func() {
+		requireCapabilities(trafficShiftingCapabilities...)
+
+		configPath := os.Getenv("CONFIG")
+		Expect(configPath).NotTo(BeEmpty())
+
+		var err error
+		c, err = config.NewConfig(configPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Validate()).To(Succeed())
+
+		cc = capi.NewClient(c)
+		routeGUID = helpers.RouteGUIDInDomain(cc, spaceGUID, shiftingDomain(c), shiftingReviewsRoute)
+		appGUIDs = map[string]string{}
+		for _, version := range reviewsVersions {
+			appGUIDs[version] = helpers.AppGUID(cc, spaceGUID, shiftingReviewsApp(version))
+		}
+		productURL = fmt.Sprintf("%s://%s.%s/productpage?u=normal", appScheme, shiftingProductPageApp, c.IstioDomain)
+	})
+
+	It("shifts reviews traffic between versions according to the route weights", func() {
+		confidence := weightedRoutingConfidence(c)
+		for _, weights := range []map[string]int{
+			{"v1": 100, "v2": 0, "v3": 0},
+			{"v1": 50, "v2": 50, "v3": 0},
+			{"v1": 0, "v2": 50, "v3": 50},
+			{"v1": 0, "v2": 0, "v3": 100},
+		} {
+			By(fmt.Sprintf("weighting reviews v1/v2/v3 at %d/%d/%d", weights["v1"], weights["v2"], weights["v3"]))
+			appGUIDToWeights := map[string]int{}
+			for version, weight := range weights {
+				if weight > 0 {
+					appGUIDToWeights[appGUIDs[version]] = weight
+				}
+			}
+			helpers.AddWeightedDestinations(cc, routeGUID, appGUIDToWeights)
+
+			// The distribution is only tested once, on a single sample taken
+			// after the new weights have propagated, so that retries do not
+			// lower the confidence level.
+			Eventually(func() map[string]int {
+				return unweightedReviewsVersions(productURL, weights)
+			}, convergenceTimeout, time.Second).Should(BeEmpty())
+
+			observed := observeReviewsVersions(productURL, weights, confidence)
+			Expect(observed).To(matchers.HaveWeightedDistribution(weights).WithConfidence(confidence))
+		}
+	})
+})
+
+// pushTrafficShifting deploys the apps of the traffic shifting scenario. It
+// reuses the details and ratings apps of the main deployment.
+func pushTrafficShifting(c config.Config) {
+	domain := shiftingDomain(c)
+	images := c.GetReviewsDockerImages()
+
+	for _, version := range reviewsVersions {
+		app := shiftingReviewsApp(version)
+		Expect(cf.Cf("push", app, "-o", images[version], "--no-route", "-u", "none").Wait(pushTimeout)).To(Exit(0))
+		Expect(cf.Cf("set-env", app, "SERVICES_DOMAIN", domain).Wait(cfTimeout)).To(Exit(0))
+		Expect(cf.Cf("restage", app).Wait(pushTimeout)).To(Exit(0))
+	}
+
+	Expect(cf.Cf("push", shiftingProductPageApp, "-o", c.ProductPageDockerWithTag, "-d", c.IstioDomain).Wait(pushTimeout)).To(Exit(0))
+	Expect(cf.Cf("set-env", shiftingProductPageApp, "SERVICES_DOMAIN", domain).Wait(cfTimeout)).To(Exit(0))
+	Expect(cf.Cf("restage", shiftingProductPageApp).Wait(pushTimeout)).To(Exit(0))
+
+	Expect(cf.Cf("map-route", "details", domain, "--hostname", "details").Wait(cfTimeout)).To(Exit(0))
+	Expect(cf.Cf("map-route", "ratings", domain, "--hostname", "ratings").Wait(cfTimeout)).To(Exit(0))
+	Expect(cf.Cf("create-route", TestSetup.TestSpace.SpaceName(), domain, "--hostname", shiftingReviewsRoute).Wait(cfTimeout)).To(Exit(0))
+}
+
+// addTrafficShiftingPolicies allows the traffic shifting apps to reach the
+// services they call. It needs to run as admin.
+func addTrafficShiftingPolicies() {
+	Expect(cf.Cf("add-network-policy", shiftingProductPageApp, "--destination-app", "details", "--protocol", "tcp", "--port", "9080").Wait(cfTimeout)).To(Exit(0))
+	for _, version := range reviewsVersions {
+		app := shiftingReviewsApp(version)
+		Expect(cf.Cf("add-network-policy", shiftingProductPageApp, "--destination-app", app, "--protocol", "tcp", "--port", "9080").Wait(cfTimeout)).To(Exit(0))
+		Expect(cf.Cf("add-network-policy", app, "--destination-app", "ratings", "--protocol", "tcp", "--port", "9080").Wait(cfTimeout)).To(Exit(0))
+	}
+}
+
+// unweightedReviewsVersions loads the product page settledPages times and
+// counts the pages that show anything but a reviews version with a weight,
+// such as a version the previous weights sent traffic to.
+func unweightedReviewsVersions(productURL string, weights map[string]int) map[string]int {
+	page := newPage()
+	defer page.Destroy()
+
+	unweighted := map[string]int{}
+	for i := 0; i < settledPages; i++ {
+		version := "error"
+		if err := page.Navigate(productURL); err == nil {
+			if html, err := page.HTML(); err == nil {
+				version = reviewsVersion(html)
+			}
+		}
+		if weights[version] <= 0 {
+			unweighted[version]++
+		}
+	}
+	return unweighted
+}
+
+// observeReviewsVersions loads the product page enough times to test the
+// given weights, and counts the pages per reviews version from how they show
+// ratings: v1 shows no stars, v2 black stars and v3 red stars. Pages without
+// reviews are counted under the text they show instead.
+func observeReviewsVersions(productURL string, weights map[string]int, confidence float64) map[string]int {
+	samples := matchers.SampleSize(weights, confidence)
+	if samples < minShiftingSamples {
+		samples = minShiftingSamples
+	}
+
+	page := newPage()
+	defer page.Destroy()
+
+	observed := map[string]int{}
+	for i := 0; i < samples; i++ {
+		if err := page.Navigate(productURL); err != nil {
+			observed["error"]++
+			continue
+		}
+		html, err := page.HTML()
+		if err != nil {
+			observed["error"]++
+			continue
+		}
+		observed[reviewsVersion(html)]++
+	}
+	return observed
+}
+
+func reviewsVersion(html string) string {
+	switch {
+	case !strings.Contains(html, "An extremely entertaining play by Shakespeare."):
+		return "no reviews"
+	case strings.Contains(html, `font color="red"`):
+		return "v3"
+	case strings.Contains(html, `font color="black"`):
+		return "v2"
+	case !strings.Contains(html, "glyphicon-star"):
+		return "v1"
+	default:
+		return "unknown stars"
+	}
+}
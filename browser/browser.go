@@ -0,0 +1,69 @@
+// Package browser drives the pages of the apps under test, either with a
+// real browser through agouti or with plain HTTP requests.
+package browser
+
+import (
+	"github.com/sclevine/agouti"
+)
+
+// Browser is the subset of page interactions the suites need.
+type Browser interface {
+	// Navigate loads url. It does not fail on error responses.
+	Navigate(url string) error
+	// Refresh loads the current page again.
+	Refresh() error
+	// HTML returns the source of the current page.
+	HTML() (string, error)
+	// Title returns the title of the current page.
+	Title() (string, error)
+	// Text returns the text of the first element matching the tag name.
+	Text(tag string) (string, error)
+	// HasLink is whether the current page has a link with the given text.
+	HasLink(text string) (bool, error)
+	// ClickLink follows the first link with the given text.
+	ClickLink(text string) error
+	// Destroy releases the page.
+	Destroy() error
+}
+
+type agoutiBrowser struct {
+	page *agouti.Page
+}
+
+// NewAgouti returns a Browser backed by an agouti page.
+func NewAgouti(page *agouti.Page) Browser {
+	return &agoutiBrowser{page: page}
+}
+
+func (b *agoutiBrowser) Navigate(url string) error {
+	return b.page.Navigate(url)
+}
+
+func (b *agoutiBrowser) Refresh() error {
+	return b.page.Refresh()
+}
+
+func (b *agoutiBrowser) HTML() (string, error) {
+	return b.page.HTML()
+}
+
+func (b *agoutiBrowser) Title() (string, error) {
+	return b.page.Title()
+}
+
+func (b *agoutiBrowser) Text(tag string) (string, error) {
+	return b.page.First(tag).Text()
+}
+
+func (b *agoutiBrowser) HasLink(text string) (bool, error) {
+	count, err := b.page.AllByLink(text).Count()
+	return count > 0, err
+}
+
+func (b *agoutiBrowser) ClickLink(text string) error {
+	return b.page.FirstByLink(text).Click()
+}
+
+func (b *agoutiBrowser) Destroy() error {
+	return b.page.Destroy()
+}
@@ -0,0 +1,164 @@
+package browser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ErrNoPage is returned when a page is inspected before one is loaded.
+var ErrNoPage = errors.New("no page has been loaded")
+
+type httpBrowser struct {
+	client *http.Client
+	url    *url.URL
+	source string
+	root   *html.Node
+}
+
+// NewHTTP returns a Browser that fetches pages with client and parses their
+// HTML. It does not run scripts or load assets.
+func NewHTTP(client *http.Client) Browser {
+	return &httpBrowser{client: client}
+}
+
+func (b *httpBrowser) Navigate(pageURL string) error {
+	location, err := url.Parse(pageURL)
+	if err != nil {
+		return err
+	}
+
+	res, err := b.client.Get(location.String())
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	root, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("parsing %s: %s", location, err)
+	}
+
+	b.url = res.Request.URL
+	b.source = string(body)
+	b.root = root
+	return nil
+}
+
+func (b *httpBrowser) Refresh() error {
+	if b.url == nil {
+		return ErrNoPage
+	}
+	return b.Navigate(b.url.String())
+}
+
+func (b *httpBrowser) HTML() (string, error) {
+	if b.root == nil {
+		return "", ErrNoPage
+	}
+	return b.source, nil
+}
+
+func (b *httpBrowser) Title() (string, error) {
+	return b.Text("title")
+}
+
+func (b *httpBrowser) Text(tag string) (string, error) {
+	if b.root == nil {
+		return "", ErrNoPage
+	}
+
+	node := find(b.root, func(n *html.Node) bool { return n.Type == html.ElementNode && n.Data == tag })
+	if node == nil {
+		return "", fmt.Errorf("no %s element on %s", tag, b.url)
+	}
+	return text(node), nil
+}
+
+func (b *httpBrowser) HasLink(linkText string) (bool, error) {
+	if b.root == nil {
+		return false, ErrNoPage
+	}
+	return b.link(linkText) != nil, nil
+}
+
+func (b *httpBrowser) ClickLink(linkText string) error {
+	if b.root == nil {
+		return ErrNoPage
+	}
+
+	link := b.link(linkText)
+	if link == nil {
+		return fmt.Errorf("no link %q on %s", linkText, b.url)
+	}
+	href, err := url.Parse(attribute(link, "href"))
+	if err != nil {
+		return err
+	}
+	return b.Navigate(b.url.ResolveReference(href).String())
+}
+
+func (b *httpBrowser) Destroy() error {
+	b.url = nil
+	b.source = ""
+	b.root = nil
+	return nil
+}
+
+func (b *httpBrowser) link(linkText string) *html.Node {
+	return find(b.root, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && n.Data == "a" && text(n) == linkText
+	})
+}
+
+// find returns the first node, in document order, that matches.
+func find(node *html.Node, matches func(*html.Node) bool) *html.Node {
+	if matches(node) {
+		return node
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if found := find(child, matches); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// text returns the text within node with whitespace collapsed, as a browser
+// would render it.
+func text(node *html.Node) string {
+	var buf bytes.Buffer
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style") {
+			return
+		}
+		if n.Type == html.TextNode {
+			buf.WriteString(n.Data)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collect(child)
+		}
+	}
+	collect(node)
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+func attribute(node *html.Node, name string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package browser_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/browser"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const indexPage = `<!DOCTYPE html>
+<html>
+  <head>
+    <title>Simple Bookstore App</title>
+    <script>var ignored = "text";</script>
+  </head>
+  <body>
+    <h3>Hello!   This is a
+      <b>simple</b> bookstore</h3>
+    <a href="productpage?u=normal">Normal user</a>
+  </body>
+</html>`
+
+const productPage = `<html><head><title>Product</title></head><body><h1>The Comedy of Errors</h1></body></html>`
+
+var _ = Describe("HTTP browser", func() {
+	var (
+		server   *httptest.Server
+		requests []string
+		b        browser.Browser
+	)
+
+	BeforeEach(func() {
+		requests = []string{}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/index", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, indexPage)
+		})
+		mux.HandleFunc("/productpage", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, productPage)
+		})
+		mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<p>not found</p>")
+		})
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.URL.RequestURI())
+			mux.ServeHTTP(w, r)
+		}))
+
+		b = browser.NewHTTP(http.DefaultClient)
+	})
+
+	AfterEach(func() {
+		Expect(b.Destroy()).To(Succeed())
+		server.Close()
+	})
+
+	It("returns the page source", func() {
+		Expect(b.Navigate(server.URL + "/index")).To(Succeed())
+		Expect(b.HTML()).To(Equal(indexPage))
+	})
+
+	It("returns element text as rendered, without scripts", func() {
+		Expect(b.Navigate(server.URL + "/index")).To(Succeed())
+		Expect(b.Title()).To(Equal("Simple Bookstore App"))
+		Expect(b.Text("h3")).To(Equal("Hello! This is a simple bookstore"))
+
+		_, err := b.Text("h1")
+		Expect(err).To(MatchError(ContainSubstring("no h1 element")))
+	})
+
+	It("follows relative links", func() {
+		Expect(b.Navigate(server.URL + "/index")).To(Succeed())
+		Expect(b.HasLink("Normal user")).To(BeTrue())
+		Expect(b.HasLink("Test user")).To(BeFalse())
+
+		Expect(b.ClickLink("Normal user")).To(Succeed())
+		Expect(b.Title()).To(Equal("Product"))
+		Expect(b.Text("h1")).To(Equal("The Comedy of Errors"))
+
+		Expect(b.ClickLink("Normal user")).To(MatchError(ContainSubstring(`no link "Normal user"`)))
+	})
+
+	It("reloads the current page on refresh", func() {
+		Expect(b.Navigate(server.URL + "/index")).To(Succeed())
+		Expect(b.ClickLink("Normal user")).To(Succeed())
+		Expect(b.Refresh()).To(Succeed())
+
+		Expect(requests).To(Equal([]string{"/index", "/productpage?u=normal", "/productpage?u=normal"}))
+	})
+
+	It("loads error pages like a browser would", func() {
+		Expect(b.Navigate(server.URL + "/missing")).To(Succeed())
+		Expect(b.Text("p")).To(Equal("not found"))
+	})
+
+	It("fails to inspect a page before one is loaded", func() {
+		_, err := b.HTML()
+		Expect(err).To(Equal(browser.ErrNoPage))
+		Expect(b.Refresh()).To(Equal(browser.ErrNoPage))
+	})
+})
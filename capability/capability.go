@@ -0,0 +1,262 @@
+// Package capability decides which optional features of the foundation the
+// suites exercise. Each feature is declared in the config, detected against
+// the foundation, or assumed, and the specs needing an unavailable feature
+// skip themselves.
+package capability
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"code.cloudfoundry.org/istio-acceptance-tests/httpclient"
+)
+
+type Name string
+
+const (
+	InternalRoutes  Name = "internal_routes"
+	SidecarProxying Name = "sidecar_proxying"
+	TLSTermination  Name = "tls_termination"
+	WeightedRouting Name = "weighted_routing"
+	Docker          Name = "docker"
+)
+
+// All lists every capability, in the order summaries show them.
+var All = []Name{InternalRoutes, SidecarProxying, TLSTermination, WeightedRouting, Docker}
+
+// Source is how a capability was decided on.
+type Source string
+
+const (
+	Declared Source = "declared"
+	Detected Source = "detected"
+	Assumed  Source = "assumed"
+)
+
+type Capability struct {
+	Name      Name
+	Available bool
+	Source    Source
+	// Reason explains a detected or assumed capability.
+	Reason string
+}
+
+func (c Capability) String() string {
+	if c.Reason == "" {
+		return fmt.Sprintf("%s (%s)", c.Name, c.Source)
+	}
+	return fmt.Sprintf("%s (%s: %s)", c.Name, c.Source, c.Reason)
+}
+
+// Set holds the decision on every capability.
+type Set map[Name]Capability
+
+func (s Set) Available(name Name) bool {
+	return s[name].Available
+}
+
+// Require returns an error describing each of names that is unavailable,
+// or nil when they all are available.
+func (s Set) Require(names ...Name) error {
+	missing := []string{}
+	for _, name := range names {
+		if !s.Available(name) {
+			missing = append(missing, s[name].String())
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("the foundation lacks %s", strings.Join(missing, ", "))
+}
+
+// Summary lists the capabilities, and whether the specs needing them were
+// exercised or skipped.
+func (s Set) Summary() string {
+	lines := []string{"Capabilities:"}
+	for _, name := range All {
+		capability, ok := s[name]
+		if !ok {
+			continue
+		}
+		outcome := "skipped"
+		if capability.Available {
+			outcome = "exercised"
+		}
+		detail := string(capability.Source)
+		if capability.Reason != "" {
+			detail += ": " + capability.Reason
+		}
+		lines = append(lines, fmt.Sprintf("  %-17s %-9s (%s)", name, outcome, detail))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// errUndetectable is returned for capabilities that cannot be detected
+// without pushing apps.
+var errUndetectable = errors.New("it cannot be detected")
+
+// Detector decides on capabilities for the foundation in Config.
+type Detector struct {
+	Config          config.Config
+	CloudController *capi.Client
+	// TLSAddress is where TLS termination is probed, by default port 443
+	// of a host on the istio domain.
+	TLSAddress string
+}
+
+func NewDetector(c config.Config, cc *capi.Client) Detector {
+	return Detector{
+		Config:          c,
+		CloudController: cc,
+		TLSAddress:      net.JoinHostPort("iats-capability-probe."+c.IstioDomain, "443"),
+	}
+}
+
+// Resolve decides on every capability. Declared capabilities are taken as
+// they are. The others are detected when auto_detect is set, and assumed
+// when they are not or cannot be detected.
+func (d Detector) Resolve() Set {
+	capabilities := d.Config.GetCapabilities()
+	declared := map[Name]*bool{
+		InternalRoutes:  capabilities.InternalRoutes,
+		SidecarProxying: capabilities.SidecarProxying,
+		TLSTermination:  capabilities.TLSTermination,
+		WeightedRouting: capabilities.WeightedRouting,
+		Docker:          capabilities.Docker,
+	}
+
+	set := Set{}
+	for _, name := range All {
+		if declared[name] != nil {
+			set[name] = Capability{Name: name, Available: *declared[name], Source: Declared}
+			continue
+		}
+
+		var detectErr error
+		if capabilities.AutoDetect {
+			available, reason, err := d.detect(name)
+			if err == nil {
+				set[name] = Capability{Name: name, Available: available, Source: Detected, Reason: reason}
+				continue
+			}
+			detectErr = err
+		}
+
+		available, reason := d.assume(name)
+		if detectErr != nil {
+			reason = fmt.Sprintf("%s, and detecting it failed: %s", reason, detectErr)
+		}
+		set[name] = Capability{Name: name, Available: available, Source: Assumed, Reason: reason}
+	}
+	return set
+}
+
+// assume decides on a capability that was neither declared nor detected.
+// Everything the suites have always tested is assumed to be available, and
+// TLS termination to be available when there is a wildcard CA to test it
+// with.
+func (d Detector) assume(name Name) (bool, string) {
+	if name == TLSTermination {
+		if d.Config.WildcardCa == "" {
+			return false, "no wildcard_ca is set"
+		}
+		return true, "wildcard_ca is set"
+	}
+	return true, "available by default"
+}
+
+func (d Detector) detect(name Name) (bool, string, error) {
+	switch name {
+	case InternalRoutes:
+		return d.detectInternalRoutes()
+	case TLSTermination:
+		return d.detectTLSTermination()
+	case WeightedRouting:
+		return d.detectWeightedRouting()
+	case Docker:
+		return d.detectDocker()
+	default:
+		return false, "", errUndetectable
+	}
+}
+
+func (d Detector) detectInternalRoutes() (bool, string, error) {
+	name := d.Config.CFInternalIstioDomain
+	if name == "" {
+		name = config.DefaultInternalIstioDomain
+	}
+
+	domain, err := d.CloudController.DomainByName(name)
+	if _, ok := err.(*capi.NotFoundError); ok {
+		return false, fmt.Sprintf("there is no %s domain", name), nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+	if !domain.Internal {
+		return false, fmt.Sprintf("%s is not an internal domain", name), nil
+	}
+	return true, fmt.Sprintf("%s is an internal domain", name), nil
+}
+
+// detectTLSTermination completes a TLS handshake with the istio routers,
+// with the TLS settings the suites use.
+func (d Detector) detectTLSTermination() (bool, string, error) {
+	tlsConfig, err := httpclient.TLSConfig(d.Config)
+	if err != nil {
+		return false, "", err
+	}
+	if host, _, err := net.SplitHostPort(d.TLSAddress); err == nil {
+		tlsConfig.ServerName = host
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", d.TLSAddress, tlsConfig)
+	if err != nil {
+		return false, fmt.Sprintf("no TLS handshake with %s: %s", d.TLSAddress, err), nil
+	}
+	conn.Close()
+	return true, fmt.Sprintf("TLS handshake with %s", d.TLSAddress), nil
+}
+
+// detectWeightedRouting asks for the destinations of a route that does not
+// exist. A Cloud Controller with the destinations API answers that the
+// route is not found, one without it that the request is unknown.
+func (d Detector) detectWeightedRouting() (bool, string, error) {
+	_, err := d.CloudController.Destinations("iats-capability-probe")
+	errResp, ok := err.(*capi.ErrorResponse)
+	if err == nil || ok && errResp.StatusCode == 404 && hasError(errResp, "CF-ResourceNotFound") {
+		return true, "the Cloud Controller has the route destinations API", nil
+	}
+	if ok && errResp.StatusCode == 404 {
+		return false, "the Cloud Controller has no route destinations API", nil
+	}
+	return false, "", err
+}
+
+func (d Detector) detectDocker() (bool, string, error) {
+	flag, err := d.CloudController.FeatureFlag("diego_docker")
+	if err != nil {
+		return false, "", err
+	}
+	if !flag.Enabled {
+		return false, "the diego_docker feature flag is disabled", nil
+	}
+	return true, "the diego_docker feature flag is enabled", nil
+}
+
+func hasError(errResp *capi.ErrorResponse, title string) bool {
+	for _, e := range errResp.Errors {
+		if e.Title == title {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package capability_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capability"
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"code.cloudfoundry.org/istio-acceptance-tests/fakecapi"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Capabilities", func() {
+	var (
+		server    *fakecapi.Server
+		tlsServer *httptest.Server
+		c         config.Config
+		yes, no   = true, false
+	)
+
+	BeforeEach(func() {
+		server = fakecapi.NewServer()
+		tlsServer = httptest.NewTLSServer(http.NotFoundHandler())
+		c = config.Config{
+			CFApi:         server.URL(),
+			AdminUser:     "admin",
+			AdminPassword: "secret",
+			IstioDomain:   "istio.example.com",
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		tlsServer.Close()
+	})
+
+	resolve := func() capability.Set {
+		detector := capability.NewDetector(c, capi.NewClient(c))
+		detector.TLSAddress = tlsServer.Listener.Addr().String()
+		return detector.Resolve()
+	}
+
+	It("takes declared capabilities as they are", func() {
+		c.Capabilities = config.Capabilities{AutoDetect: true, WeightedRouting: &no, Docker: &yes}
+		server.SetFeatureFlag("diego_docker", false)
+
+		capabilities := resolve()
+		Expect(capabilities[capability.WeightedRouting]).To(Equal(capability.Capability{Name: capability.WeightedRouting, Available: false, Source: capability.Declared}))
+		Expect(capabilities.Available(capability.Docker)).To(BeTrue())
+	})
+
+	It("lets include_internal_route_tests stand in for internal routes and sidecar proxying", func() {
+		c.IncludeInternalRouteTests = &no
+
+		capabilities := resolve()
+		Expect(capabilities.Available(capability.InternalRoutes)).To(BeFalse())
+		Expect(capabilities.Available(capability.SidecarProxying)).To(BeFalse())
+		Expect(capabilities[capability.SidecarProxying].Source).To(Equal(capability.Declared))
+	})
+
+	Context("without auto detection", func() {
+		It("assumes the capabilities the suites have always tested", func() {
+			capabilities := resolve()
+			for _, name := range []capability.Name{capability.InternalRoutes, capability.SidecarProxying, capability.WeightedRouting, capability.Docker} {
+				Expect(capabilities.Available(name)).To(BeTrue())
+				Expect(capabilities[name].Source).To(Equal(capability.Assumed))
+			}
+			Expect(server.Requests()).To(BeEmpty())
+		})
+
+		It("assumes TLS termination only when there is a wildcard CA", func() {
+			Expect(resolve().Available(capability.TLSTermination)).To(BeFalse())
+
+			c.WildcardCa = "some-ca"
+			Expect(resolve().Available(capability.TLSTermination)).To(BeTrue())
+		})
+	})
+
+	Context("with auto detection", func() {
+		BeforeEach(func() {
+			c.Capabilities.AutoDetect = true
+			server.SetFeatureFlag("diego_docker", true)
+			server.AddDomain(config.DefaultInternalIstioDomain, true)
+		})
+
+		It("detects the capabilities of the foundation", func() {
+			capabilities := resolve()
+			for _, name := range []capability.Name{capability.InternalRoutes, capability.TLSTermination, capability.WeightedRouting, capability.Docker} {
+				Expect(capabilities.Available(name)).To(BeTrue(), string(name))
+				Expect(capabilities[name].Source).To(Equal(capability.Detected))
+			}
+		})
+
+		It("detects missing capabilities", func() {
+			server.SetFeatureFlag("diego_docker", false)
+			server.NoDestinations = true
+			c.CFInternalIstioDomain = "mesh.apps.internal"
+			tlsServer.Close()
+
+			capabilities := resolve()
+			Expect(capabilities.Available(capability.Docker)).To(BeFalse())
+			Expect(capabilities[capability.WeightedRouting].Reason).To(Equal("the Cloud Controller has no route destinations API"))
+			Expect(capabilities[capability.InternalRoutes].Reason).To(Equal("there is no mesh.apps.internal domain"))
+			Expect(capabilities[capability.TLSTermination].Source).To(Equal(capability.Detected))
+			Expect(capabilities.Available(capability.TLSTermination)).To(BeFalse())
+		})
+
+		It("falls back to assuming capabilities it cannot detect", func() {
+			server.FailNext("/v3/feature_flags/diego_docker", 1)
+
+			capabilities := resolve()
+			Expect(capabilities[capability.SidecarProxying].Source).To(Equal(capability.Assumed))
+			Expect(capabilities[capability.Docker].Source).To(Equal(capability.Assumed))
+			Expect(capabilities[capability.Docker].Reason).To(ContainSubstring("detecting it failed"))
+			Expect(capabilities.Available(capability.Docker)).To(BeTrue())
+		})
+	})
+
+	It("names the missing capabilities a spec requires", func() {
+		c.Capabilities = config.Capabilities{InternalRoutes: &no}
+		capabilities := resolve()
+
+		Expect(capabilities.Require(capability.WeightedRouting)).To(Succeed())
+		Expect(capabilities.Require(capability.WeightedRouting, capability.InternalRoutes)).To(MatchError("the foundation lacks internal_routes (declared)"))
+		Expect(capabilities.Summary()).To(ContainSubstring("internal_routes   skipped   (declared)"))
+		Expect(capabilities.Summary()).To(ContainSubstring("weighted_routing  exercised (assumed: available by default)"))
+	})
+})
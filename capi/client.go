@@ -0,0 +1,575 @@
+package capi
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+)
+
+type Client struct {
+	apiURL     string
+	username   string
+	password   string
+	httpClient *http.Client
+
+	tokenLock   sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+type Error struct {
+	Code   int    `json:"code"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+}
+
+type ErrorResponse struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Errors     []Error `json:"errors"`
+}
+
+func (e *ErrorResponse) Error() string {
+	details := []string{}
+	for _, err := range e.Errors {
+		details = append(details, fmt.Sprintf("%s: %s", err.Title, err.Detail))
+	}
+	if len(details) == 0 {
+		return fmt.Sprintf("%s %s failed with status %d", e.Method, e.Path, e.StatusCode)
+	}
+	return fmt.Sprintf("%s %s failed with status %d: %s", e.Method, e.Path, e.StatusCode, strings.Join(details, "; "))
+}
+
+type NotFoundError struct {
+	Resource string
+	Name     string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %q not found", e.Resource, e.Name)
+}
+
+type AmbiguousError struct {
+	Resource string
+	Name     string
+	Count    int
+}
+
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("found %d %ss named %q, expected exactly one", e.Count, e.Resource, e.Name)
+}
+
+// NewClient returns a Cloud Controller client for the API endpoint in c that
+// authenticates as the configured admin user.
+func NewClient(c config.Config) *Client {
+	apiURL := c.GetApiEndpoint()
+	if !strings.HasPrefix(apiURL, "http://") && !strings.HasPrefix(apiURL, "https://") {
+		apiURL = "https://" + apiURL
+	}
+
+	return &Client{
+		apiURL:   strings.TrimSuffix(apiURL, "/"),
+		username: c.GetAdminUser(),
+		password: c.GetAdminPassword(),
+		httpClient: &http.Client{
+			Timeout: c.GetCFAPITimeout(),
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: c.GetSkipSSLValidation()},
+			},
+		},
+	}
+}
+
+func (c *Client) Apps(query url.Values) ([]App, error) {
+	apps := []App{}
+	err := c.list("/v3/apps", query, func(page json.RawMessage) error {
+		var resources []App
+		if err := json.Unmarshal(page, &resources); err != nil {
+			return err
+		}
+		apps = append(apps, resources...)
+		return nil
+	})
+	return apps, err
+}
+
+func (c *Client) AppByName(name, spaceGUID string) (App, error) {
+	apps, err := c.Apps(url.Values{"names": {name}, "space_guids": {spaceGUID}})
+	if err != nil {
+		return App{}, err
+	}
+	switch len(apps) {
+	case 0:
+		return App{}, &NotFoundError{Resource: "app", Name: name}
+	case 1:
+		return apps[0], nil
+	default:
+		return App{}, &AmbiguousError{Resource: "app", Name: name, Count: len(apps)}
+	}
+}
+
+// DeleteApp deletes an app. The Cloud Controller finishes deleting it, and
+// unmapping its routes, asynchronously.
+func (c *Client) DeleteApp(appGUID string) error {
+	return c.do("DELETE", fmt.Sprintf("/v3/apps/%s", appGUID), nil, nil)
+}
+
+func (c *Client) StartApp(appGUID string) (App, error) {
+	var app App
+	err := c.do("POST", fmt.Sprintf("/v3/apps/%s/actions/start", appGUID), nil, &app)
+	return app, err
+}
+
+func (c *Client) StopApp(appGUID string) (App, error) {
+	var app App
+	err := c.do("POST", fmt.Sprintf("/v3/apps/%s/actions/stop", appGUID), nil, &app)
+	return app, err
+}
+
+// SetProcessCommand overrides the start command of one of the processes of
+// an app. An empty command reverts to the command detected when staging. The
+// new command only applies once the app is restarted.
+func (c *Client) SetProcessCommand(appGUID, processType, command string) error {
+	var cmd interface{}
+	if command != "" {
+		cmd = command
+	}
+	body := map[string]interface{}{"command": cmd}
+	return c.do("PATCH", fmt.Sprintf("/v3/apps/%s/processes/%s", appGUID, processType), body, nil)
+}
+
+func (c *Client) Organizations(query url.Values) ([]Organization, error) {
+	orgs := []Organization{}
+	err := c.list("/v3/organizations", query, func(page json.RawMessage) error {
+		var resources []Organization
+		if err := json.Unmarshal(page, &resources); err != nil {
+			return err
+		}
+		orgs = append(orgs, resources...)
+		return nil
+	})
+	return orgs, err
+}
+
+// DeleteOrganization deletes an organization along with its spaces and
+// everything in them. The Cloud Controller finishes deleting it
+// asynchronously.
+func (c *Client) DeleteOrganization(orgGUID string) error {
+	return c.do("DELETE", fmt.Sprintf("/v3/organizations/%s", orgGUID), nil, nil)
+}
+
+func (c *Client) Spaces(query url.Values) ([]Space, error) {
+	spaces := []Space{}
+	err := c.list("/v3/spaces", query, func(page json.RawMessage) error {
+		var resources []Space
+		if err := json.Unmarshal(page, &resources); err != nil {
+			return err
+		}
+		spaces = append(spaces, resources...)
+		return nil
+	})
+	return spaces, err
+}
+
+// OrganizationByName returns the organization with the given name, which is
+// unique across the foundation.
+func (c *Client) OrganizationByName(name string) (Organization, error) {
+	orgs, err := c.Organizations(url.Values{"names": {name}})
+	if err != nil {
+		return Organization{}, err
+	}
+	switch len(orgs) {
+	case 0:
+		return Organization{}, &NotFoundError{Resource: "organization", Name: name}
+	case 1:
+		return orgs[0], nil
+	default:
+		return Organization{}, &AmbiguousError{Resource: "organization", Name: name, Count: len(orgs)}
+	}
+}
+
+// SpaceByName returns the space with the given name in an organization.
+// Space names are only unique within an organization.
+func (c *Client) SpaceByName(orgGUID, name string) (Space, error) {
+	spaces, err := c.Spaces(url.Values{"organization_guids": {orgGUID}, "names": {name}})
+	if err != nil {
+		return Space{}, err
+	}
+	switch len(spaces) {
+	case 0:
+		return Space{}, &NotFoundError{Resource: "space", Name: name}
+	case 1:
+		return spaces[0], nil
+	default:
+		return Space{}, &AmbiguousError{Resource: "space", Name: name, Count: len(spaces)}
+	}
+}
+
+func (c *Client) Domains(query url.Values) ([]Domain, error) {
+	domains := []Domain{}
+	err := c.list("/v3/domains", query, func(page json.RawMessage) error {
+		var resources []Domain
+		if err := json.Unmarshal(page, &resources); err != nil {
+			return err
+		}
+		domains = append(domains, resources...)
+		return nil
+	})
+	return domains, err
+}
+
+// DomainByName returns the domain with the given name, which may match both
+// a shared and a private domain.
+func (c *Client) DomainByName(name string) (Domain, error) {
+	domains, err := c.Domains(url.Values{"names": {name}})
+	if err != nil {
+		return Domain{}, err
+	}
+	switch len(domains) {
+	case 0:
+		return Domain{}, &NotFoundError{Resource: "domain", Name: name}
+	case 1:
+		return domains[0], nil
+	default:
+		return Domain{}, &AmbiguousError{Resource: "domain", Name: name, Count: len(domains)}
+	}
+}
+
+func (c *Client) StackByName(name string) (Stack, error) {
+	stacks := []Stack{}
+	err := c.list("/v3/stacks", url.Values{"names": {name}}, func(page json.RawMessage) error {
+		var resources []Stack
+		if err := json.Unmarshal(page, &resources); err != nil {
+			return err
+		}
+		stacks = append(stacks, resources...)
+		return nil
+	})
+	if err != nil {
+		return Stack{}, err
+	}
+	switch len(stacks) {
+	case 0:
+		return Stack{}, &NotFoundError{Resource: "stack", Name: name}
+	case 1:
+		return stacks[0], nil
+	default:
+		return Stack{}, &AmbiguousError{Resource: "stack", Name: name, Count: len(stacks)}
+	}
+}
+
+// DeleteDomain deletes a domain. The Cloud Controller finishes deleting it
+// asynchronously.
+func (c *Client) DeleteDomain(domainGUID string) error {
+	return c.do("DELETE", fmt.Sprintf("/v3/domains/%s", domainGUID), nil, nil)
+}
+
+// CreateSharedDomain creates a domain that is shared with every organization.
+func (c *Client) CreateSharedDomain(name string, internal bool) (Domain, error) {
+	var domain Domain
+	body := map[string]interface{}{"name": name, "internal": internal}
+	err := c.do("POST", "/v3/domains", body, &domain)
+	return domain, err
+}
+
+func (c *Client) Routes(query url.Values) ([]Route, error) {
+	routes := []Route{}
+	err := c.list("/v3/routes", query, func(page json.RawMessage) error {
+		var resources []Route
+		if err := json.Unmarshal(page, &resources); err != nil {
+			return err
+		}
+		routes = append(routes, resources...)
+		return nil
+	})
+	return routes, err
+}
+
+// RouteByHost returns the route with the given hostname in the given space.
+// Routes with a context path are ignored.
+func (c *Client) RouteByHost(spaceGUID, host string) (Route, error) {
+	return c.routeByHost(url.Values{"hosts": {host}, "space_guids": {spaceGUID}}, host)
+}
+
+// RouteByHostInDomain is RouteByHost for spaces with routes of the same
+// hostname on several domains.
+func (c *Client) RouteByHostInDomain(spaceGUID, domainGUID, host string) (Route, error) {
+	return c.routeByHost(url.Values{"hosts": {host}, "space_guids": {spaceGUID}, "domain_guids": {domainGUID}}, host)
+}
+
+func (c *Client) routeByHost(query url.Values, host string) (Route, error) {
+	routes, err := c.Routes(query)
+	if err != nil {
+		return Route{}, err
+	}
+
+	matches := []Route{}
+	for _, route := range routes {
+		if route.Path == "" {
+			matches = append(matches, route)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return Route{}, &NotFoundError{Resource: "route", Name: host}
+	case 1:
+		return matches[0], nil
+	default:
+		return Route{}, &AmbiguousError{Resource: "route", Name: host, Count: len(matches)}
+	}
+}
+
+// DeleteRoute deletes a route. The Cloud Controller finishes deleting it
+// asynchronously.
+func (c *Client) DeleteRoute(routeGUID string) error {
+	return c.do("DELETE", fmt.Sprintf("/v3/routes/%s", routeGUID), nil, nil)
+}
+
+func (c *Client) Destinations(routeGUID string) ([]Destination, error) {
+	var resp struct {
+		Destinations []Destination `json:"destinations"`
+	}
+	err := c.do("GET", fmt.Sprintf("/v3/routes/%s/destinations", routeGUID), nil, &resp)
+	return resp.Destinations, err
+}
+
+// InsertDestinations adds destinations to a route, keeping the existing ones.
+func (c *Client) InsertDestinations(routeGUID string, destinations []Destination) ([]Destination, error) {
+	return c.writeDestinations("POST", routeGUID, destinations)
+}
+
+// ReplaceDestinations replaces all of the destinations of a route.
+func (c *Client) ReplaceDestinations(routeGUID string, destinations []Destination) ([]Destination, error) {
+	return c.writeDestinations("PATCH", routeGUID, destinations)
+}
+
+func (c *Client) writeDestinations(method, routeGUID string, destinations []Destination) ([]Destination, error) {
+	var resp struct {
+		Destinations []Destination `json:"destinations"`
+	}
+	body := map[string]interface{}{"destinations": destinations}
+	err := c.do(method, fmt.Sprintf("/v3/routes/%s/destinations", routeGUID), body, &resp)
+	return resp.Destinations, err
+}
+
+func (c *Client) FeatureFlag(name string) (FeatureFlag, error) {
+	var flag FeatureFlag
+	err := c.do("GET", fmt.Sprintf("/v3/feature_flags/%s", name), nil, &flag)
+	return flag, err
+}
+
+// Policies returns the network policies that have any of the given apps as
+// source or destination.
+func (c *Client) Policies(appGUIDs ...string) ([]Policy, error) {
+	var resp struct {
+		Policies []Policy `json:"policies"`
+	}
+	path := "/networking/v1/external/policies"
+	if len(appGUIDs) > 0 {
+		path += "?" + url.Values{"id": {strings.Join(appGUIDs, ",")}}.Encode()
+	}
+	err := c.do("GET", path, nil, &resp)
+	return resp.Policies, err
+}
+
+func (c *Client) CreatePolicies(policies []Policy) error {
+	body := map[string]interface{}{"policies": policies}
+	return c.do("POST", "/networking/v1/external/policies", body, nil)
+}
+
+func (c *Client) DeletePolicies(policies []Policy) error {
+	body := map[string]interface{}{"policies": policies}
+	return c.do("POST", "/networking/v1/external/policies/delete", body, nil)
+}
+
+func (c *Client) list(path string, query url.Values, appendPage func(json.RawMessage) error) error {
+	next := path
+	if len(query) > 0 {
+		next = path + "?" + query.Encode()
+	}
+
+	for next != "" {
+		var page struct {
+			Pagination struct {
+				Next *struct {
+					Href string `json:"href"`
+				} `json:"next"`
+			} `json:"pagination"`
+			Resources json.RawMessage `json:"resources"`
+		}
+		if err := c.do("GET", next, nil, &page); err != nil {
+			return err
+		}
+		if err := appendPage(page.Resources); err != nil {
+			return err
+		}
+
+		next = ""
+		if page.Pagination.Next != nil {
+			next = page.Pagination.Next.Href
+		}
+	}
+	return nil
+}
+
+// do sends an authenticated request to the Cloud Controller. path may either
+// be relative to the API endpoint or an absolute URL, as returned in
+// pagination links.
+func (c *Client) do(method, path string, body interface{}, result interface{}) error {
+	token, err := c.accessToken()
+	if err != nil {
+		return err
+	}
+
+	requestURL := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		requestURL = c.apiURL + path
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, requestURL, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 400 {
+		errResp := &ErrorResponse{Method: method, Path: req.URL.Path, StatusCode: resp.StatusCode}
+		json.Unmarshal(respBody, errResp)
+		return errResp
+	}
+
+	if result == nil || len(respBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return fmt.Errorf("decoding response from %s %s: %s", method, req.URL.Path, err)
+	}
+	return nil
+}
+
+func (c *Client) accessToken() (string, error) {
+	c.tokenLock.Lock()
+	defer c.tokenLock.Unlock()
+
+	if c.token != "" && time.Now().Before(c.tokenExpiry) {
+		return c.token, nil
+	}
+
+	tokenEndpoint, err := c.tokenEndpoint()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"password"},
+		"username":   {c.username},
+		"password":   {c.password},
+	}
+	req, err := http.NewRequest("POST", tokenEndpoint+"/oauth/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth("cf", "")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("authenticating as %s failed with status %d", c.username, resp.StatusCode)
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", err
+	}
+
+	c.token = tokenResp.AccessToken
+	// refresh a little before the token actually expires, by no more than
+	// half its lifetime so that short-lived tokens are still reused
+	lifetime := time.Duration(tokenResp.ExpiresIn) * time.Second
+	margin := time.Minute
+	if lifetime/2 < margin {
+		margin = lifetime / 2
+	}
+	c.tokenExpiry = time.Now().Add(lifetime - margin)
+	return c.token, nil
+}
+
+// Info fetches /v2/info, which needs no authentication.
+func (c *Client) Info() (Info, error) {
+	var info Info
+	resp, err := c.httpClient.Get(c.apiURL + "/v2/info")
+	if err != nil {
+		return info, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return info, fmt.Errorf("GET /v2/info failed with status %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return info, fmt.Errorf("decoding response from GET /v2/info: %s", err)
+	}
+	return info, nil
+}
+
+// Login authenticates as the admin user, unless the client already holds a
+// valid access token.
+func (c *Client) Login() error {
+	_, err := c.accessToken()
+	return err
+}
+
+func (c *Client) tokenEndpoint() (string, error) {
+	info, err := c.Info()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(info.TokenEndpoint, "/"), nil
+}
@@ -0,0 +1,383 @@
+package capi_test
+
+import (
+	"net/url"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"code.cloudfoundry.org/istio-acceptance-tests/fakecapi"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		server *fakecapi.Server
+		client *capi.Client
+	)
+
+	BeforeEach(func() {
+		server = fakecapi.NewServer()
+		client = capi.NewClient(config.Config{
+			CFApi:         server.URL(),
+			AdminUser:     "admin",
+			AdminPassword: "secret",
+		})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("authenticates as the admin user before calling the API", func() {
+		server.AddDomain("istio.example.com", false)
+
+		_, err := client.DomainByName("istio.example.com")
+		Expect(err).NotTo(HaveOccurred())
+
+		requests := server.Requests()
+		Expect(requests).To(HaveLen(3))
+		Expect(requests[0].Path).To(Equal("/v2/info"))
+		Expect(requests[1].Path).To(Equal("/oauth/token"))
+		Expect(requests[1].Body).To(ContainSubstring("username=admin"))
+		Expect(requests[1].Body).To(ContainSubstring("password=secret"))
+		Expect(requests[2].Path).To(Equal("/v3/domains"))
+	})
+
+	It("reuses the access token across requests", func() {
+		_, err := client.Domains(nil)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.Domains(nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		tokenRequests := 0
+		for _, request := range server.Requests() {
+			if request.Path == "/oauth/token" {
+				tokenRequests++
+			}
+		}
+		Expect(tokenRequests).To(Equal(1))
+	})
+
+	It("reuses access tokens that expire in under a minute", func() {
+		server.TokenExpiresIn = 30
+
+		_, err := client.Domains(nil)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.Domains(nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		tokenRequests := 0
+		for _, request := range server.Requests() {
+			if request.Path == "/oauth/token" {
+				tokenRequests++
+			}
+		}
+		Expect(tokenRequests).To(Equal(1))
+	})
+
+	It("follows pagination links", func() {
+		server.PerPage = 2
+		server.AddDomain("one.example.com", false)
+		server.AddDomain("two.example.com", false)
+		server.AddDomain("three.example.com", true)
+
+		domains, err := client.Domains(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(domains).To(HaveLen(3))
+		Expect(domains[2].Name).To(Equal("three.example.com"))
+		Expect(domains[2].Internal).To(BeTrue())
+	})
+
+	Describe("AppByName", func() {
+		It("only returns apps in the given space", func() {
+			spaceOne := server.AddSpace("space-one")
+			spaceTwo := server.AddSpace("space-two")
+			server.AddApp("app", spaceOne.GUID)
+			appTwo := server.AddApp("app", spaceTwo.GUID)
+
+			app, err := client.AppByName("app", spaceTwo.GUID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(app.GUID).To(Equal(appTwo.GUID))
+		})
+
+		It("returns a NotFoundError when there is no such app", func() {
+			space := server.AddSpace("space")
+
+			_, err := client.AppByName("missing", space.GUID)
+			Expect(err).To(BeAssignableToTypeOf(&capi.NotFoundError{}))
+			Expect(err).To(MatchError(`app "missing" not found`))
+		})
+
+		It("returns an AmbiguousError when several apps in the space share the name", func() {
+			space := server.AddSpace("space")
+			server.AddApp("app", space.GUID)
+			server.AddApp("app", space.GUID)
+
+			_, err := client.AppByName("app", space.GUID)
+			Expect(err).To(BeAssignableToTypeOf(&capi.AmbiguousError{}))
+		})
+	})
+
+	Describe("StackByName", func() {
+		It("returns the stack with the name", func() {
+			server.AddStack("cflinuxfs2")
+			stack := server.AddStack("cflinuxfs3")
+
+			found, err := client.StackByName("cflinuxfs3")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found.GUID).To(Equal(stack.GUID))
+		})
+
+		It("returns an AmbiguousError when several stacks share the name", func() {
+			server.AddStack("cflinuxfs3")
+			server.AddStack("cflinuxfs3")
+
+			_, err := client.StackByName("cflinuxfs3")
+			Expect(err).To(BeAssignableToTypeOf(&capi.AmbiguousError{}))
+		})
+	})
+
+	Describe("DomainByName", func() {
+		It("returns the domain with the name", func() {
+			server.AddDomain("other.example.com", false)
+			domain := server.AddDomain("istio.example.com", false)
+
+			found, err := client.DomainByName("istio.example.com")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found.GUID).To(Equal(domain.GUID))
+		})
+
+		It("returns an AmbiguousError when several domains share the name", func() {
+			server.AddDomain("istio.example.com", false)
+			server.AddDomain("istio.example.com", false)
+
+			_, err := client.DomainByName("istio.example.com")
+			Expect(err).To(BeAssignableToTypeOf(&capi.AmbiguousError{}))
+			Expect(err).To(MatchError(`found 2 domains named "istio.example.com", expected exactly one`))
+		})
+	})
+
+	Describe("OrganizationByName", func() {
+		It("returns an AmbiguousError when several organizations share the name", func() {
+			server.AddOrganization("org")
+			server.AddOrganization("org")
+
+			_, err := client.OrganizationByName("org")
+			Expect(err).To(BeAssignableToTypeOf(&capi.AmbiguousError{}))
+		})
+
+		It("returns a NotFoundError when there is no such organization", func() {
+			server.AddOrganization("org")
+
+			_, err := client.OrganizationByName("missing")
+			Expect(err).To(BeAssignableToTypeOf(&capi.NotFoundError{}))
+			Expect(err).To(MatchError(`organization "missing" not found`))
+		})
+	})
+
+	Describe("SpaceByName", func() {
+		var org capi.Organization
+
+		BeforeEach(func() {
+			org = server.AddOrganization("org")
+		})
+
+		It("only returns spaces in the given organization", func() {
+			server.AddSpaceInOrganization("space", server.AddOrganization("other-org").GUID)
+			spaceTwo := server.AddSpaceInOrganization("space", org.GUID)
+
+			space, err := client.SpaceByName(org.GUID, "space")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(space.GUID).To(Equal(spaceTwo.GUID))
+		})
+
+		It("returns an AmbiguousError when several spaces share the name", func() {
+			server.AddSpaceInOrganization("space", org.GUID)
+			server.AddSpaceInOrganization("space", org.GUID)
+
+			_, err := client.SpaceByName(org.GUID, "space")
+			Expect(err).To(BeAssignableToTypeOf(&capi.AmbiguousError{}))
+		})
+	})
+
+	Describe("RouteByHost", func() {
+		var (
+			spaceOne capi.Space
+			spaceTwo capi.Space
+			domain   capi.Domain
+		)
+
+		BeforeEach(func() {
+			spaceOne = server.AddSpace("space-one")
+			spaceTwo = server.AddSpace("space-two")
+			domain = server.AddDomain("istio.example.com", false)
+		})
+
+		It("returns the route in the given space when hostnames collide", func() {
+			internalDomain := server.AddDomain("istio.apps.internal", true)
+			server.AddRoute("host", "", internalDomain.GUID, spaceOne.GUID)
+			routeTwo := server.AddRoute("host", "", domain.GUID, spaceTwo.GUID)
+
+			route, err := client.RouteByHost(spaceTwo.GUID, "host")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(route.GUID).To(Equal(routeTwo.GUID))
+		})
+
+		It("ignores routes with a context path", func() {
+			server.AddRoute("host", "/some/path", domain.GUID, spaceOne.GUID)
+
+			_, err := client.RouteByHost(spaceOne.GUID, "host")
+			Expect(err).To(BeAssignableToTypeOf(&capi.NotFoundError{}))
+		})
+
+		It("refuses to choose between routes on different domains", func() {
+			internalDomain := server.AddDomain("istio.apps.internal", true)
+			server.AddRoute("host", "", domain.GUID, spaceOne.GUID)
+			internalRoute := server.AddRoute("host", "", internalDomain.GUID, spaceOne.GUID)
+
+			_, err := client.RouteByHost(spaceOne.GUID, "host")
+			Expect(err).To(BeAssignableToTypeOf(&capi.AmbiguousError{}))
+
+			route, err := client.RouteByHostInDomain(spaceOne.GUID, internalDomain.GUID, "host")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(route.GUID).To(Equal(internalRoute.GUID))
+		})
+	})
+
+	Describe("destinations", func() {
+		var (
+			route capi.Route
+			app   capi.App
+		)
+
+		BeforeEach(func() {
+			space := server.AddSpace("space")
+			domain := server.AddDomain("istio.example.com", false)
+			route = server.AddRoute("host", "", domain.GUID, space.GUID)
+			app = server.AddApp("app", space.GUID)
+		})
+
+		It("replaces the destinations of a route", func() {
+			otherApp := server.AddApp("other-app", app.Relationships.Space.Data.GUID)
+			_, err := client.InsertDestinations(route.GUID, capi.WeightedDestinations(map[string]int{app.GUID: 1}))
+			Expect(err).NotTo(HaveOccurred())
+
+			destinations, err := client.ReplaceDestinations(route.GUID, capi.WeightedDestinations(map[string]int{
+				app.GUID:      1,
+				otherApp.GUID: 9,
+			}))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(destinations).To(HaveLen(2))
+
+			destinations, err = client.Destinations(route.GUID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(destinations).To(HaveLen(2))
+			weights := map[string]int{}
+			for _, destination := range destinations {
+				weights[destination.App.GUID] = *destination.Weight
+			}
+			Expect(weights).To(Equal(map[string]int{app.GUID: 1, otherApp.GUID: 9}))
+		})
+
+		It("returns the Cloud Controller error when the request is rejected", func() {
+			_, err := client.ReplaceDestinations(route.GUID, capi.WeightedDestinations(map[string]int{"no-such-app": 1}))
+			Expect(err).To(HaveOccurred())
+
+			errResp, ok := err.(*capi.ErrorResponse)
+			Expect(ok).To(BeTrue())
+			Expect(errResp.StatusCode).To(Equal(422))
+			Expect(errResp.Errors[0].Title).To(Equal("CF-UnprocessableEntity"))
+			Expect(err.Error()).To(ContainSubstring("no-such-app"))
+		})
+	})
+
+	Describe("app lifecycle", func() {
+		var app capi.App
+
+		BeforeEach(func() {
+			app = server.AddApp("app", server.AddSpace("space").GUID)
+		})
+
+		It("stops and starts apps", func() {
+			stopped, err := client.StopApp(app.GUID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stopped.State).To(Equal("STOPPED"))
+			Expect(server.App(app.GUID).State).To(Equal("STOPPED"))
+
+			started, err := client.StartApp(app.GUID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(started.State).To(Equal("STARTED"))
+		})
+
+		It("overrides and reverts the start command of a process", func() {
+			Expect(client.SetProcessCommand(app.GUID, "web", "exit 1")).To(Succeed())
+			Expect(server.Command(app.GUID, "web")).To(Equal("exit 1"))
+
+			Expect(client.SetProcessCommand(app.GUID, "web", "")).To(Succeed())
+			Expect(server.Command(app.GUID, "web")).To(BeEmpty())
+			Expect(server.Requests()[len(server.Requests())-1].Body).To(ContainSubstring(`"command":null`))
+		})
+	})
+
+	Describe("network policies", func() {
+		It("creates, lists and deletes policies", func() {
+			policy := capi.NewPolicy("source-guid", "destination-guid", "tcp", 9080)
+			other := capi.NewPolicy("other-guid", "another-guid", "tcp", 8080)
+			Expect(client.CreatePolicies([]capi.Policy{policy, other})).To(Succeed())
+
+			policies, err := client.Policies("destination-guid")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policies).To(ConsistOf(policy))
+
+			Expect(client.DeletePolicies([]capi.Policy{policy})).To(Succeed())
+			Expect(server.Policies()).To(ConsistOf(other))
+		})
+	})
+
+	Describe("organizations", func() {
+		It("lists and deletes organizations", func() {
+			org := server.AddOrganization("org")
+			server.AddOrganization("other-org")
+
+			orgs, err := client.Organizations(url.Values{"names": {"org"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(orgs).To(HaveLen(1))
+			Expect(orgs[0].GUID).To(Equal(org.GUID))
+
+			Expect(client.DeleteOrganization(org.GUID)).To(Succeed())
+			Expect(server.Organizations()).To(HaveLen(1))
+			Expect(server.Organizations()[0].Name).To(Equal("other-org"))
+		})
+	})
+
+	Describe("DeleteDomain", func() {
+		It("deletes the domain", func() {
+			domain := server.AddDomain("apps.internal", true)
+
+			Expect(client.DeleteDomain(domain.GUID)).To(Succeed())
+			Expect(server.Domains()).To(BeEmpty())
+
+			err := client.DeleteDomain(domain.GUID)
+			Expect(err).To(MatchError(ContainSubstring("CF-ResourceNotFound")))
+		})
+	})
+
+	Describe("FeatureFlag", func() {
+		It("returns whether the flag is enabled", func() {
+			server.SetFeatureFlag("diego_docker", true)
+
+			flag, err := client.FeatureFlag("diego_docker")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(flag).To(Equal(capi.FeatureFlag{Name: "diego_docker", Enabled: true}))
+		})
+	})
+
+	It("returns an error when the Cloud Controller fails", func() {
+		server.FailNext("/v3/apps", 1)
+
+		_, err := client.Apps(url.Values{"names": {"app"}})
+		Expect(err).To(MatchError(ContainSubstring("GET /v3/apps failed with status 500")))
+	})
+})
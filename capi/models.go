@@ -0,0 +1,151 @@
+package capi
+
+import (
+	"sort"
+	"time"
+)
+
+// Info is what the Cloud Controller tells unauthenticated clients about
+// itself.
+type Info struct {
+	APIVersion    string `json:"api_version"`
+	TokenEndpoint string `json:"token_endpoint"`
+}
+
+type Relationship struct {
+	Data struct {
+		GUID string `json:"guid"`
+	} `json:"data"`
+}
+
+type App struct {
+	GUID          string    `json:"guid"`
+	Name          string    `json:"name"`
+	State         string    `json:"state"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Relationships struct {
+		Space Relationship `json:"space"`
+	} `json:"relationships"`
+}
+
+type Organization struct {
+	GUID      string    `json:"guid"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Space struct {
+	GUID          string    `json:"guid"`
+	Name          string    `json:"name"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Relationships struct {
+		Organization Relationship `json:"organization"`
+	} `json:"relationships"`
+}
+
+type Domain struct {
+	GUID      string    `json:"guid"`
+	Name      string    `json:"name"`
+	Internal  bool      `json:"internal"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Route struct {
+	GUID          string        `json:"guid"`
+	Host          string        `json:"host"`
+	Path          string        `json:"path"`
+	URL           string        `json:"url"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+	Destinations  []Destination `json:"destinations"`
+	Relationships struct {
+		Space  Relationship `json:"space"`
+		Domain Relationship `json:"domain"`
+	} `json:"relationships"`
+}
+
+type Stack struct {
+	GUID string `json:"guid"`
+	Name string `json:"name"`
+}
+
+type FeatureFlag struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+type Destination struct {
+	GUID     string         `json:"guid,omitempty"`
+	App      DestinationApp `json:"app"`
+	Weight   *int           `json:"weight,omitempty"`
+	Port     int            `json:"port,omitempty"`
+	Protocol string         `json:"protocol,omitempty"`
+}
+
+type DestinationApp struct {
+	GUID    string              `json:"guid"`
+	Process *DestinationProcess `json:"process,omitempty"`
+}
+
+type DestinationProcess struct {
+	Type string `json:"type"`
+}
+
+// WeightedDestinations builds the destinations for a route from a map of app
+// GUIDs to weights, in the form accepted by /v3/routes/:guid/destinations.
+func WeightedDestinations(appGUIDToWeights map[string]int) []Destination {
+	appGUIDs := []string{}
+	for appGUID := range appGUIDToWeights {
+		appGUIDs = append(appGUIDs, appGUID)
+	}
+	sort.Strings(appGUIDs)
+
+	destinations := []Destination{}
+	for _, appGUID := range appGUIDs {
+		w := appGUIDToWeights[appGUID]
+		destinations = append(destinations, Destination{
+			App:    DestinationApp{GUID: appGUID},
+			Weight: &w,
+		})
+	}
+	return destinations
+}
+
+// Policy is a container networking policy, in the form accepted by the
+// policy server's external API.
+type Policy struct {
+	Source      PolicySource      `json:"source"`
+	Destination PolicyDestination `json:"destination"`
+}
+
+type PolicySource struct {
+	ID string `json:"id"`
+}
+
+type PolicyDestination struct {
+	ID       string      `json:"id"`
+	Protocol string      `json:"protocol"`
+	Ports    PolicyPorts `json:"ports"`
+}
+
+type PolicyPorts struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// NewPolicy returns a policy allowing the source app to reach the
+// destination app on a single port.
+func NewPolicy(sourceAppGUID, destinationAppGUID, protocol string, port int) Policy {
+	return Policy{
+		Source: PolicySource{ID: sourceAppGUID},
+		Destination: PolicyDestination{
+			ID:       destinationAppGUID,
+			Protocol: protocol,
+			Ports:    PolicyPorts{Start: port, End: port},
+		},
+	}
+}
@@ -0,0 +1,286 @@
+// Command iats checks the foundation the acceptance suites run against, and
+// deploys and tears down demo topologies on it, using the same config file as
+// the suites.
+//
+//	iats preflight
+//	iats janitor
+//	iats -delete janitor
+//	iats -o ORG -s SPACE bookinfo deploy
+//	iats -o ORG -s SPACE bookinfo teardown
+//	iats -o ORG -s SPACE weighted-demo deploy
+//	iats -o ORG -s SPACE weighted-demo teardown
+//
+// The output of cf goes to stderr, so that stdout only lists the URLs of
+// what was deployed.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"code.cloudfoundry.org/istio-acceptance-tests/janitor"
+	"code.cloudfoundry.org/istio-acceptance-tests/preflight"
+	"code.cloudfoundry.org/istio-acceptance-tests/topology"
+)
+
+const usage = `usage: iats [flags] preflight
+       iats [flags] janitor
+       iats [flags] <topology> <deploy|teardown>
+
+Commands:
+  preflight      check that the foundation is set up the way the suites
+                 expect, and exit non-zero unless it is
+  janitor        list the orgs, apps, routes and network policies named with
+                 name_prefix that interrupted runs left behind, and delete
+                 them with -delete
+
+Topologies:
+  bookinfo       the istio bookinfo apps, with the product page on the istio
+                 domain and the other services on the internal apps domain
+  weighted-demo  two apps sharing a route on the istio domain, with the
+                 traffic split between them by weight
+
+Flags:
+`
+
+type options struct {
+	configPath  string
+	org         string
+	space       string
+	createSpace bool
+	suffix      string
+	hostname    string
+	weights     string
+	assets      string
+
+	olderThan            time.Duration
+	delete               bool
+	deleteInternalDomain bool
+}
+
+func main() {
+	opts := options{}
+	flags := flag.NewFlagSet("iats", flag.ExitOnError)
+	flags.StringVar(&opts.configPath, "config", os.Getenv("CONFIG"), "path to the acceptance tests config file")
+	flags.StringVar(&opts.org, "o", "", "org to deploy to")
+	flags.StringVar(&opts.space, "s", "", "space to deploy to")
+	flags.BoolVar(&opts.createSpace, "create-space", false, "create the org and space if they do not exist")
+	flags.StringVar(&opts.suffix, "suffix", "", "suffix of the bookinfo app names and services domain, to deploy several copies in one space")
+	flags.StringVar(&opts.hostname, "hostname", "weighted-demo", "hostname of the weighted-demo route")
+	flags.StringVar(&opts.weights, "weights", "1,1", "weights of the weighted-demo hello and hola apps")
+	flags.StringVar(&opts.assets, "assets", "assets", "path to the assets directory of this repository")
+	flags.DurationVar(&opts.olderThan, "older-than", 2*time.Hour, "only clean up what was created at least this long ago, to spare runs in progress")
+	flags.BoolVar(&opts.delete, "delete", false, "delete what the janitor finds instead of only listing it")
+	flags.BoolVar(&opts.deleteInternalDomain, "delete-internal-domain", false, "also clean up the default internal apps domain the suites create, once nothing routes through it")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	var err error
+	switch {
+	case flags.NArg() == 1 && flags.Arg(0) == "preflight":
+		err = runPreflight(opts)
+	case flags.NArg() == 1 && flags.Arg(0) == "janitor":
+		err = runJanitor(opts)
+	case flags.NArg() == 2 && opts.org != "" && opts.space != "":
+		err = run(opts, flags.Arg(0), flags.Arg(1))
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "iats: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func loadConfig(opts options) (config.Config, error) {
+	if opts.configPath == "" {
+		return config.Config{}, fmt.Errorf("no config file, set -config or CONFIG")
+	}
+	c, err := config.NewConfig(opts.configPath)
+	if err != nil {
+		return config.Config{}, err
+	}
+	return c, c.Validate()
+}
+
+// runPreflight prints the results of the preflight checks as a table.
+func runPreflight(opts options) error {
+	c, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	results := preflight.NewChecker(c, capi.NewClient(c)).Run()
+	fmt.Print(preflight.Table(results))
+	if !preflight.Passed(results) {
+		return fmt.Errorf("preflight checks failed")
+	}
+	return nil
+}
+
+// runJanitor prints what the janitor would clean up, and cleans it up when
+// asked to.
+func runJanitor(opts options) error {
+	c, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	j := janitor.New(c, capi.NewClient(c), opts.olderThan)
+	if opts.deleteInternalDomain && c.CFInternalAppsDomain == "" {
+		j.Domains = []string{config.DefaultInternalAppsDomain}
+	}
+	plan, err := j.Plan()
+	if err != nil {
+		return err
+	}
+	fmt.Print(plan)
+	if opts.delete {
+		return j.Execute(plan)
+	}
+	if !plan.Empty() {
+		fmt.Println("dry run, pass -delete to delete these")
+	}
+	return nil
+}
+
+func run(opts options, name, action string) error {
+	if action != "deploy" && action != "teardown" {
+		return fmt.Errorf("unknown action %q, expected deploy or teardown", action)
+	}
+	c, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	cf := topology.ExecCF(os.Stderr, os.Stderr, "CF_USERNAME="+c.GetAdminUser(), "CF_PASSWORD="+c.GetAdminPassword())
+	cc := capi.NewClient(c)
+	if err := target(cf, c, opts); err != nil {
+		return err
+	}
+
+	switch name {
+	case "bookinfo":
+		return bookinfo(cf, cc, c, opts, action)
+	case "weighted-demo":
+		return weightedDemo(cf, cc, c, opts, action)
+	default:
+		return fmt.Errorf("unknown topology %q", name)
+	}
+}
+
+// target logs cf in as the admin user from the config, whose credentials cf
+// auth reads from the environment, and targets the org and space to deploy
+// to.
+func target(cf topology.CF, c config.Config, opts options) error {
+	api := []string{"api", c.GetApiEndpoint()}
+	if c.GetSkipSSLValidation() {
+		api = append(api, "--skip-ssl-validation")
+	}
+	if err := cf(api...); err != nil {
+		return err
+	}
+	if err := cf("auth"); err != nil {
+		return err
+	}
+	if opts.createSpace {
+		if err := cf("create-org", opts.org); err != nil {
+			return err
+		}
+		if err := cf("create-space", opts.space, "-o", opts.org); err != nil {
+			return err
+		}
+	}
+	return cf("target", "-o", opts.org, "-s", opts.space)
+}
+
+func bookinfo(cf topology.CF, cc *capi.Client, c config.Config, opts options, action string) error {
+	if c.CFInternalAppsDomain == "" {
+		c.CFInternalAppsDomain = config.DefaultInternalAppsDomain
+	}
+	deployment := topology.NewBookinfo(opts.suffix, c.CFInternalAppsDomain)
+
+	if action == "teardown" {
+		if err := deployment.Teardown(cf); err != nil {
+			return err
+		}
+		// Suffixed copies have a services domain of their own, which deploy
+		// created.
+		if deployment.ServicesDomain != c.CFInternalAppsDomain {
+			return topology.DeleteInternalDomain(cc, deployment.ServicesDomain)
+		}
+		return nil
+	}
+
+	if err := cf("enable-feature-flag", "diego_docker"); err != nil {
+		return err
+	}
+	if err := topology.EnsureInternalDomain(cc, deployment.ServicesDomain); err != nil {
+		return err
+	}
+	if err := deployment.Push(cf, c); err != nil {
+		return err
+	}
+	if err := deployment.AddPolicies(cf); err != nil {
+		return err
+	}
+
+	fmt.Println(deployment.ProductPageURL(c))
+	for _, url := range deployment.InternalURLs() {
+		fmt.Println(url)
+	}
+	return nil
+}
+
+func weightedDemo(cf topology.CF, cc *capi.Client, c config.Config, opts options, action string) error {
+	weights := strings.Split(opts.weights, ",")
+	if len(weights) != 2 {
+		return fmt.Errorf("invalid -weights %q, expected two weights such as 1,9", opts.weights)
+	}
+	demo := topology.WeightedDemo{Hostname: opts.hostname}
+	for i, greeting := range []string{"hello", "hola"} {
+		weight, err := strconv.Atoi(weights[i])
+		if err != nil || weight < 1 {
+			return fmt.Errorf("invalid -weights %q, expected two positive weights", opts.weights)
+		}
+		demo.Apps = append(demo.Apps, topology.WeightedApp{
+			Name:    opts.hostname + "-" + greeting,
+			Droplet: filepath.Join(opts.assets, greeting+"-golang.tgz"),
+			Weight:  weight,
+		})
+	}
+
+	if action == "teardown" {
+		return demo.Teardown(cf, c)
+	}
+
+	if err := demo.Deploy(cf, cc, c, opts.org, opts.space); err != nil {
+		return err
+	}
+
+	fmt.Println(demo.URL(c))
+	appURLs := demo.AppURLs(c)
+	apps := []string{}
+	for app := range appURLs {
+		apps = append(apps, app)
+	}
+	sort.Strings(apps)
+	for _, app := range apps {
+		for _, url := range appURLs[app] {
+			fmt.Println(url)
+		}
+	}
+	return nil
+}
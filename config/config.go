@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
 	"time"
@@ -11,8 +12,29 @@ import (
 
 const DefaultInternalAppsDomain = "apps.internal"
 const DefaultInternalIstioDomain = "istio.apps.internal"
+const DefaultNumRetries = 2
+const DefaultRouteTimeout = 15 * time.Second
+const DefaultTimeoutBody = "upstream request timeout"
+const DefaultEjectionTimeout = 30 * time.Second
+const DefaultRecoveryTimeout = 2 * time.Minute
+const DefaultLargeBodyBytes = 256 << 20
+const DefaultMaxFirstByteLatency = 5 * time.Second
+const DefaultMaxDegradedResponseTime = 10 * time.Second
+const DefaultPushTimeout = 4 * time.Minute
+const DefaultCFAPITimeout = 2 * time.Minute
+const DefaultRouteConvergenceTimeout = 4 * time.Minute
+const DefaultHTTPRequestTimeout = 2 * time.Minute
+const DefaultSoakApps = 3
+const DefaultSoakRequestsPerSecond = 5
+const DefaultSoakChurnInterval = 30 * time.Second
+
+const (
+	BookinfoDriverChrome = "chrome"
+	BookinfoDriverHTTP   = "http"
+)
 
 type Config struct {
+	CFApi                    string `json:"cf_api"`
 	CFSystemDomain           string `json:"cf_system_domain"`
 	CFInternalAppsDomain     string `json:"cf_internal_apps_domain"`
 	CFInternalIstioDomain    string `json:"cf_internal_istio_domain"`
@@ -21,9 +43,254 @@ type Config struct {
 	AdminPassword            string `json:"cf_admin_password"`
 	ProductPageDockerWithTag string `json:"product_page_docker_tag"`
 	ReviewsDockerWithTag     string `json:"reviews_docker_tag"`
+	ReviewsV1DockerWithTag   string `json:"reviews_v1_docker_tag"`
+	ReviewsV2DockerWithTag   string `json:"reviews_v2_docker_tag"`
 	RatingsDockerWithTag     string `json:"ratings_docker_tag"`
 	DetailsDockerWithTag     string `json:"details_docker_tag"`
 	WildcardCa               string `json:"wildcard_ca"`
+	ClientCert               string `json:"client_cert"`
+	ClientKey                string `json:"client_key"`
+	SkipSSLValidation        *bool  `json:"skip_ssl_validation"`
+	PreferHTTPS              bool   `json:"prefer_https"`
+	BookinfoDriver           string `json:"bookinfo_driver"`
+
+	// The suites create, and delete again, an org, space and user of their
+	// own unless told to use existing ones. Existing ones are left in place,
+	// and only what the suites created in them is deleted.
+	UseExistingOrganization  bool   `json:"use_existing_organization"`
+	ExistingOrganization     string `json:"existing_organization"`
+	UseExistingSpace         bool   `json:"use_existing_space"`
+	ExistingSpace            string `json:"existing_space"`
+	UseExistingUser          bool   `json:"use_existing_user"`
+	ExistingUser             string `json:"existing_user"`
+	ExistingUserPassword     string `json:"existing_user_password"`
+	ShouldKeepUser           bool   `json:"keep_user_at_suite_end"`
+	ConfigurableTestPassword string `json:"test_password"`
+	NamePrefix               string `json:"name_prefix"`
+
+	PersistentAppOrg       string `json:"persistent_app_org"`
+	PersistentAppSpace     string `json:"persistent_app_space"`
+	PersistentAppQuotaName string `json:"persistent_app_quota_name"`
+
+	// TimeoutScale multiplies every timeout, for environments that are
+	// uniformly slower or faster than the defaults assume.
+	TimeoutScale float64  `json:"timeout_scale"`
+	Timeouts     Timeouts `json:"timeouts"`
+
+	WeightedRoutingConfidence float64 `json:"weighted_routing_confidence"`
+	PropagationReportDir      string  `json:"propagation_report_dir"`
+
+	RetryPolicy   RetryPolicy   `json:"retry_policy"`
+	TimeoutPolicy TimeoutPolicy `json:"timeout_policy"`
+
+	OutlierDetection OutlierDetectionPolicy `json:"outlier_detection"`
+	StreamingPolicy  StreamingPolicy        `json:"streaming_policy"`
+	TracingCollector TracingCollector       `json:"tracing_collector"`
+	BookinfoFaults   BookinfoFaultPolicy    `json:"bookinfo_faults"`
+	Soak             SoakPolicy             `json:"soak"`
+
+	Capabilities Capabilities `json:"capabilities"`
+	// IncludeInternalRouteTests predates Capabilities, and stands for both
+	// internal_routes and sidecar_proxying when they are not declared.
+	IncludeInternalRouteTests *bool `json:"include_internal_route_tests"`
+}
+
+// Capabilities declares which optional features the foundation has, so that
+// the specs needing a missing one are skipped. Features that are not
+// declared are detected when AutoDetect is set, and assumed otherwise.
+type Capabilities struct {
+	AutoDetect bool `json:"auto_detect"`
+
+	// InternalRoutes is routes on the internal istio domain.
+	InternalRoutes *bool `json:"internal_routes"`
+	// SidecarProxying is the Envoy sidecar in the path of requests from
+	// apps, as enabled by the enable-sidecar-proxying ops-file.
+	SidecarProxying *bool `json:"sidecar_proxying"`
+	// TLSTermination is the istio routers accepting HTTPS, as enabled by
+	// the enable-tls-termination ops-file.
+	TLSTermination *bool `json:"tls_termination"`
+	// WeightedRouting is weighted route destinations in the v3 API.
+	WeightedRouting *bool `json:"weighted_routing"`
+	// Docker is pushing docker images, which the bookinfo suite does.
+	Docker *bool `json:"docker"`
+}
+
+// Timeouts are the budgets the suites give each kind of operation, before
+// TimeoutScale is applied.
+type Timeouts struct {
+	// Push bounds pushing, restaging and starting apps.
+	Push string `json:"push"`
+	// CFAPI bounds every other cf command and Cloud Controller request.
+	CFAPI string `json:"cf_api"`
+	// RouteConvergence bounds waiting for route and policy changes to reach
+	// the routers and sidecars.
+	RouteConvergence string `json:"route_convergence"`
+	// HTTPRequest bounds a single request to an app.
+	HTTPRequest string `json:"http_request"`
+}
+
+// BookinfoFaultPolicy bounds how long the bookinfo product page may take to
+// render while one of the services it depends on is unavailable.
+type BookinfoFaultPolicy struct {
+	MaxDegradedResponseTime string `json:"max_degraded_response_time"`
+}
+
+func (p BookinfoFaultPolicy) GetMaxDegradedResponseTime() time.Duration {
+	timeout, _ := time.ParseDuration(p.MaxDegradedResponseTime)
+	return timeout
+}
+
+// SoakPolicy configures the soak suite, which keeps traffic flowing to a
+// fleet of apps while their routes and instances churn. It is skipped unless
+// Duration is set.
+type SoakPolicy struct {
+	Duration          string  `json:"duration"`
+	Apps              int     `json:"apps"`
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	ChurnInterval     string  `json:"churn_interval"`
+	// MinAvailability is the percentage of requests to each route that
+	// must succeed for the suite to pass. Zero only reports availability.
+	MinAvailability float64 `json:"min_availability"`
+	ReportDir       string  `json:"report_dir"`
+}
+
+func (p SoakPolicy) GetDuration() time.Duration {
+	duration, _ := time.ParseDuration(p.Duration)
+	return duration
+}
+
+func (p SoakPolicy) GetChurnInterval() time.Duration {
+	interval, _ := time.ParseDuration(p.ChurnInterval)
+	return interval
+}
+
+// TracingCollector names the route the sidecars are configured to send
+// Zipkin spans to. The bookinfo suite pushes its collector there, and skips
+// the tracing specs when Hostname is not set.
+type TracingCollector struct {
+	Hostname string `json:"hostname"`
+	Domain   string `json:"domain"`
+}
+
+// StreamingPolicy describes how the routers are expected to handle large
+// and incrementally delivered bodies. Request body size limits are only
+// tested when MaxRequestBodyBytes is set.
+type StreamingPolicy struct {
+	LargeBodyBytes         int64  `json:"large_body_bytes"`
+	MaxFirstByteLatency    string `json:"max_first_byte_latency"`
+	MaxRequestBodyBytes    int64  `json:"max_request_body_bytes"`
+	BodyTooLargeStatusCode int    `json:"body_too_large_status_code"`
+}
+
+func (p StreamingPolicy) GetMaxFirstByteLatency() time.Duration {
+	latency, _ := time.ParseDuration(p.MaxFirstByteLatency)
+	return latency
+}
+
+// OutlierDetectionPolicy bounds how quickly traffic is expected to shift away
+// from a failing app instance, and back to it once it recovers.
+type OutlierDetectionPolicy struct {
+	EjectionTimeout string `json:"ejection_timeout"`
+	RecoveryTimeout string `json:"recovery_timeout"`
+}
+
+func (p OutlierDetectionPolicy) GetEjectionTimeout() time.Duration {
+	timeout, _ := time.ParseDuration(p.EjectionTimeout)
+	return timeout
+}
+
+func (p OutlierDetectionPolicy) GetRecoveryTimeout() time.Duration {
+	timeout, _ := time.ParseDuration(p.RecoveryTimeout)
+	return timeout
+}
+
+// TimeoutPolicy describes how the routers and sidecars are expected to
+// handle slow backends.
+type TimeoutPolicy struct {
+	RouteTimeout      string `json:"route_timeout"`
+	TimeoutStatusCode int    `json:"timeout_status_code"`
+	TimeoutBody       string `json:"timeout_body"`
+	IdleTimeout       string `json:"idle_timeout"`
+}
+
+func (p TimeoutPolicy) GetRouteTimeout() time.Duration {
+	timeout, _ := time.ParseDuration(p.RouteTimeout)
+	return timeout
+}
+
+func (p TimeoutPolicy) GetIdleTimeout() time.Duration {
+	timeout, _ := time.ParseDuration(p.IdleTimeout)
+	return timeout
+}
+
+// RetryPolicy describes the retry policy the routers and sidecars are
+// expected to apply, in the terms of Envoy's route retry policy.
+type RetryPolicy struct {
+	NumRetries           *int     `json:"num_retries"`
+	RetryOn              []string `json:"retry_on"`
+	RetriableStatusCodes []int    `json:"retriable_status_codes"`
+	PerTryTimeout        string   `json:"per_try_timeout"`
+	RetryNonIdempotent   bool     `json:"retry_non_idempotent"`
+}
+
+// GetNumRetries returns num_retries, which defaults to DefaultNumRetries
+// and may be zero for a foundation that does not retry.
+func (p RetryPolicy) GetNumRetries() int {
+	if p.NumRetries == nil {
+		return DefaultNumRetries
+	}
+	return *p.NumRetries
+}
+
+// Attempts is the number of times a request is tried before giving up.
+func (p RetryPolicy) Attempts() int {
+	return p.GetNumRetries() + 1
+}
+
+func (p RetryPolicy) RetriesStatus(status int) bool {
+	if p.GetNumRetries() == 0 {
+		return false
+	}
+	if p.retriesOn("5xx") && status >= 500 && status <= 599 {
+		return true
+	}
+	if p.retriesOn("gateway-error") && (status == 502 || status == 503 || status == 504) {
+		return true
+	}
+	if p.retriesOn("retriable-status-codes") {
+		for _, code := range p.RetriableStatusCodes {
+			if code == status {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RetriesResets is whether a request is retried when the backend resets the
+// connection before responding.
+func (p RetryPolicy) RetriesResets() bool {
+	return p.GetNumRetries() > 0 && (p.retriesOn("5xx") || p.retriesOn("reset"))
+}
+
+// RetriesNonIdempotent is whether failed requests with non-idempotent
+// methods, such as POST, are retried.
+func (p RetryPolicy) RetriesNonIdempotent() bool {
+	return p.GetNumRetries() > 0 && p.RetryNonIdempotent
+}
+
+func (p RetryPolicy) GetPerTryTimeout() time.Duration {
+	timeout, _ := time.ParseDuration(p.PerTryTimeout)
+	return timeout
+}
+
+func (p RetryPolicy) retriesOn(condition string) bool {
+	for _, c := range p.RetryOn {
+		if c == condition {
+			return true
+		}
+	}
+	return false
 }
 
 func NewConfig(path string) (Config, error) {
@@ -66,13 +333,179 @@ func (c Config) Validate() error {
 	if c.DetailsDockerWithTag == "" {
 		c.DetailsDockerWithTag = "istio/examples-bookinfo-details-v1:1.5.0"
 	}
+	if driver := c.GetBookinfoDriver(); driver != BookinfoDriverChrome && driver != BookinfoDriverHTTP {
+		return fmt.Errorf("Invalid bookinfo_driver: %q, expected %q or %q", driver, BookinfoDriverChrome, BookinfoDriverHTTP)
+	}
+	for property, value := range map[string]string{
+		"retry_policy.per_try_timeout":               c.RetryPolicy.PerTryTimeout,
+		"timeout_policy.route_timeout":               c.TimeoutPolicy.RouteTimeout,
+		"timeout_policy.idle_timeout":                c.TimeoutPolicy.IdleTimeout,
+		"outlier_detection.ejection_timeout":         c.OutlierDetection.EjectionTimeout,
+		"outlier_detection.recovery_timeout":         c.OutlierDetection.RecoveryTimeout,
+		"streaming_policy.max_first_byte_latency":    c.StreamingPolicy.MaxFirstByteLatency,
+		"bookinfo_faults.max_degraded_response_time": c.BookinfoFaults.MaxDegradedResponseTime,
+		"timeouts.push":                              c.Timeouts.Push,
+		"timeouts.cf_api":                            c.Timeouts.CFAPI,
+		"timeouts.route_convergence":                 c.Timeouts.RouteConvergence,
+		"timeouts.http_request":                      c.Timeouts.HTTPRequest,
+		"soak.duration":                              c.Soak.Duration,
+		"soak.churn_interval":                        c.Soak.ChurnInterval,
+	} {
+		if value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("Invalid %s: %s", property, err)
+		}
+		if duration <= 0 {
+			return fmt.Errorf("Invalid %s: %s, expected a positive duration", property, value)
+		}
+	}
+	if c.UseExistingOrganization && c.ExistingOrganization == "" {
+		missingProperties = append(missingProperties, "existing_organization")
+	}
+	if c.UseExistingSpace && c.ExistingSpace == "" {
+		missingProperties = append(missingProperties, "existing_space")
+	}
+	if c.UseExistingUser && c.ExistingUser == "" {
+		missingProperties = append(missingProperties, "existing_user")
+	}
+	if c.UseExistingUser && c.ExistingUserPassword == "" {
+		missingProperties = append(missingProperties, "existing_user_password")
+	}
+	if (c.ClientCert == "") != (c.ClientKey == "") {
+		return errors.New("client_cert and client_key must be given together")
+	}
+	if c.RetryPolicy.NumRetries != nil && *c.RetryPolicy.NumRetries < 0 {
+		return fmt.Errorf("Invalid retry_policy.num_retries: %d, expected zero or more", *c.RetryPolicy.NumRetries)
+	}
+	if c.TimeoutScale < 0 {
+		return fmt.Errorf("Invalid timeout_scale: %v, expected a positive number", c.TimeoutScale)
+	}
+	if c.Soak.Apps < 0 || c.Soak.RequestsPerSecond < 0 {
+		return errors.New("soak.apps and soak.requests_per_second must not be negative")
+	}
+	if c.Soak.MinAvailability < 0 || c.Soak.MinAvailability > 100 {
+		return fmt.Errorf("Invalid soak.min_availability: %v, expected a percentage", c.Soak.MinAvailability)
+	}
+	if c.UseExistingSpace && !c.UseExistingOrganization {
+		return errors.New("use_existing_space requires use_existing_organization")
+	}
 	if len(missingProperties) > 0 {
 		return errors.New(fmt.Sprintf("Missing required config properties: %s", strings.Join(missingProperties, ", ")))
 	}
 	return nil
 }
 
+// GetRetryPolicy returns the configured retry policy, defaulting to
+// DefaultNumRetries retries on any 5xx.
+func (c Config) GetRetryPolicy() RetryPolicy {
+	policy := c.RetryPolicy
+	if len(policy.RetryOn) == 0 {
+		policy.RetryOn = []string{"5xx"}
+	}
+	return policy
+}
+
+// GetTimeoutPolicy returns the configured timeout policy, defaulting to
+// Envoy's default route timeout and response.
+func (c Config) GetTimeoutPolicy() TimeoutPolicy {
+	policy := c.TimeoutPolicy
+	if policy.RouteTimeout == "" {
+		policy.RouteTimeout = DefaultRouteTimeout.String()
+	}
+	if policy.TimeoutStatusCode == 0 {
+		policy.TimeoutStatusCode = http.StatusGatewayTimeout
+	}
+	if policy.TimeoutBody == "" {
+		policy.TimeoutBody = DefaultTimeoutBody
+	}
+	return policy
+}
+
+func (c Config) GetOutlierDetectionPolicy() OutlierDetectionPolicy {
+	policy := c.OutlierDetection
+	if policy.EjectionTimeout == "" {
+		policy.EjectionTimeout = DefaultEjectionTimeout.String()
+	}
+	if policy.RecoveryTimeout == "" {
+		policy.RecoveryTimeout = DefaultRecoveryTimeout.String()
+	}
+	return policy
+}
+
+// GetStreamingPolicy returns the configured streaming policy, defaulting to
+// DefaultLargeBodyBytes bodies and Envoy's 413 for oversized requests.
+func (c Config) GetStreamingPolicy() StreamingPolicy {
+	policy := c.StreamingPolicy
+	if policy.LargeBodyBytes == 0 {
+		policy.LargeBodyBytes = DefaultLargeBodyBytes
+	}
+	if policy.MaxFirstByteLatency == "" {
+		policy.MaxFirstByteLatency = DefaultMaxFirstByteLatency.String()
+	}
+	if policy.BodyTooLargeStatusCode == 0 {
+		policy.BodyTooLargeStatusCode = http.StatusRequestEntityTooLarge
+	}
+	return policy
+}
+
+// GetSoakPolicy returns the configured soak policy, defaulting to
+// DefaultSoakApps apps, each sent DefaultSoakRequestsPerSecond requests per
+// second per route, and a churn action every DefaultSoakChurnInterval.
+func (c Config) GetSoakPolicy() SoakPolicy {
+	policy := c.Soak
+	if policy.Apps == 0 {
+		policy.Apps = DefaultSoakApps
+	}
+	if policy.RequestsPerSecond == 0 {
+		policy.RequestsPerSecond = DefaultSoakRequestsPerSecond
+	}
+	if policy.ChurnInterval == "" {
+		policy.ChurnInterval = DefaultSoakChurnInterval.String()
+	}
+	return policy
+}
+
+func (c Config) GetBookinfoFaultPolicy() BookinfoFaultPolicy {
+	policy := c.BookinfoFaults
+	if policy.MaxDegradedResponseTime == "" {
+		policy.MaxDegradedResponseTime = DefaultMaxDegradedResponseTime.String()
+	}
+	return policy
+}
+
+// GetReviewsDockerImages returns the docker image of each version of the
+// bookinfo reviews app, keyed by version. ReviewsDockerWithTag is v3, the
+// version the bookinfo suite deploys as reviews.
+func (c Config) GetReviewsDockerImages() map[string]string {
+	images := map[string]string{
+		"v1": c.ReviewsV1DockerWithTag,
+		"v2": c.ReviewsV2DockerWithTag,
+		"v3": c.ReviewsDockerWithTag,
+	}
+	for version, image := range images {
+		if image == "" {
+			images[version] = fmt.Sprintf("istio/examples-bookinfo-reviews-%s:1.5.0", version)
+		}
+	}
+	return images
+}
+
+// GetBookinfoDriver returns how the bookinfo suite loads pages: with headless
+// Chrome, the default, or with plain HTTP requests.
+func (c Config) GetBookinfoDriver() string {
+	if c.BookinfoDriver == "" {
+		return BookinfoDriverChrome
+	}
+	return c.BookinfoDriver
+}
+
 func (c Config) GetApiEndpoint() string {
+	if c.CFApi != "" {
+		return c.CFApi
+	}
 	return "api." + c.CFSystemDomain
 }
 
@@ -84,18 +517,86 @@ func (c Config) GetAdminUser() string {
 	return c.AdminUser
 }
 
-func (c Config) GetConfigurableTestPassword() string            { return "" }
-func (c Config) GetPersistentAppOrg() string                    { return "" }
-func (c Config) GetPersistentAppQuotaName() string              { return "" }
-func (c Config) GetPersistentAppSpace() string                  { return "" }
-func (c Config) GetScaledTimeout(d time.Duration) time.Duration { return d }
-func (c Config) GetExistingUser() string                        { return "" }
-func (c Config) GetExistingUserPassword() string                { return "" }
-func (c Config) GetShouldKeepUser() bool                        { return false }
-func (c Config) GetUseExistingUser() bool                       { return false }
-func (c Config) GetUseExistingOrganization() bool               { return false }
-func (c Config) GetUseExistingSpace() bool                      { return false }
-func (c Config) GetExistingOrganization() string                { return "" }
-func (c Config) GetExistingSpace() string                       { return "" }
-func (c Config) GetSkipSSLValidation() bool                     { return true }
-func (c Config) GetNamePrefix() string                          { return "IATS" }
+func (c Config) GetConfigurableTestPassword() string { return c.ConfigurableTestPassword }
+func (c Config) GetPersistentAppOrg() string         { return c.PersistentAppOrg }
+func (c Config) GetPersistentAppQuotaName() string   { return c.PersistentAppQuotaName }
+func (c Config) GetPersistentAppSpace() string       { return c.PersistentAppSpace }
+func (c Config) GetExistingUser() string             { return c.ExistingUser }
+func (c Config) GetExistingUserPassword() string     { return c.ExistingUserPassword }
+func (c Config) GetShouldKeepUser() bool             { return c.ShouldKeepUser }
+func (c Config) GetUseExistingUser() bool            { return c.UseExistingUser }
+func (c Config) GetUseExistingOrganization() bool    { return c.UseExistingOrganization }
+func (c Config) GetUseExistingSpace() bool           { return c.UseExistingSpace }
+func (c Config) GetExistingOrganization() string     { return c.ExistingOrganization }
+func (c Config) GetExistingSpace() string            { return c.ExistingSpace }
+
+// GetSkipSSLValidation returns whether certificates go unverified, which
+// they do unless skip_ssl_validation is set to false.
+func (c Config) GetSkipSSLValidation() bool {
+	if c.SkipSSLValidation == nil {
+		return true
+	}
+	return *c.SkipSSLValidation
+}
+
+// GetAppScheme returns the scheme the suites reach apps on: https when
+// prefer_https is set, and http otherwise.
+func (c Config) GetAppScheme() string {
+	if c.PreferHTTPS {
+		return "https"
+	}
+	return "http"
+}
+
+// GetCapabilities returns the declared capabilities, with
+// include_internal_route_tests standing in for internal_routes and
+// sidecar_proxying when they are not declared.
+func (c Config) GetCapabilities() Capabilities {
+	capabilities := c.Capabilities
+	if capabilities.InternalRoutes == nil {
+		capabilities.InternalRoutes = c.IncludeInternalRouteTests
+	}
+	if capabilities.SidecarProxying == nil {
+		capabilities.SidecarProxying = c.IncludeInternalRouteTests
+	}
+	return capabilities
+}
+
+// GetScaledTimeout scales d by timeout_scale, which defaults to 1.
+func (c Config) GetScaledTimeout(d time.Duration) time.Duration {
+	if c.TimeoutScale == 0 {
+		return d
+	}
+	return time.Duration(float64(d) * c.TimeoutScale)
+}
+
+func (c Config) GetPushTimeout() time.Duration {
+	return c.scaledTimeout(c.Timeouts.Push, DefaultPushTimeout)
+}
+
+func (c Config) GetCFAPITimeout() time.Duration {
+	return c.scaledTimeout(c.Timeouts.CFAPI, DefaultCFAPITimeout)
+}
+
+func (c Config) GetRouteConvergenceTimeout() time.Duration {
+	return c.scaledTimeout(c.Timeouts.RouteConvergence, DefaultRouteConvergenceTimeout)
+}
+
+func (c Config) GetHTTPRequestTimeout() time.Duration {
+	return c.scaledTimeout(c.Timeouts.HTTPRequest, DefaultHTTPRequestTimeout)
+}
+
+func (c Config) scaledTimeout(configured string, defaultTimeout time.Duration) time.Duration {
+	timeout, err := time.ParseDuration(configured)
+	if err != nil || timeout == 0 {
+		timeout = defaultTimeout
+	}
+	return c.GetScaledTimeout(timeout)
+}
+
+func (c Config) GetNamePrefix() string {
+	if c.NamePrefix == "" {
+		return "IATS"
+	}
+	return c.NamePrefix
+}
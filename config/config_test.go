@@ -0,0 +1,63 @@
+package config_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config", func() {
+	var c config.Config
+
+	BeforeEach(func() {
+		c = config.Config{
+			IstioDomain:    "istio.example.com",
+			CFSystemDomain: "example.com",
+			AdminUser:      "admin",
+			AdminPassword:  "secret",
+		}
+	})
+
+	It("accepts the required properties alone", func() {
+		Expect(c.Validate()).To(Succeed())
+	})
+
+	DescribeTable("durations",
+		func(set func(*config.Config, string), property, value string, valid bool) {
+			set(&c, value)
+			if valid {
+				Expect(c.Validate()).To(Succeed())
+				return
+			}
+			Expect(c.Validate()).To(MatchError(ContainSubstring("Invalid " + property)))
+		},
+		Entry("accepts a positive timeouts.push", func(c *config.Config, v string) { c.Timeouts.Push = v }, "timeouts.push", "5m", true),
+		Entry("rejects a negative timeouts.push", func(c *config.Config, v string) { c.Timeouts.Push = v }, "timeouts.push", "-5m", false),
+		Entry("rejects a zero timeouts.push", func(c *config.Config, v string) { c.Timeouts.Push = v }, "timeouts.push", "0s", false),
+		Entry("rejects a negative timeouts.cf_api", func(c *config.Config, v string) { c.Timeouts.CFAPI = v }, "timeouts.cf_api", "-1s", false),
+		Entry("rejects a negative timeouts.route_convergence", func(c *config.Config, v string) { c.Timeouts.RouteConvergence = v }, "timeouts.route_convergence", "-1s", false),
+		Entry("rejects a negative timeouts.http_request", func(c *config.Config, v string) { c.Timeouts.HTTPRequest = v }, "timeouts.http_request", "-1s", false),
+		Entry("rejects an unparseable timeouts.http_request", func(c *config.Config, v string) { c.Timeouts.HTTPRequest = v }, "timeouts.http_request", "soon", false),
+		Entry("rejects a negative outlier_detection.ejection_timeout", func(c *config.Config, v string) { c.OutlierDetection.EjectionTimeout = v }, "outlier_detection.ejection_timeout", "-30s", false),
+		Entry("rejects a zero outlier_detection.recovery_timeout", func(c *config.Config, v string) { c.OutlierDetection.RecoveryTimeout = v }, "outlier_detection.recovery_timeout", "0s", false),
+		Entry("rejects a negative timeout_policy.idle_timeout", func(c *config.Config, v string) { c.TimeoutPolicy.IdleTimeout = v }, "timeout_policy.idle_timeout", "-1m", false),
+		Entry("rejects a zero timeout_policy.route_timeout", func(c *config.Config, v string) { c.TimeoutPolicy.RouteTimeout = v }, "timeout_policy.route_timeout", "0s", false),
+		Entry("rejects a negative retry_policy.per_try_timeout", func(c *config.Config, v string) { c.RetryPolicy.PerTryTimeout = v }, "retry_policy.per_try_timeout", "-1s", false),
+		Entry("rejects a zero streaming_policy.max_first_byte_latency", func(c *config.Config, v string) { c.StreamingPolicy.MaxFirstByteLatency = v }, "streaming_policy.max_first_byte_latency", "0s", false),
+		Entry("rejects a negative soak.duration", func(c *config.Config, v string) { c.Soak.Duration = v }, "soak.duration", "-1h", false),
+	)
+
+	Describe("GetPushTimeout", func() {
+		It("defaults and scales the push timeout", func() {
+			Expect(c.GetPushTimeout()).To(Equal(config.DefaultPushTimeout))
+
+			c.Timeouts.Push = "1m"
+			c.TimeoutScale = 2
+			Expect(c.GetPushTimeout()).To(Equal(2 * time.Minute))
+		})
+	})
+})
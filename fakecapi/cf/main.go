@@ -0,0 +1,263 @@
+// Command cf is a stand-in for the cf CLI that talks to a fakecapi.Server.
+// Build it with gexec.Build and put its directory first on PATH so that
+// cf.Cf invocations from the suites reach the fake Cloud Controller.
+//
+// Every invocation is appended to the file named by FAKE_CF_LOG, one line of
+// space separated arguments per call. Commands that only change local CLI
+// state (api, auth, target, ...) succeed without doing anything.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/fakecapi"
+)
+
+func main() {
+	args := os.Args[1:]
+	logInvocation(args)
+
+	apiURL := os.Getenv(fakecapi.URLEnvVar)
+	if apiURL == "" {
+		fail("%s is not set", fakecapi.URLEnvVar)
+	}
+	if len(args) == 0 {
+		fail("no command given")
+	}
+
+	switch args[0] {
+	case "curl":
+		curl(apiURL, args[1:])
+	case "app":
+		printGUID(apiURL, "/v3/apps", "names", args[1:])
+	case "space":
+		printGUID(apiURL, "/v3/spaces", "names", args[1:])
+	case "create-route":
+		createRoute(apiURL, args[1:])
+	case "map-route":
+		mapRoute(apiURL, args[1:])
+	}
+}
+
+func curl(apiURL string, args []string) {
+	var (
+		path        string
+		method      = "GET"
+		body        string
+		failOnError bool
+		headers     = http.Header{}
+	)
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-X":
+			i++
+			method = args[i]
+		case "-d":
+			i++
+			body = args[i]
+			if method == "GET" {
+				method = "POST"
+			}
+		case "-H":
+			i++
+			parts := strings.SplitN(args[i], ":", 2)
+			if len(parts) == 2 {
+				headers.Set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+			}
+		case "-f", "--fail":
+			failOnError = true
+		case "-i", "-v":
+		default:
+			path = args[i]
+		}
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	status, respBody := request(apiURL, method, path, headers, body)
+	os.Stdout.Write(respBody)
+	if failOnError && status >= 400 {
+		os.Exit(22)
+	}
+}
+
+func printGUID(apiURL, path, filter string, args []string) {
+	if len(args) == 0 {
+		fail("missing name")
+	}
+
+	guid, ok := lookupGUID(apiURL, path, url.Values{filter: {args[0]}})
+	if !ok {
+		fail("%s not found", args[0])
+	}
+	fmt.Println(guid)
+}
+
+func createRoute(apiURL string, args []string) {
+	positional, flags := parseFlags(args)
+	if len(positional) < 2 {
+		fail("usage: cf create-route SPACE DOMAIN [--hostname HOSTNAME] [--path PATH]")
+	}
+
+	spaceGUID, ok := lookupGUID(apiURL, "/v3/spaces", url.Values{"names": {positional[0]}})
+	if !ok {
+		fail("space %s not found", positional[0])
+	}
+	ensureRoute(apiURL, spaceGUID, positional[1], flags["--hostname"], flags["--path"])
+}
+
+func mapRoute(apiURL string, args []string) {
+	positional, flags := parseFlags(args)
+	if len(positional) < 2 {
+		fail("usage: cf map-route APP DOMAIN [--hostname HOSTNAME] [--path PATH]")
+	}
+
+	var apps struct {
+		Resources []struct {
+			GUID          string `json:"guid"`
+			Relationships struct {
+				Space struct {
+					Data struct {
+						GUID string `json:"guid"`
+					} `json:"data"`
+				} `json:"space"`
+			} `json:"relationships"`
+		} `json:"resources"`
+	}
+	getJSON(apiURL, "/v3/apps?"+url.Values{"names": {positional[0]}}.Encode(), &apps)
+	if len(apps.Resources) == 0 {
+		fail("app %s not found", positional[0])
+	}
+	app := apps.Resources[0]
+
+	routeGUID := ensureRoute(apiURL, app.Relationships.Space.Data.GUID, positional[1], flags["--hostname"], flags["--path"])
+	body := fmt.Sprintf(`{"destinations":[{"app":{"guid":%q}}]}`, app.GUID)
+	status, respBody := request(apiURL, "POST", fmt.Sprintf("/v3/routes/%s/destinations", routeGUID), nil, body)
+	if status >= 400 {
+		fail("mapping route failed: %s", respBody)
+	}
+}
+
+func ensureRoute(apiURL, spaceGUID, domainName, host, path string) string {
+	domainGUID, ok := lookupGUID(apiURL, "/v3/domains", url.Values{"names": {domainName}})
+	if !ok {
+		fail("domain %s not found", domainName)
+	}
+
+	query := url.Values{"hosts": {host}, "domain_guids": {domainGUID}, "paths": {path}}
+	if routeGUID, ok := lookupGUID(apiURL, "/v3/routes", query); ok {
+		return routeGUID
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"host": host,
+		"path": path,
+		"relationships": map[string]interface{}{
+			"space":  map[string]interface{}{"data": map[string]string{"guid": spaceGUID}},
+			"domain": map[string]interface{}{"data": map[string]string{"guid": domainGUID}},
+		},
+	})
+	status, respBody := request(apiURL, "POST", "/v3/routes", nil, string(body))
+	if status >= 400 {
+		fail("creating route failed: %s", respBody)
+	}
+
+	var route struct {
+		GUID string `json:"guid"`
+	}
+	json.Unmarshal(respBody, &route)
+	return route.GUID
+}
+
+func lookupGUID(apiURL, path string, query url.Values) (string, bool) {
+	var list struct {
+		Resources []struct {
+			GUID string `json:"guid"`
+		} `json:"resources"`
+	}
+	getJSON(apiURL, path+"?"+query.Encode(), &list)
+	if len(list.Resources) == 0 {
+		return "", false
+	}
+	return list.Resources[0].GUID, true
+}
+
+func getJSON(apiURL, path string, result interface{}) {
+	status, body := request(apiURL, "GET", path, nil, "")
+	if status >= 400 {
+		fail("GET %s failed: %s", path, body)
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		fail("decoding %s: %s", path, err)
+	}
+}
+
+func request(apiURL, method, path string, headers http.Header, body string) (int, []byte) {
+	req, err := http.NewRequest(method, apiURL+path, bytes.NewBufferString(body))
+	if err != nil {
+		fail("%s", err)
+	}
+	for key := range headers {
+		req.Header.Set(key, headers.Get(key))
+	}
+	req.Header.Set("Authorization", "bearer fake-cf")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fail("%s", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fail("%s", err)
+	}
+	return resp.StatusCode, respBody
+}
+
+func parseFlags(args []string) ([]string, map[string]string) {
+	positional := []string{}
+	flags := map[string]string{}
+	for i := 0; i < len(args); i++ {
+		if strings.HasPrefix(args[i], "-") {
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				flags[args[i]] = args[i+1]
+				i++
+			} else {
+				flags[args[i]] = ""
+			}
+			continue
+		}
+		positional = append(positional, args[i])
+	}
+	return positional, flags
+}
+
+func logInvocation(args []string) {
+	logPath := os.Getenv(fakecapi.LogEnvVar)
+	if logPath == "" {
+		return
+	}
+
+	logFile, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fail("%s", err)
+	}
+	defer logFile.Close()
+	fmt.Fprintln(logFile, strings.Join(args, " "))
+}
+
+func fail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "FAILED\n"+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,735 @@
+// Package fakecapi provides an in-memory Cloud Controller that implements the
+// subset of the v2 and v3 APIs used by the acceptance suites, so that suite
+// helpers can be exercised without a foundation.
+package fakecapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
+)
+
+// URLEnvVar is the environment variable the fake cf binary reads to find the
+// fake Cloud Controller.
+const URLEnvVar = "FAKE_CAPI_URL"
+
+// LogEnvVar is the environment variable naming the file the fake cf binary
+// appends each invocation to.
+const LogEnvVar = "FAKE_CF_LOG"
+
+const defaultPerPage = 50
+
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Body   string
+}
+
+type Server struct {
+	// PerPage limits the number of resources returned per page of a v3 list,
+	// so callers can exercise pagination.
+	PerPage int
+	// NoDestinations makes the server answer requests for route
+	// destinations as unknown, like a Cloud Controller that predates them.
+	NoDestinations bool
+	// Password, if set, is the only password the server issues tokens
+	// for.
+	Password string
+	// TokenExpiresIn is the lifetime in seconds of the tokens the server
+	// issues, an hour unless set.
+	TokenExpiresIn int
+
+	server *httptest.Server
+
+	lock         sync.Mutex
+	nextID       int
+	requests     []Request
+	orgs         []capi.Organization
+	spaces       []capi.Space
+	domains      []capi.Domain
+	apps         []capi.App
+	routes       []capi.Route
+	destinations map[string][]capi.Destination
+	commands     map[string]string
+	policies     []capi.Policy
+	featureFlags map[string]bool
+	stacks       []capi.Stack
+	failures     map[string]int
+}
+
+func NewServer() *Server {
+	s := &Server{
+		PerPage:      defaultPerPage,
+		destinations: map[string][]capi.Destination{},
+		commands:     map[string]string{},
+		featureFlags: map[string]bool{},
+		failures:     map[string]int{},
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+func (s *Server) tokenExpiresIn() int {
+	if s.TokenExpiresIn == 0 {
+		return 3600
+	}
+	return s.TokenExpiresIn
+}
+
+func (s *Server) AddOrganization(name string) capi.Organization {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	org := capi.Organization{GUID: s.guid("org"), Name: name, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	s.orgs = append(s.orgs, org)
+	return org
+}
+
+func (s *Server) AddSpace(name string) capi.Space {
+	return s.AddSpaceInOrganization(name, "")
+}
+
+func (s *Server) AddSpaceInOrganization(name, orgGUID string) capi.Space {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	space := capi.Space{GUID: s.guid("space"), Name: name, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	space.Relationships.Organization.Data.GUID = orgGUID
+	s.spaces = append(s.spaces, space)
+	return space
+}
+
+func (s *Server) AddDomain(name string, internal bool) capi.Domain {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.addDomain(name, internal)
+}
+
+func (s *Server) AddApp(name, spaceGUID string) capi.App {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	app := capi.App{GUID: s.guid("app"), Name: name, State: "STARTED", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	app.Relationships.Space.Data.GUID = spaceGUID
+	s.apps = append(s.apps, app)
+	return app
+}
+
+func (s *Server) AddStack(name string) capi.Stack {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stack := capi.Stack{GUID: s.guid("stack"), Name: name}
+	s.stacks = append(s.stacks, stack)
+	return stack
+}
+
+func (s *Server) SetFeatureFlag(name string, enabled bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.featureFlags[name] = enabled
+}
+
+func (s *Server) AddRoute(host, path, domainGUID, spaceGUID string) capi.Route {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.addRoute(host, path, domainGUID, spaceGUID)
+}
+
+func (s *Server) Organizations() []capi.Organization {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]capi.Organization{}, s.orgs...)
+}
+
+func (s *Server) Apps() []capi.App {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]capi.App{}, s.apps...)
+}
+
+func (s *Server) Domains() []capi.Domain {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]capi.Domain{}, s.domains...)
+}
+
+func (s *Server) Routes() []capi.Route {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]capi.Route{}, s.routes...)
+}
+
+func (s *Server) Destinations(routeGUID string) []capi.Destination {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]capi.Destination{}, s.destinations[routeGUID]...)
+}
+
+func (s *Server) App(guid string) capi.App {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, app := range s.apps {
+		if app.GUID == guid {
+			return app
+		}
+	}
+	return capi.App{}
+}
+
+// Command returns the start command set on a process of an app, or "" when
+// the detected command is used.
+func (s *Server) Command(appGUID, processType string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.commands[appGUID+"/"+processType]
+}
+
+func (s *Server) Policies() []capi.Policy {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]capi.Policy{}, s.policies...)
+}
+
+// Requests returns every request the server has received, in order.
+func (s *Server) Requests() []Request {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]Request{}, s.requests...)
+}
+
+// FailNext makes the next count requests to path fail with a 500 and a
+// Cloud Controller style error body.
+func (s *Server) FailNext(path string, count int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.failures[path] = count
+}
+
+func (s *Server) guid(kind string) string {
+	s.nextID++
+	return fmt.Sprintf("%s-guid-%d", kind, s.nextID)
+}
+
+func (s *Server) addDomain(name string, internal bool) capi.Domain {
+	domain := capi.Domain{GUID: s.guid("domain"), Name: name, Internal: internal, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	s.domains = append(s.domains, domain)
+	return domain
+}
+
+func (s *Server) addRoute(host, path, domainGUID, spaceGUID string) capi.Route {
+	route := capi.Route{GUID: s.guid("route"), Host: host, Path: path, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	route.Relationships.Domain.Data.GUID = domainGUID
+	route.Relationships.Space.Data.GUID = spaceGUID
+	for _, domain := range s.domains {
+		if domain.GUID == domainGUID {
+			route.URL = host + "." + domain.Name + path
+		}
+	}
+	s.routes = append(s.routes, route)
+	return route
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query(), Body: string(body)})
+
+	if s.failures[r.URL.Path] > 0 {
+		s.failures[r.URL.Path]--
+		writeError(w, http.StatusInternalServerError, "UnknownError", "injected failure")
+		return
+	}
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/v2/info":
+		writeJSON(w, http.StatusOK, map[string]string{
+			"api_version":            "2.150.0",
+			"token_endpoint":         s.server.URL,
+			"authorization_endpoint": s.server.URL,
+		})
+	case r.URL.Path == "/oauth/token" && s.Password != "" && formValue(body, "password") != s.Password:
+		writeError(w, http.StatusUnauthorized, "unauthorized", "Bad credentials")
+	case r.URL.Path == "/oauth/token":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"access_token": "fake-token",
+			"token_type":   "bearer",
+			"expires_in":   s.tokenExpiresIn(),
+		})
+	case !strings.HasPrefix(strings.ToLower(r.Header.Get("Authorization")), "bearer "):
+		writeError(w, http.StatusUnauthorized, "CF-InvalidAuthToken", "Invalid Auth Token")
+	case segments[0] == "v2" && len(segments) > 1:
+		s.serveV2(w, r, segments[1:], body)
+	case segments[0] == "v3" && len(segments) > 1:
+		s.serveV3(w, r, segments[1:], body)
+	case strings.HasPrefix(r.URL.Path, "/networking/v1/external/policies"):
+		s.servePolicies(w, r, body)
+	default:
+		writeError(w, http.StatusNotFound, "CF-NotFound", "Unknown request")
+	}
+}
+
+func (s *Server) serveV2(w http.ResponseWriter, r *http.Request, segments []string, body []byte) {
+	filter := v2Filter(r.URL.Query().Get("q"))
+
+	switch {
+	case segments[0] == "shared_domains" && r.Method == "POST":
+		var req struct {
+			Name     string `json:"name"`
+			Internal bool   `json:"internal"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "CF-MessageParseError", err.Error())
+			return
+		}
+		domain := s.addDomain(req.Name, req.Internal)
+		writeJSON(w, http.StatusCreated, v2Resource(domain.GUID, map[string]interface{}{"name": domain.Name, "internal": domain.Internal}))
+	case (segments[0] == "shared_domains" || segments[0] == "domains") && len(segments) == 1:
+		resources := []interface{}{}
+		for _, domain := range s.domains {
+			if filter["name"] == "" || filter["name"] == domain.Name {
+				resources = append(resources, v2Resource(domain.GUID, map[string]interface{}{"name": domain.Name, "internal": domain.Internal}))
+			}
+		}
+		writeJSON(w, http.StatusOK, v2List(resources))
+	case segments[0] == "routes" && len(segments) == 1:
+		resources := []interface{}{}
+		for _, route := range s.routes {
+			if filter["host"] == "" || filter["host"] == route.Host {
+				resources = append(resources, v2Resource(route.GUID, map[string]interface{}{
+					"host":        route.Host,
+					"path":        route.Path,
+					"domain_guid": route.Relationships.Domain.Data.GUID,
+					"space_guid":  route.Relationships.Space.Data.GUID,
+				}))
+			}
+		}
+		writeJSON(w, http.StatusOK, v2List(resources))
+	case segments[0] == "apps" && len(segments) == 1:
+		resources := []interface{}{}
+		for _, app := range s.apps {
+			if filter["name"] == "" || filter["name"] == app.Name {
+				resources = append(resources, v2Resource(app.GUID, map[string]interface{}{
+					"name":       app.Name,
+					"state":      app.State,
+					"space_guid": app.Relationships.Space.Data.GUID,
+				}))
+			}
+		}
+		writeJSON(w, http.StatusOK, v2List(resources))
+	case segments[0] == "apps" && len(segments) == 4 && segments[2] == "routes" && r.Method == "PUT":
+		s.mapRoute(w, segments[3], segments[1])
+	case segments[0] == "routes" && len(segments) == 4 && segments[2] == "apps" && r.Method == "PUT":
+		s.mapRoute(w, segments[1], segments[3])
+	default:
+		writeError(w, http.StatusNotFound, "CF-NotFound", "Unknown request")
+	}
+}
+
+func (s *Server) serveV3(w http.ResponseWriter, r *http.Request, segments []string, body []byte) {
+	query := r.URL.Query()
+
+	switch {
+	case segments[0] == "organizations" && len(segments) == 1:
+		resources := []interface{}{}
+		for _, org := range s.orgs {
+			if matches(query, "names", org.Name) {
+				resources = append(resources, org)
+			}
+		}
+		s.writePage(w, r, resources)
+	case segments[0] == "organizations" && len(segments) == 2 && r.Method == "DELETE":
+		for i, org := range s.orgs {
+			if org.GUID == segments[1] {
+				s.orgs = append(s.orgs[:i], s.orgs[i+1:]...)
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "CF-ResourceNotFound", "Organization not found")
+	case segments[0] == "domains" && len(segments) == 2 && r.Method == "DELETE":
+		for i, domain := range s.domains {
+			if domain.GUID == segments[1] {
+				s.domains = append(s.domains[:i], s.domains[i+1:]...)
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "CF-ResourceNotFound", "Domain not found")
+	case segments[0] == "spaces" && len(segments) == 1:
+		resources := []interface{}{}
+		for _, space := range s.spaces {
+			if matches(query, "names", space.Name) && matches(query, "guids", space.GUID) &&
+				matches(query, "organization_guids", space.Relationships.Organization.Data.GUID) {
+				resources = append(resources, space)
+			}
+		}
+		s.writePage(w, r, resources)
+	case segments[0] == "apps" && len(segments) == 1:
+		resources := []interface{}{}
+		for _, app := range s.apps {
+			if matches(query, "names", app.Name) && matches(query, "space_guids", app.Relationships.Space.Data.GUID) {
+				resources = append(resources, app)
+			}
+		}
+		s.writePage(w, r, resources)
+	case segments[0] == "apps" && len(segments) == 2 && r.Method == "DELETE":
+		for i, app := range s.apps {
+			if app.GUID == segments[1] {
+				s.apps = append(s.apps[:i], s.apps[i+1:]...)
+				for routeGUID, destinations := range s.destinations {
+					kept := []capi.Destination{}
+					for _, destination := range destinations {
+						if destination.App.GUID != app.GUID {
+							kept = append(kept, destination)
+						}
+					}
+					s.destinations[routeGUID] = kept
+				}
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "CF-ResourceNotFound", "App not found")
+	case segments[0] == "routes" && len(segments) == 2 && r.Method == "DELETE":
+		for i, route := range s.routes {
+			if route.GUID == segments[1] {
+				s.routes = append(s.routes[:i], s.routes[i+1:]...)
+				delete(s.destinations, route.GUID)
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "CF-ResourceNotFound", "Route not found")
+	case segments[0] == "apps" && len(segments) == 4 && segments[2] == "actions" && r.Method == "POST":
+		state := map[string]string{"start": "STARTED", "stop": "STOPPED"}[segments[3]]
+		for i := range s.apps {
+			if s.apps[i].GUID == segments[1] && state != "" {
+				s.apps[i].State = state
+				writeJSON(w, http.StatusOK, s.apps[i])
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "CF-ResourceNotFound", "App not found")
+	case segments[0] == "apps" && len(segments) == 4 && segments[2] == "processes" && r.Method == "PATCH":
+		if !s.appExists(segments[1]) {
+			writeError(w, http.StatusNotFound, "CF-ResourceNotFound", "App not found")
+			return
+		}
+		var req struct {
+			Command *string `json:"command"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "CF-MessageParseError", err.Error())
+			return
+		}
+		key := segments[1] + "/" + segments[3]
+		if req.Command == nil {
+			delete(s.commands, key)
+		} else {
+			s.commands[key] = *req.Command
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"type": segments[3], "command": req.Command})
+	case segments[0] == "domains" && len(segments) == 1 && r.Method == "POST":
+		var req struct {
+			Name     string `json:"name"`
+			Internal bool   `json:"internal"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "CF-MessageParseError", err.Error())
+			return
+		}
+		for _, domain := range s.domains {
+			if domain.Name == req.Name {
+				writeError(w, http.StatusUnprocessableEntity, "CF-UnprocessableEntity", fmt.Sprintf("The domain name %q is already in use", req.Name))
+				return
+			}
+		}
+		writeJSON(w, http.StatusCreated, s.addDomain(req.Name, req.Internal))
+	case segments[0] == "domains" && len(segments) == 1:
+		resources := []interface{}{}
+		for _, domain := range s.domains {
+			if matches(query, "names", domain.Name) {
+				resources = append(resources, domain)
+			}
+		}
+		s.writePage(w, r, resources)
+	case segments[0] == "routes" && len(segments) == 1 && r.Method == "POST":
+		var req struct {
+			Host          string `json:"host"`
+			Path          string `json:"path"`
+			Relationships struct {
+				Space  capi.Relationship `json:"space"`
+				Domain capi.Relationship `json:"domain"`
+			} `json:"relationships"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "CF-MessageParseError", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, s.addRoute(req.Host, req.Path, req.Relationships.Domain.Data.GUID, req.Relationships.Space.Data.GUID))
+	case segments[0] == "routes" && len(segments) == 1:
+		resources := []interface{}{}
+		for _, route := range s.routes {
+			if matches(query, "hosts", route.Host) &&
+				matches(query, "paths", route.Path) &&
+				matches(query, "space_guids", route.Relationships.Space.Data.GUID) &&
+				matches(query, "domain_guids", route.Relationships.Domain.Data.GUID) {
+				route.Destinations = s.destinations[route.GUID]
+				resources = append(resources, route)
+			}
+		}
+		s.writePage(w, r, resources)
+	case segments[0] == "routes" && len(segments) == 3 && segments[2] == "destinations" && !s.NoDestinations:
+		s.serveDestinations(w, r, segments[1], body)
+	case segments[0] == "stacks" && len(segments) == 1:
+		resources := []interface{}{}
+		for _, stack := range s.stacks {
+			if matches(query, "names", stack.Name) {
+				resources = append(resources, stack)
+			}
+		}
+		s.writePage(w, r, resources)
+	case segments[0] == "feature_flags" && len(segments) == 2 && r.Method == "GET":
+		enabled, ok := s.featureFlags[segments[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "CF-ResourceNotFound", "Feature flag not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, capi.FeatureFlag{Name: segments[1], Enabled: enabled})
+	default:
+		writeError(w, http.StatusNotFound, "CF-NotFound", "Unknown request")
+	}
+}
+
+func (s *Server) serveDestinations(w http.ResponseWriter, r *http.Request, routeGUID string, body []byte) {
+	if !s.routeExists(routeGUID) {
+		writeError(w, http.StatusNotFound, "CF-ResourceNotFound", "Route not found")
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+	case "POST", "PATCH":
+		var req struct {
+			Destinations []capi.Destination `json:"destinations"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "CF-MessageParseError", err.Error())
+			return
+		}
+		for i := range req.Destinations {
+			if !s.appExists(req.Destinations[i].App.GUID) {
+				writeError(w, http.StatusUnprocessableEntity, "CF-UnprocessableEntity", fmt.Sprintf("App with guid '%s' does not exist", req.Destinations[i].App.GUID))
+				return
+			}
+			req.Destinations[i].GUID = s.guid("destination")
+		}
+		if r.Method == "POST" {
+			s.destinations[routeGUID] = append(s.destinations[routeGUID], req.Destinations...)
+		} else {
+			s.destinations[routeGUID] = req.Destinations
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "CF-NotFound", "Unknown request")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"destinations": s.destinations[routeGUID]})
+}
+
+func (s *Server) servePolicies(w http.ResponseWriter, r *http.Request, body []byte) {
+	if r.Method == "GET" {
+		policies := []capi.Policy{}
+		for _, policy := range s.policies {
+			if matches(r.URL.Query(), "id", policy.Source.ID) || matches(r.URL.Query(), "id", policy.Destination.ID) {
+				policies = append(policies, policy)
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"total_policies": len(policies), "policies": policies})
+		return
+	}
+
+	var req struct {
+		Policies []capi.Policy `json:"policies"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "CF-MessageParseError", err.Error())
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/delete") {
+		kept := []capi.Policy{}
+		for _, policy := range s.policies {
+			deleted := false
+			for _, d := range req.Policies {
+				deleted = deleted || policy == d
+			}
+			if !deleted {
+				kept = append(kept, policy)
+			}
+		}
+		s.policies = kept
+	} else {
+		s.policies = append(s.policies, req.Policies...)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+func (s *Server) mapRoute(w http.ResponseWriter, routeGUID, appGUID string) {
+	if !s.routeExists(routeGUID) || !s.appExists(appGUID) {
+		writeError(w, http.StatusNotFound, "CF-ResourceNotFound", "Route or app not found")
+		return
+	}
+	s.destinations[routeGUID] = append(s.destinations[routeGUID], capi.Destination{
+		GUID: s.guid("destination"),
+		App:  capi.DestinationApp{GUID: appGUID},
+	})
+	writeJSON(w, http.StatusCreated, v2Resource(routeGUID, map[string]interface{}{}))
+}
+
+func (s *Server) routeExists(guid string) bool {
+	for _, route := range s.routes {
+		if route.GUID == guid {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) appExists(guid string) bool {
+	for _, app := range s.apps {
+		if app.GUID == guid {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) writePage(w http.ResponseWriter, r *http.Request, resources []interface{}) {
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage := s.PerPage
+	if n, err := strconv.Atoi(query.Get("per_page")); err == nil && n > 0 {
+		perPage = n
+	}
+
+	start := (page - 1) * perPage
+	if start > len(resources) {
+		start = len(resources)
+	}
+	end := start + perPage
+	if end > len(resources) {
+		end = len(resources)
+	}
+
+	pagination := map[string]interface{}{
+		"total_results": len(resources),
+		"next":          nil,
+	}
+	if end < len(resources) {
+		query.Set("page", strconv.Itoa(page+1))
+		query.Set("per_page", strconv.Itoa(perPage))
+		pagination["next"] = map[string]string{
+			"href": fmt.Sprintf("%s%s?%s", s.server.URL, r.URL.Path, query.Encode()),
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"pagination": pagination,
+		"resources":  resources[start:end],
+	})
+}
+
+// matches implements v3 list filters, which take a comma separated list of
+// acceptable values.
+func matches(query url.Values, filter, value string) bool {
+	if _, ok := query[filter]; !ok {
+		return true
+	}
+	for _, v := range strings.Split(query.Get(filter), ",") {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func v2Filter(q string) map[string]string {
+	filter := map[string]string{}
+	if parts := strings.SplitN(q, ":", 2); len(parts) == 2 {
+		filter[parts[0]] = parts[1]
+	}
+	return filter
+}
+
+func v2Resource(guid string, entity map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"metadata": map[string]string{"guid": guid},
+		"entity":   entity,
+	}
+}
+
+func v2List(resources []interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"total_results": len(resources),
+		"total_pages":   1,
+		"resources":     resources,
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, title, detail string) {
+	writeJSON(w, status, map[string]interface{}{
+		"errors": []capi.Error{{Code: status, Title: title, Detail: detail}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(body)
+}
+
+func formValue(body []byte, key string) string {
+	form, _ := url.ParseQuery(string(body))
+	return form.Get(key)
+}
@@ -0,0 +1,142 @@
+package helpers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"code.cloudfoundry.org/istio-acceptance-tests/topology"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+)
+
+// EnsureInternalAppsDomain creates the default internal apps domain when the
+// config does not name one, and returns the internal apps domain to use.
+func EnsureInternalAppsDomain(c config.Config, timeout time.Duration) string {
+	if c.CFInternalAppsDomain != "" {
+		return c.CFInternalAppsDomain
+	}
+
+	createCmd := cf.Cf("curl", "/v2/shared_domains", "-d", fmt.Sprintf("{\"name\": \"%s\", \"internal\": true}", config.DefaultInternalAppsDomain))
+	Expect(createCmd.Wait(timeout)).To(Exit(0))
+	return config.DefaultInternalAppsDomain
+}
+
+// EnsureInternalDomain creates the internal shared domain name unless it
+// already exists.
+func EnsureInternalDomain(cc *capi.Client, name string) {
+	Expect(topology.EnsureInternalDomain(cc, name)).To(Succeed())
+}
+
+// SpaceGUID resolves a space within the named org, since space names are
+// only unique within an org.
+func SpaceGUID(cc *capi.Client, orgName, spaceName string) string {
+	org, err := cc.OrganizationByName(orgName)
+	Expect(err).ToNot(HaveOccurred())
+	space, err := cc.SpaceByName(org.GUID, spaceName)
+	Expect(err).ToNot(HaveOccurred())
+	return space.GUID
+}
+
+func AppGUID(cc *capi.Client, spaceGUID, appName string) string {
+	app, err := cc.AppByName(appName, spaceGUID)
+	Expect(err).ToNot(HaveOccurred())
+	return app.GUID
+}
+
+func DomainGUID(cc *capi.Client, domainName string) string {
+	domain, err := cc.DomainByName(domainName)
+	Expect(err).ToNot(HaveOccurred())
+	return domain.GUID
+}
+
+func RouteGUID(cc *capi.Client, spaceGUID, hostname string) string {
+	route, err := cc.RouteByHost(spaceGUID, hostname)
+	Expect(err).ToNot(HaveOccurred())
+	return route.GUID
+}
+
+func RouteGUIDInDomain(cc *capi.Client, spaceGUID, domainName, hostname string) string {
+	route, err := cc.RouteByHostInDomain(spaceGUID, DomainGUID(cc, domainName), hostname)
+	Expect(err).ToNot(HaveOccurred())
+	return route.GUID
+}
+
+// AddWeightedDestinations replaces the destinations of a route with the given
+// apps, weighted as given.
+func AddWeightedDestinations(cc *capi.Client, routeGUID string, appGUIDToWeights map[string]int) {
+	_, err := cc.ReplaceDestinations(routeGUID, capi.WeightedDestinations(appGUIDToWeights))
+	Expect(err).ToNot(HaveOccurred())
+}
+
+// SetDestinationProtocol replaces the destinations of a route with copies
+// that use protocol, e.g. "http2" for apps that only speak HTTP/2.
+func SetDestinationProtocol(cc *capi.Client, routeGUID, protocol string) {
+	destinations, err := cc.Destinations(routeGUID)
+	Expect(err).ToNot(HaveOccurred())
+
+	for i := range destinations {
+		destinations[i].GUID = ""
+		destinations[i].Protocol = protocol
+	}
+	_, err = cc.ReplaceDestinations(routeGUID, destinations)
+	Expect(err).ToNot(HaveOccurred())
+}
+
+// SpaceContents records the apps and routes in a space, so that a suite
+// running in an existing space can delete only what it created.
+type SpaceContents struct {
+	AppGUIDs   map[string]bool
+	RouteGUIDs map[string]bool
+}
+
+func SnapshotSpace(cc *capi.Client, spaceGUID string) SpaceContents {
+	contents := SpaceContents{AppGUIDs: map[string]bool{}, RouteGUIDs: map[string]bool{}}
+
+	apps, err := cc.Apps(url.Values{"space_guids": {spaceGUID}})
+	Expect(err).ToNot(HaveOccurred())
+	for _, app := range apps {
+		contents.AppGUIDs[app.GUID] = true
+	}
+
+	routes, err := cc.Routes(url.Values{"space_guids": {spaceGUID}})
+	Expect(err).ToNot(HaveOccurred())
+	for _, route := range routes {
+		contents.RouteGUIDs[route.GUID] = true
+	}
+	return contents
+}
+
+// DeleteCreatedSince deletes the apps and routes in a space that were not
+// there when before was taken and that owned claims, by app name or route
+// host, so that what others create in the space meanwhile is left alone.
+func DeleteCreatedSince(cc *capi.Client, spaceGUID string, before SpaceContents, owned func(name string) bool) {
+	apps, err := cc.Apps(url.Values{"space_guids": {spaceGUID}})
+	Expect(err).ToNot(HaveOccurred())
+	for _, app := range apps {
+		if !before.AppGUIDs[app.GUID] && owned(app.Name) {
+			Expect(cc.DeleteApp(app.GUID)).To(Succeed())
+		}
+	}
+
+	routes, err := cc.Routes(url.Values{"space_guids": {spaceGUID}})
+	Expect(err).ToNot(HaveOccurred())
+	for _, route := range routes {
+		if !before.RouteGUIDs[route.GUID] && owned(route.Host) {
+			Expect(cc.DeleteRoute(route.GUID)).To(Succeed())
+		}
+	}
+}
+
+// HasNamePrefix returns whether name starts with prefix followed by a dash,
+// as the names the suites generate with it do, ignoring case.
+func HasNamePrefix(prefix string) func(name string) bool {
+	return func(name string) bool {
+		return strings.HasPrefix(strings.ToLower(name), strings.ToLower(prefix)+"-")
+	}
+}
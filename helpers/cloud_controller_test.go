@@ -0,0 +1,189 @@
+package helpers_test
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"code.cloudfoundry.org/istio-acceptance-tests/fakecapi"
+	"code.cloudfoundry.org/istio-acceptance-tests/helpers"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Cloud Controller helpers", func() {
+	var (
+		server  *fakecapi.Server
+		client  *capi.Client
+		cfg     config.Config
+		cfLog   string
+		timeout = 10 * time.Second
+	)
+
+	BeforeEach(func() {
+		server = fakecapi.NewServer()
+		cfLog = useFakeCf(server)
+		cfg = config.Config{
+			CFApi:         server.URL(),
+			AdminUser:     "admin",
+			AdminPassword: "secret",
+		}
+		client = capi.NewClient(cfg)
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.Remove(cfLog)
+	})
+
+	Describe("EnsureInternalAppsDomain", func() {
+		It("creates the default internal domain when none is configured", func() {
+			Expect(helpers.EnsureInternalAppsDomain(cfg, timeout)).To(Equal(config.DefaultInternalAppsDomain))
+
+			domains := server.Domains()
+			Expect(domains).To(HaveLen(1))
+			Expect(domains[0].Name).To(Equal(config.DefaultInternalAppsDomain))
+			Expect(domains[0].Internal).To(BeTrue())
+		})
+
+		It("uses the configured internal domain", func() {
+			cfg.CFInternalAppsDomain = "internal.example.com"
+
+			Expect(helpers.EnsureInternalAppsDomain(cfg, timeout)).To(Equal("internal.example.com"))
+			Expect(server.Domains()).To(BeEmpty())
+
+			invocations, err := ioutil.ReadFile(cfLog)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(invocations).To(BeEmpty())
+		})
+	})
+
+	Describe("EnsureInternalDomain", func() {
+		It("creates the domain once", func() {
+			helpers.EnsureInternalDomain(client, "faults.apps.internal")
+			helpers.EnsureInternalDomain(client, "faults.apps.internal")
+
+			domains := server.Domains()
+			Expect(domains).To(HaveLen(1))
+			Expect(domains[0].Name).To(Equal("faults.apps.internal"))
+			Expect(domains[0].Internal).To(BeTrue())
+		})
+	})
+
+	Describe("DeleteCreatedSince", func() {
+		It("only deletes the owned apps and routes created after the snapshot", func() {
+			space := server.AddSpace("existing-space")
+			otherSpace := server.AddSpace("other-space")
+			domain := server.AddDomain("istio.example.com", false)
+			existingApp := server.AddApp("IATS-existing", space.GUID)
+			existingRoute := server.AddRoute("IATS-existing", "", domain.GUID, space.GUID)
+
+			before := helpers.SnapshotSpace(client, space.GUID)
+
+			server.AddApp("IATS-1-created", space.GUID)
+			server.AddRoute("iats-1-created", "", domain.GUID, space.GUID)
+			someoneElsesApp := server.AddApp("created", space.GUID)
+			someoneElsesRoute := server.AddRoute("created", "", domain.GUID, space.GUID)
+			otherApp := server.AddApp("IATS-elsewhere", otherSpace.GUID)
+
+			helpers.DeleteCreatedSince(client, space.GUID, before, helpers.HasNamePrefix("IATS"))
+
+			Expect(server.Apps()).To(ConsistOf(existingApp, someoneElsesApp, otherApp))
+			routeGUIDs := []string{}
+			for _, route := range server.Routes() {
+				routeGUIDs = append(routeGUIDs, route.GUID)
+			}
+			Expect(routeGUIDs).To(ConsistOf(existingRoute.GUID, someoneElsesRoute.GUID))
+		})
+	})
+
+	Context("with apps and routes in several spaces", func() {
+		var (
+			space capi.Space
+			app   capi.App
+		)
+
+		BeforeEach(func() {
+			otherSpace := server.AddSpace("other-space")
+			space = server.AddSpace("space")
+			server.AddDomain("istio.example.com", false)
+			server.AddDomain("istio.apps.internal", true)
+			server.AddApp("app", otherSpace.GUID)
+			app = server.AddApp("app", space.GUID)
+
+			Expect(cf.Cf("create-route", "other-space", "istio.apps.internal", "--hostname", "host").Wait(timeout)).To(Exit(0))
+			Expect(cf.Cf("create-route", "space", "istio.example.com", "--hostname", "host").Wait(timeout)).To(Exit(0))
+		})
+
+		It("resolves space GUIDs within the org", func() {
+			org := server.AddOrganization("org")
+			server.AddSpaceInOrganization("space", server.AddOrganization("other-org").GUID)
+			orgSpace := server.AddSpaceInOrganization("space", org.GUID)
+
+			Expect(helpers.SpaceGUID(client, "org", "space")).To(Equal(orgSpace.GUID))
+		})
+
+		It("resolves app GUIDs within the space", func() {
+			Expect(helpers.AppGUID(client, space.GUID, "app")).To(Equal(app.GUID))
+		})
+
+		It("resolves route GUIDs within the space", func() {
+			routeGUID := helpers.RouteGUID(client, space.GUID, "host")
+
+			for _, route := range server.Routes() {
+				if route.GUID == routeGUID {
+					Expect(route.Relationships.Space.Data.GUID).To(Equal(space.GUID))
+					return
+				}
+			}
+			Fail("route not found on the fake Cloud Controller")
+		})
+
+		It("resolves route GUIDs within the domain", func() {
+			server.AddDomain("bookinfo.apps.internal", true)
+			Expect(cf.Cf("create-route", "space", "bookinfo.apps.internal", "--hostname", "host").Wait(timeout)).To(Exit(0))
+			routeGUID := helpers.RouteGUIDInDomain(client, space.GUID, "bookinfo.apps.internal", "host")
+
+			for _, route := range server.Routes() {
+				if route.GUID == routeGUID {
+					Expect(route.Relationships.Space.Data.GUID).To(Equal(space.GUID))
+					Expect(route.Relationships.Domain.Data.GUID).To(Equal(helpers.DomainGUID(client, "bookinfo.apps.internal")))
+					return
+				}
+			}
+			Fail("route not found on the fake Cloud Controller")
+		})
+
+		It("adds weighted destinations to a route", func() {
+			otherApp := server.AddApp("other-app", space.GUID)
+			routeGUID := helpers.RouteGUID(client, space.GUID, "host")
+
+			helpers.AddWeightedDestinations(client, routeGUID, map[string]int{app.GUID: 1, otherApp.GUID: 9})
+
+			destinations := server.Destinations(routeGUID)
+			Expect(destinations).To(HaveLen(2))
+			Expect(destinations[0].App.GUID).To(Equal(app.GUID))
+			Expect(*destinations[0].Weight).To(Equal(1))
+			Expect(destinations[1].App.GUID).To(Equal(otherApp.GUID))
+			Expect(*destinations[1].Weight).To(Equal(9))
+		})
+
+		It("sets the protocol of a route's destinations", func() {
+			routeGUID := helpers.RouteGUID(client, space.GUID, "host")
+			_, err := client.InsertDestinations(routeGUID, []capi.Destination{{App: capi.DestinationApp{GUID: app.GUID}}})
+			Expect(err).NotTo(HaveOccurred())
+
+			helpers.SetDestinationProtocol(client, routeGUID, "http2")
+
+			destinations := server.Destinations(routeGUID)
+			Expect(destinations).To(HaveLen(1))
+			Expect(destinations[0].App.GUID).To(Equal(app.GUID))
+			Expect(destinations[0].Protocol).To(Equal("http2"))
+		})
+	})
+})
@@ -0,0 +1,51 @@
+package helpers_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/fakecapi"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+)
+
+var (
+	fakeCfPath   string
+	originalPath string
+)
+
+func TestHelpers(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Helpers Suite")
+}
+
+var _ = BeforeSuite(func() {
+	var err error
+	fakeCfPath, err = gexec.Build("code.cloudfoundry.org/istio-acceptance-tests/fakecapi/cf")
+	Expect(err).NotTo(HaveOccurred())
+
+	originalPath = os.Getenv("PATH")
+	os.Setenv("PATH", filepath.Dir(fakeCfPath)+string(os.PathListSeparator)+originalPath)
+})
+
+var _ = AfterSuite(func() {
+	os.Setenv("PATH", originalPath)
+	gexec.CleanupBuildArtifacts()
+})
+
+// useFakeCf points the fake cf binary at server and returns the path of the
+// file it logs its invocations to.
+func useFakeCf(server *fakecapi.Server) string {
+	logFile, err := ioutil.TempFile("", "fake-cf-log")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(logFile.Close()).To(Succeed())
+
+	os.Setenv(fakecapi.URLEnvVar, server.URL())
+	os.Setenv(fakecapi.LogEnvVar, logFile.Name())
+	return logFile.Name()
+}
@@ -0,0 +1,60 @@
+// Package httpclient builds the HTTP clients the suites reach apps with, so
+// that every suite honours the same TLS settings.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+)
+
+// TLSConfig returns the TLS settings for connections to apps. Certificates
+// are verified against the system roots and wildcard_ca, unless
+// skip_ssl_validation is set and there is no wildcard_ca to verify against.
+// The configured client certificate is presented to servers that ask for
+// one.
+func TLSConfig(c config.Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if c.WildcardCa != "" {
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM([]byte(c.WildcardCa)) {
+			return nil, errors.New("wildcard_ca does not contain any PEM encoded certificates")
+		}
+		tlsConfig.RootCAs = roots
+	} else {
+		tlsConfig.InsecureSkipVerify = c.GetSkipSSLValidation()
+	}
+
+	if c.ClientCert != "" {
+		cert, err := tls.X509KeyPair([]byte(c.ClientCert), []byte(c.ClientKey))
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// New returns a client for requests to apps, with the TLS settings of
+// TLSConfig and the configured HTTP request timeout.
+func New(c config.Config) (*http.Client, error) {
+	tlsConfig, err := TLSConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Timeout: c.GetHTTPRequestTimeout(),
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+	}, nil
+}
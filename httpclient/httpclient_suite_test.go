@@ -0,0 +1,14 @@
+package httpclient_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestHTTPClient(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "HTTP Client Suite")
+}
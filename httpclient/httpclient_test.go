@@ -0,0 +1,115 @@
+package httpclient_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"code.cloudfoundry.org/istio-acceptance-tests/httpclient"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HTTP client", func() {
+	var (
+		server      *httptest.Server
+		serverCA    string
+		clientNames []string
+		skip        bool
+		noSkip      bool
+	)
+
+	BeforeEach(func() {
+		clientNames = nil
+		server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, cert := range r.TLS.PeerCertificates {
+				clientNames = append(clientNames, cert.Subject.CommonName)
+			}
+		}))
+		server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+		server.StartTLS()
+		serverCA = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+		skip, noSkip = true, false
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	get := func(c config.Config) error {
+		client, err := httpclient.New(c)
+		Expect(err).NotTo(HaveOccurred())
+		res, err := client.Get(server.URL)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		return nil
+	}
+
+	It("verifies servers against the wildcard CA", func() {
+		Expect(get(config.Config{WildcardCa: serverCA, SkipSSLValidation: &noSkip})).To(Succeed())
+	})
+
+	It("verifies servers against the wildcard CA even when skipping SSL validation", func() {
+		otherCA, _ := clientCertificate("other-ca")
+
+		Expect(get(config.Config{WildcardCa: otherCA, SkipSSLValidation: &skip})).NotTo(Succeed())
+	})
+
+	It("rejects unknown certificates when SSL validation is enabled", func() {
+		Expect(get(config.Config{SkipSSLValidation: &noSkip})).NotTo(Succeed())
+	})
+
+	It("skips SSL validation by default", func() {
+		Expect(get(config.Config{})).To(Succeed())
+	})
+
+	It("presents the client certificate", func() {
+		cert, key := clientCertificate("iats-client")
+
+		Expect(get(config.Config{WildcardCa: serverCA, ClientCert: cert, ClientKey: key})).To(Succeed())
+		Expect(clientNames).To(Equal([]string{"iats-client"}))
+	})
+
+	It("rejects a wildcard CA without certificates", func() {
+		_, err := httpclient.New(config.Config{WildcardCa: "not a certificate"})
+		Expect(err).To(MatchError(ContainSubstring("wildcard_ca")))
+	})
+
+	It("uses the configured HTTP request timeout", func() {
+		client, err := httpclient.New(config.Config{TimeoutScale: 2, Timeouts: config.Timeouts{HTTPRequest: "5s"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(client.Timeout).To(Equal(10 * time.Second))
+	})
+})
+
+func clientCertificate(commonName string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
@@ -0,0 +1,223 @@
+// Package janitor finds the orgs, apps, routes and network policies that
+// interrupted runs of the suites left behind, by the prefix of their names,
+// and deletes them.
+package janitor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+)
+
+// policyBatchSize bounds how many app GUIDs go into one policies query, to
+// keep its URL short.
+const policyBatchSize = 50
+
+// Janitor plans and carries out the cleanup of the foundation behind
+// CloudController.
+type Janitor struct {
+	CloudController *capi.Client
+	// Prefix is matched, case-insensitively and followed by a dash, against
+	// the names of orgs and apps and the hosts of routes.
+	Prefix string
+	// Cutoff spares everything created at or after it, so that runs still in
+	// progress are not disturbed.
+	Cutoff time.Time
+	// Domains are shared domains the suites create when they are missing,
+	// deleted once every route on them is planned to be deleted. It is
+	// empty unless asked for, since the foundation may provide them.
+	Domains []string
+}
+
+// New returns a janitor for the resources named with the name prefix of c
+// that are older than olderThan.
+func New(c config.Config, cc *capi.Client, olderThan time.Duration) Janitor {
+	return Janitor{
+		CloudController: cc,
+		Prefix:          c.GetNamePrefix(),
+		Cutoff:          time.Now().Add(-olderThan),
+	}
+}
+
+// Plan is what a janitor deletes, in the order it deletes it.
+type Plan struct {
+	Prefix        string
+	Cutoff        time.Time
+	Policies      []capi.Policy
+	Apps          []capi.App
+	Routes        []capi.Route
+	Organizations []capi.Organization
+	Domains       []capi.Domain
+}
+
+// Empty returns whether there is nothing to delete.
+func (p Plan) Empty() bool {
+	return len(p.Policies)+len(p.Apps)+len(p.Routes)+len(p.Organizations)+len(p.Domains) == 0
+}
+
+// String lists what the plan deletes, as a dry run.
+func (p Plan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "resources named %s-* created before %s:\n", p.Prefix, p.Cutoff.Format(time.RFC3339))
+	if p.Empty() {
+		b.WriteString("  nothing to delete\n")
+		return b.String()
+	}
+	for _, policy := range p.Policies {
+		fmt.Fprintf(&b, "  network policy  %s -> %s %s\n", policy.Source.ID, policy.Destination.ID, ports(policy))
+	}
+	for _, app := range p.Apps {
+		fmt.Fprintf(&b, "  app             %s (%s, created %s)\n", app.Name, app.GUID, app.CreatedAt.Format(time.RFC3339))
+	}
+	for _, route := range p.Routes {
+		fmt.Fprintf(&b, "  route           %s (%s, created %s)\n", route.URL, route.GUID, route.CreatedAt.Format(time.RFC3339))
+	}
+	for _, org := range p.Organizations {
+		fmt.Fprintf(&b, "  org             %s (%s, created %s)\n", org.Name, org.GUID, org.CreatedAt.Format(time.RFC3339))
+	}
+	for _, domain := range p.Domains {
+		fmt.Fprintf(&b, "  domain          %s (%s, created %s)\n", domain.Name, domain.GUID, domain.CreatedAt.Format(time.RFC3339))
+	}
+	return b.String()
+}
+
+func ports(policy capi.Policy) string {
+	p := policy.Destination.Ports
+	if p.Start == p.End {
+		return fmt.Sprintf("%s:%d", policy.Destination.Protocol, p.Start)
+	}
+	return fmt.Sprintf("%s:%d-%d", policy.Destination.Protocol, p.Start, p.End)
+}
+
+// Plan discovers what to delete.
+func (j Janitor) Plan() (Plan, error) {
+	plan := Plan{Prefix: j.Prefix, Cutoff: j.Cutoff}
+
+	apps, err := j.CloudController.Apps(nil)
+	if err != nil {
+		return Plan{}, fmt.Errorf("listing apps: %s", err)
+	}
+	appGUIDs := []string{}
+	for _, app := range apps {
+		if j.matches(app.Name, app.CreatedAt) {
+			plan.Apps = append(plan.Apps, app)
+			appGUIDs = append(appGUIDs, app.GUID)
+		}
+	}
+
+	plan.Policies, err = j.policies(appGUIDs)
+	if err != nil {
+		return Plan{}, fmt.Errorf("listing network policies: %s", err)
+	}
+
+	routes, err := j.CloudController.Routes(nil)
+	if err != nil {
+		return Plan{}, fmt.Errorf("listing routes: %s", err)
+	}
+	planned := map[string]bool{}
+	for _, route := range routes {
+		if j.matches(route.Host, route.CreatedAt) {
+			plan.Routes = append(plan.Routes, route)
+			planned[route.GUID] = true
+		}
+	}
+
+	orgs, err := j.CloudController.Organizations(nil)
+	if err != nil {
+		return Plan{}, fmt.Errorf("listing orgs: %s", err)
+	}
+	for _, org := range orgs {
+		if j.matches(org.Name, org.CreatedAt) {
+			plan.Organizations = append(plan.Organizations, org)
+		}
+	}
+
+	for _, name := range j.Domains {
+		domain, err := j.CloudController.DomainByName(name)
+		if _, ok := err.(*capi.NotFoundError); ok {
+			continue
+		}
+		if err != nil {
+			return Plan{}, fmt.Errorf("looking up domain %s: %s", name, err)
+		}
+		if !domain.CreatedAt.Before(j.Cutoff) {
+			continue
+		}
+		inUse := false
+		for _, route := range routes {
+			if route.Relationships.Domain.Data.GUID == domain.GUID && !planned[route.GUID] {
+				inUse = true
+			}
+		}
+		if !inUse {
+			plan.Domains = append(plan.Domains, domain)
+		}
+	}
+
+	return plan, nil
+}
+
+// policies returns the policies with any of appGUIDs as source or
+// destination, once each.
+func (j Janitor) policies(appGUIDs []string) ([]capi.Policy, error) {
+	policies := []capi.Policy{}
+	seen := map[capi.Policy]bool{}
+	for start := 0; start < len(appGUIDs); start += policyBatchSize {
+		end := start + policyBatchSize
+		if end > len(appGUIDs) {
+			end = len(appGUIDs)
+		}
+		batch, err := j.CloudController.Policies(appGUIDs[start:end]...)
+		if err != nil {
+			return nil, err
+		}
+		for _, policy := range batch {
+			if !seen[policy] {
+				seen[policy] = true
+				policies = append(policies, policy)
+			}
+		}
+	}
+	return policies, nil
+}
+
+func (j Janitor) matches(name string, createdAt time.Time) bool {
+	return strings.HasPrefix(strings.ToLower(name), strings.ToLower(j.Prefix)+"-") && createdAt.Before(j.Cutoff)
+}
+
+// Execute deletes what plan lists, policies first and domains last, so that
+// nothing is deleted while something else still depends on it. It carries
+// on past failures and returns them all at the end.
+func (j Janitor) Execute(plan Plan) error {
+	cc := j.CloudController
+	failures := []string{}
+	fail := func(what string, err error) {
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("deleting %s: %s", what, err))
+		}
+	}
+
+	if len(plan.Policies) > 0 {
+		fail(fmt.Sprintf("%d network policies", len(plan.Policies)), cc.DeletePolicies(plan.Policies))
+	}
+	for _, app := range plan.Apps {
+		fail("app "+app.Name, cc.DeleteApp(app.GUID))
+	}
+	for _, route := range plan.Routes {
+		fail("route "+route.URL, cc.DeleteRoute(route.GUID))
+	}
+	for _, org := range plan.Organizations {
+		fail("org "+org.Name, cc.DeleteOrganization(org.GUID))
+	}
+	for _, domain := range plan.Domains {
+		fail("domain "+domain.Name, cc.DeleteDomain(domain.GUID))
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%d deletions failed:\n  %s", len(failures), strings.Join(failures, "\n  "))
+	}
+	return nil
+}
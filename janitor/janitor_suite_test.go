@@ -0,0 +1,14 @@
+package janitor_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestJanitor(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Janitor Suite")
+}
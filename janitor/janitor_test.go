@@ -0,0 +1,159 @@
+package janitor_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"code.cloudfoundry.org/istio-acceptance-tests/fakecapi"
+	"code.cloudfoundry.org/istio-acceptance-tests/janitor"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Janitor", func() {
+	var (
+		server   *fakecapi.Server
+		cc       *capi.Client
+		j        janitor.Janitor
+		domain   capi.Domain
+		internal capi.Domain
+		space    capi.Space
+	)
+
+	appNames := func(apps []capi.App) []string {
+		names := []string{}
+		for _, app := range apps {
+			names = append(names, app.Name)
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		server = fakecapi.NewServer()
+		c := config.Config{CFApi: server.URL(), AdminUser: "admin", AdminPassword: "secret"}
+		cc = capi.NewClient(c)
+
+		domain = server.AddDomain("example.com", false)
+		internal = server.AddDomain(config.DefaultInternalAppsDomain, true)
+		space = server.AddSpace("IATS-1-SPACE-old")
+
+		old := server.AddApp("IATS-1-APP-old", space.GUID)
+		backend := server.AddApp("iats-2-backend-old", space.GUID)
+		server.AddApp("acceptance-app", space.GUID)
+		server.AddApp("IATSX-app", space.GUID)
+		server.AddRoute("iats-1-host-old", "", domain.GUID, space.GUID)
+		server.AddRoute("iats-2-backend-old", "", internal.GUID, space.GUID)
+		server.AddRoute("www", "", domain.GUID, space.GUID)
+		server.AddOrganization("IATS-1-ORG-old")
+		server.AddOrganization("system")
+		Expect(cc.CreatePolicies([]capi.Policy{capi.NewPolicy(old.GUID, backend.GUID, "tcp", 8080)})).To(Succeed())
+
+		time.Sleep(10 * time.Millisecond)
+		j = janitor.New(c, cc, 0)
+		time.Sleep(10 * time.Millisecond)
+
+		server.AddApp("IATS-3-APP-new", space.GUID)
+		server.AddRoute("iats-3-host-new", "", domain.GUID, space.GUID)
+		server.AddOrganization("IATS-3-ORG-new")
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("Plan", func() {
+		It("plans to delete what is named with the prefix and older than the cutoff", func() {
+			plan, err := j.Plan()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(appNames(plan.Apps)).To(ConsistOf("IATS-1-APP-old", "iats-2-backend-old"))
+			Expect(plan.Routes).To(HaveLen(2))
+			Expect([]string{plan.Routes[0].Host, plan.Routes[1].Host}).To(ConsistOf("iats-1-host-old", "iats-2-backend-old"))
+			Expect(plan.Organizations).To(HaveLen(1))
+			Expect(plan.Organizations[0].Name).To(Equal("IATS-1-ORG-old"))
+			Expect(plan.Policies).To(HaveLen(1))
+			Expect(plan.Domains).To(BeEmpty())
+		})
+
+		It("plans to delete the domains asked for once nothing else routes through them", func() {
+			j.Domains = []string{config.DefaultInternalAppsDomain, "example.com", "missing.example.com"}
+
+			plan, err := j.Plan()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan.Domains).To(HaveLen(1))
+			Expect(plan.Domains[0].GUID).To(Equal(internal.GUID))
+		})
+
+		It("spares domains created after the cutoff", func() {
+			j.Domains = []string{"new.internal"}
+			server.AddDomain("new.internal", true)
+
+			plan, err := j.Plan()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan.Domains).To(BeEmpty())
+		})
+
+		It("lists the plan as a dry run", func() {
+			plan, err := j.Plan()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(plan.String()).To(ContainSubstring("resources named IATS-* created before"))
+			Expect(plan.String()).To(ContainSubstring("IATS-1-APP-old"))
+			Expect(plan.String()).To(ContainSubstring("iats-1-host-old.example.com"))
+			Expect(plan.String()).To(ContainSubstring("IATS-1-ORG-old"))
+			Expect(plan.String()).To(ContainSubstring("tcp:8080"))
+			Expect(plan.String()).NotTo(ContainSubstring("new"))
+		})
+
+		It("says when there is nothing to delete", func() {
+			j.Prefix = "nothing"
+
+			plan, err := j.Plan()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan.Empty()).To(BeTrue())
+			Expect(plan.String()).To(ContainSubstring("nothing to delete"))
+		})
+	})
+
+	Describe("Execute", func() {
+		It("deletes policies, apps, routes, orgs and domains in that order", func() {
+			j.Domains = []string{config.DefaultInternalAppsDomain}
+			plan, err := j.Plan()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(j.Execute(plan)).To(Succeed())
+
+			Expect(server.Policies()).To(BeEmpty())
+			Expect(appNames(server.Apps())).To(ConsistOf("acceptance-app", "IATSX-app", "IATS-3-APP-new"))
+			Expect(server.Routes()).To(HaveLen(2))
+			Expect(server.Organizations()).To(HaveLen(2))
+			Expect(server.Domains()).To(Equal([]capi.Domain{domain}))
+
+			deletes := []string{}
+			for _, request := range server.Requests() {
+				if request.Method == "DELETE" || request.Path == "/networking/v1/external/policies/delete" {
+					deletes = append(deletes, request.Path)
+				}
+			}
+			Expect(deletes).To(HaveLen(7))
+			Expect(deletes[0]).To(Equal("/networking/v1/external/policies/delete"))
+			Expect(deletes[1]).To(HavePrefix("/v3/apps/"))
+			Expect(deletes[3]).To(HavePrefix("/v3/routes/"))
+			Expect(deletes[5]).To(HavePrefix("/v3/organizations/"))
+			Expect(deletes[6]).To(HavePrefix("/v3/domains/"))
+		})
+
+		It("carries on past failures and reports them", func() {
+			plan, err := j.Plan()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cc.DeleteApp(plan.Apps[0].GUID)).To(Succeed())
+
+			err = j.Execute(plan)
+			Expect(err).To(MatchError(ContainSubstring("1 deletions failed")))
+			Expect(err).To(MatchError(ContainSubstring("deleting app " + plan.Apps[0].Name)))
+			Expect(server.Organizations()).To(HaveLen(2))
+		})
+	})
+})
@@ -0,0 +1,14 @@
+package latency_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestLatency(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Latency Suite")
+}
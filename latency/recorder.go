@@ -0,0 +1,229 @@
+// Package latency measures how long route changes take to propagate through
+// the control plane to the istio routers and sidecars, and reports the
+// distribution across a test run.
+package latency
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Event string
+
+const (
+	MapRoute     Event = "map-route"
+	CreateRoute  Event = "create-route"
+	Destinations Event = "destinations"
+	UnmapRoute   Event = "unmap-route"
+	DeleteRoute  Event = "delete-route"
+)
+
+type Sample struct {
+	Event          Event         `json:"event"`
+	URL            string        `json:"url"`
+	ExpectedStatus int           `json:"expected_status"`
+	StartedAt      time.Time     `json:"started_at"`
+	Duration       time.Duration `json:"duration_ns"`
+	Converged      bool          `json:"converged"`
+}
+
+type Recorder struct {
+	lock    sync.Mutex
+	samples []Sample
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// WaitForStatus polls check until it returns the expected status or the
+// timeout expires, records how long that took against event, and returns an
+// error if the status was never seen.
+func (r *Recorder) WaitForStatus(event Event, url string, expectedStatus int, check func() (int, error), timeout, interval time.Duration) error {
+	return r.WaitForStatusSince(time.Now(), event, url, expectedStatus, check, timeout, interval)
+}
+
+// WaitForStatusSince is WaitForStatus for a change made at start, so that
+// changes made together can be waited for one after another without the
+// later ones being recorded as faster than they were. The timeout still
+// runs from when polling begins.
+func (r *Recorder) WaitForStatusSince(start time.Time, event Event, url string, expectedStatus int, check func() (int, error), timeout, interval time.Duration) error {
+	pollStart := time.Now()
+	var (
+		status int
+		err    error
+	)
+	for {
+		status, err = check()
+		if err == nil && status == expectedStatus {
+			r.Record(Sample{
+				Event:          event,
+				URL:            url,
+				ExpectedStatus: expectedStatus,
+				StartedAt:      start,
+				Duration:       time.Since(start),
+				Converged:      true,
+			})
+			return nil
+		}
+		if time.Since(pollStart) >= timeout {
+			break
+		}
+		time.Sleep(interval)
+	}
+
+	r.Record(Sample{
+		Event:          event,
+		URL:            url,
+		ExpectedStatus: expectedStatus,
+		StartedAt:      start,
+		Duration:       time.Since(start),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %s did not return %d within %s, last error: %s", event, url, expectedStatus, timeout, err)
+	}
+	return fmt.Errorf("%s: %s did not return %d within %s, last status: %d", event, url, expectedStatus, timeout, status)
+}
+
+func (r *Recorder) Record(sample Sample) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.samples = append(r.samples, sample)
+}
+
+func (r *Recorder) Samples() []Sample {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append([]Sample{}, r.samples...)
+}
+
+// WriteSamples writes the raw samples to path so that samples from parallel
+// test nodes can be merged with ReadSamples.
+func (r *Recorder) WriteSamples(path string) error {
+	encoded, err := json.Marshal(r.Samples())
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, encoded, 0644)
+}
+
+// ReadSamples reads and concatenates the samples in every file matching the
+// glob pattern.
+func ReadSamples(pattern string) ([]Sample, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := []Sample{}
+	for _, path := range paths {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var fileSamples []Sample
+		if err := json.Unmarshal(contents, &fileSamples); err != nil {
+			return nil, fmt.Errorf("reading %s: %s", path, err)
+		}
+		samples = append(samples, fileSamples...)
+	}
+	return samples, nil
+}
+
+type Summary struct {
+	Count    int     `json:"count"`
+	Timeouts int     `json:"timeouts"`
+	P50      float64 `json:"p50_seconds"`
+	P90      float64 `json:"p90_seconds"`
+	P99      float64 `json:"p99_seconds"`
+	Max      float64 `json:"max_seconds"`
+}
+
+type Report struct {
+	Suite       string            `json:"suite"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	Events      map[Event]Summary `json:"events"`
+	Samples     []Sample          `json:"samples"`
+}
+
+// NewReport aggregates samples per event. Percentiles only consider samples
+// that converged; samples that timed out are counted separately.
+func NewReport(suite string, samples []Sample) Report {
+	durations := map[Event][]float64{}
+	summaries := map[Event]Summary{}
+	for _, sample := range samples {
+		summary := summaries[sample.Event]
+		summary.Count++
+		if sample.Converged {
+			durations[sample.Event] = append(durations[sample.Event], sample.Duration.Seconds())
+		} else {
+			summary.Timeouts++
+		}
+		summaries[sample.Event] = summary
+	}
+
+	for event, summary := range summaries {
+		eventDurations := durations[event]
+		sort.Float64s(eventDurations)
+		summary.P50 = percentile(eventDurations, 50)
+		summary.P90 = percentile(eventDurations, 90)
+		summary.P99 = percentile(eventDurations, 99)
+		summary.Max = percentile(eventDurations, 100)
+		summaries[event] = summary
+	}
+
+	return Report{
+		Suite:       suite,
+		GeneratedAt: time.Now().UTC(),
+		Events:      summaries,
+		Samples:     samples,
+	}
+}
+
+func (r Report) Write(path string) error {
+	encoded, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, encoded, 0644)
+}
+
+func (r Report) String() string {
+	events := []string{}
+	for event := range r.Events {
+		events = append(events, string(event))
+	}
+	sort.Strings(events)
+
+	out := fmt.Sprintf("Route propagation latency (%s):\n", r.Suite)
+	for _, event := range events {
+		summary := r.Events[Event(event)]
+		out += fmt.Sprintf("  %-13s n=%-4d timeouts=%-3d p50=%.1fs p90=%.1fs p99=%.1fs max=%.1fs\n",
+			event, summary.Count, summary.Timeouts, summary.P50, summary.P90, summary.P99, summary.Max)
+	}
+	return out
+}
+
+// percentile returns the nearest-rank percentile of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
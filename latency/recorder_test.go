@@ -0,0 +1,128 @@
+package latency_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/latency"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Recorder", func() {
+	var recorder *latency.Recorder
+
+	BeforeEach(func() {
+		recorder = latency.NewRecorder()
+	})
+
+	Describe("WaitForStatus", func() {
+		It("records the time until the expected status is returned", func() {
+			calls := 0
+			check := func() (int, error) {
+				calls++
+				if calls < 3 {
+					return 404, nil
+				}
+				return 200, nil
+			}
+
+			err := recorder.WaitForStatus(latency.MapRoute, "http://app.example.com", 200, check, time.Second, 10*time.Millisecond)
+			Expect(err).NotTo(HaveOccurred())
+
+			samples := recorder.Samples()
+			Expect(samples).To(HaveLen(1))
+			Expect(samples[0].Event).To(Equal(latency.MapRoute))
+			Expect(samples[0].Converged).To(BeTrue())
+			Expect(samples[0].Duration).To(BeNumerically(">=", 20*time.Millisecond))
+		})
+
+		It("records the time since the change when given a start time", func() {
+			start := time.Now().Add(-time.Second)
+			check := func() (int, error) {
+				return 200, nil
+			}
+
+			err := recorder.WaitForStatusSince(start, latency.MapRoute, "http://app.example.com", 200, check, time.Second, 10*time.Millisecond)
+			Expect(err).NotTo(HaveOccurred())
+
+			samples := recorder.Samples()
+			Expect(samples).To(HaveLen(1))
+			Expect(samples[0].StartedAt).To(Equal(start))
+			Expect(samples[0].Duration).To(BeNumerically(">=", time.Second))
+		})
+
+		It("records a timeout and returns the last error", func() {
+			check := func() (int, error) {
+				return 0, errors.New("connection refused")
+			}
+
+			err := recorder.WaitForStatus(latency.UnmapRoute, "http://app.example.com", 404, check, 30*time.Millisecond, 10*time.Millisecond)
+			Expect(err).To(MatchError(ContainSubstring("connection refused")))
+
+			samples := recorder.Samples()
+			Expect(samples).To(HaveLen(1))
+			Expect(samples[0].Converged).To(BeFalse())
+		})
+	})
+
+	It("merges samples written by several recorders", func() {
+		dir, err := ioutil.TempDir("", "latency")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		other := latency.NewRecorder()
+		recorder.Record(latency.Sample{Event: latency.MapRoute, Duration: time.Second, Converged: true})
+		other.Record(latency.Sample{Event: latency.DeleteRoute, Duration: 2 * time.Second, Converged: true})
+
+		Expect(recorder.WriteSamples(filepath.Join(dir, "samples-1.json"))).To(Succeed())
+		Expect(other.WriteSamples(filepath.Join(dir, "samples-2.json"))).To(Succeed())
+
+		samples, err := latency.ReadSamples(filepath.Join(dir, "samples-*.json"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(samples).To(HaveLen(2))
+	})
+})
+
+var _ = Describe("Report", func() {
+	It("aggregates percentiles per event", func() {
+		samples := []latency.Sample{}
+		for i := 1; i <= 100; i++ {
+			samples = append(samples, latency.Sample{Event: latency.MapRoute, Duration: time.Duration(i) * time.Second, Converged: true})
+		}
+		samples = append(samples, latency.Sample{Event: latency.MapRoute, Duration: 240 * time.Second})
+		samples = append(samples, latency.Sample{Event: latency.UnmapRoute, Duration: 3 * time.Second, Converged: true})
+
+		report := latency.NewReport("routing", samples)
+
+		mapRoute := report.Events[latency.MapRoute]
+		Expect(mapRoute.Count).To(Equal(101))
+		Expect(mapRoute.Timeouts).To(Equal(1))
+		Expect(mapRoute.P50).To(Equal(50.0))
+		Expect(mapRoute.P90).To(Equal(90.0))
+		Expect(mapRoute.P99).To(Equal(99.0))
+		Expect(mapRoute.Max).To(Equal(100.0))
+
+		unmapRoute := report.Events[latency.UnmapRoute]
+		Expect(unmapRoute.Count).To(Equal(1))
+		Expect(unmapRoute.P99).To(Equal(3.0))
+	})
+
+	It("writes a JSON report", func() {
+		dir, err := ioutil.TempDir("", "latency")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		report := latency.NewReport("routing", []latency.Sample{{Event: latency.MapRoute, Duration: time.Second, Converged: true}})
+		path := filepath.Join(dir, "reports", "routing.json")
+		Expect(report.Write(path)).To(Succeed())
+
+		contents, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(ContainSubstring(`"p50_seconds": 1`))
+	})
+})
@@ -0,0 +1,267 @@
+package matchers
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/onsi/gomega/format"
+)
+
+// DefaultConfidence is the confidence level used when none is given: a
+// correctly weighted route fails the assertion one time in a thousand.
+const DefaultConfidence = 0.999
+
+// detectionPower is the probability with which SampleSize guarantees a
+// misweighted route is detected.
+const detectionPower = 0.95
+
+// minExpectedCount is the smallest expected count per destination for the
+// chi-square approximation to hold.
+const minExpectedCount = 5
+
+// HaveWeightedDistribution succeeds if the actual map of destination to
+// observed request count is consistent with the given weights, using a
+// chi-square goodness-of-fit test at DefaultConfidence. The weights are keyed
+// the same way as the observed counts, typically by app GUID.
+func HaveWeightedDistribution(weights map[string]int) *DistributionMatcher {
+	return &DistributionMatcher{Weights: weights, Confidence: DefaultConfidence}
+}
+
+type DistributionMatcher struct {
+	Weights    map[string]int
+	Confidence float64
+
+	observed  map[string]int
+	total     int
+	statistic float64
+	pValue    float64
+	reason    string
+}
+
+// WithConfidence sets the confidence level of the test, e.g. 0.999.
+func (m *DistributionMatcher) WithConfidence(confidence float64) *DistributionMatcher {
+	m.Confidence = confidence
+	return m
+}
+
+func (m *DistributionMatcher) Match(actual interface{}) (bool, error) {
+	observed, ok := actual.(map[string]int)
+	if !ok {
+		return false, fmt.Errorf("HaveWeightedDistribution expects a map[string]int of observed counts, got:\n%s", format.Object(actual, 1))
+	}
+	if m.Confidence <= 0 || m.Confidence >= 1 {
+		return false, fmt.Errorf("confidence must be between 0 and 1, got %v", m.Confidence)
+	}
+
+	totalWeight := 0
+	for _, weight := range m.Weights {
+		if weight < 0 {
+			return false, fmt.Errorf("weights must not be negative, got %d", weight)
+		}
+		totalWeight += weight
+	}
+	if totalWeight == 0 {
+		return false, fmt.Errorf("at least one weight must be positive")
+	}
+
+	m.observed = observed
+	m.total = 0
+	for _, count := range observed {
+		m.total += count
+	}
+	if m.total == 0 {
+		return false, fmt.Errorf("no requests were observed")
+	}
+
+	for destination, count := range observed {
+		if count > 0 && m.Weights[destination] == 0 {
+			m.reason = fmt.Sprintf("%d requests reached %q, which has no weight", count, destination)
+			return false, nil
+		}
+	}
+
+	m.statistic = 0
+	degreesOfFreedom := -1
+	for destination, weight := range m.Weights {
+		if weight == 0 {
+			continue
+		}
+		expected := float64(m.total) * float64(weight) / float64(totalWeight)
+		diff := float64(observed[destination]) - expected
+		m.statistic += diff * diff / expected
+		degreesOfFreedom++
+	}
+
+	if degreesOfFreedom == 0 {
+		return true, nil
+	}
+
+	m.pValue = chiSquareSurvival(m.statistic, degreesOfFreedom)
+	if m.pValue < 1-m.Confidence {
+		m.reason = fmt.Sprintf("chi-square = %.3f with %d degrees of freedom, p = %.6f < %.6f", m.statistic, degreesOfFreedom, m.pValue, 1-m.Confidence)
+		return false, nil
+	}
+	return true, nil
+}
+
+func (m *DistributionMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected the observed requests to match the route weights at %.2f%% confidence: %s\n%s",
+		m.Confidence*100, m.reason, m.distributionTable())
+}
+
+func (m *DistributionMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected the observed requests not to match the route weights at %.2f%% confidence (p = %.6f)\n%s",
+		m.Confidence*100, m.pValue, m.distributionTable())
+}
+
+func (m *DistributionMatcher) distributionTable() string {
+	destinations := []string{}
+	seen := map[string]bool{}
+	for destination := range m.Weights {
+		destinations = append(destinations, destination)
+		seen[destination] = true
+	}
+	for destination := range m.observed {
+		if !seen[destination] {
+			destinations = append(destinations, destination)
+		}
+	}
+	sort.Strings(destinations)
+
+	totalWeight := 0
+	for _, weight := range m.Weights {
+		totalWeight += weight
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "destination\tweight\texpected\tobserved\t")
+	for _, destination := range destinations {
+		weight := m.Weights[destination]
+		fmt.Fprintf(w, "%s\t%d\t%.1f%%\t%d (%.1f%%)\t\n",
+			destination,
+			weight,
+			100*float64(weight)/float64(totalWeight),
+			m.observed[destination],
+			100*float64(m.observed[destination])/float64(m.total),
+		)
+	}
+	fmt.Fprintf(w, "total\t%d\t\t%d\t\n", totalWeight, m.total)
+	w.Flush()
+	return buf.String()
+}
+
+// SampleSize returns the number of requests to send so that
+// HaveWeightedDistribution, at the given confidence, detects with 95% power
+// a route whose smallest share is off by half of its expected value. This is
+// what catches a split like 0/100 for a route weighted 1/9.
+func SampleSize(weights map[string]int, confidence float64) int {
+	totalWeight := 0
+	minWeight := 0
+	for _, weight := range weights {
+		if weight <= 0 {
+			continue
+		}
+		totalWeight += weight
+		if minWeight == 0 || weight < minWeight {
+			minWeight = weight
+		}
+	}
+	if totalWeight == 0 {
+		return 0
+	}
+
+	p := float64(minWeight) / float64(totalWeight)
+	n := float64(minExpectedCount) / p
+
+	if p < 1 {
+		delta := p / 2
+		zAlpha := normalQuantile(1 - (1-confidence)/2)
+		zBeta := normalQuantile(detectionPower)
+		shifted := p + delta
+		if p > 0.5 {
+			shifted = p - delta
+		}
+		powerN := math.Pow((zAlpha*math.Sqrt(p*(1-p))+zBeta*math.Sqrt(shifted*(1-shifted)))/delta, 2)
+		n = math.Max(n, powerN)
+	}
+
+	return int(math.Ceil(n))
+}
+
+// chiSquareSurvival returns P(X >= x) for a chi-square distribution with k
+// degrees of freedom.
+func chiSquareSurvival(x float64, k int) float64 {
+	if x <= 0 {
+		return 1
+	}
+	return upperIncompleteGamma(float64(k)/2, x/2)
+}
+
+// upperIncompleteGamma returns the regularized upper incomplete gamma
+// function Q(a, x), using the series expansion for x < a+1 and the continued
+// fraction otherwise (Numerical Recipes, 6.2).
+func upperIncompleteGamma(a, x float64) float64 {
+	const (
+		maxIterations = 1000
+		epsilon       = 1e-14
+		tiny          = 1e-300
+	)
+
+	lgamma, _ := math.Lgamma(a)
+	prefix := math.Exp(-x + a*math.Log(x) - lgamma)
+
+	if x < a+1 {
+		sum := 1 / a
+		term := sum
+		for n := 1; n < maxIterations; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*epsilon {
+				break
+			}
+		}
+		return 1 - sum*prefix
+	}
+
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for n := 1; n < maxIterations; n++ {
+		an := -float64(n) * (float64(n) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return prefix * h
+}
+
+// normalQuantile returns z such that P(Z <= z) = p for a standard normal Z.
+func normalQuantile(p float64) float64 {
+	low, high := -10.0, 10.0
+	for i := 0; i < 100; i++ {
+		mid := (low + high) / 2
+		if 0.5*math.Erfc(-mid/math.Sqrt2) < p {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2
+}
@@ -0,0 +1,86 @@
+package matchers_test
+
+import (
+	"code.cloudfoundry.org/istio-acceptance-tests/matchers"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HaveWeightedDistribution", func() {
+	var weights map[string]int
+
+	BeforeEach(func() {
+		weights = map[string]int{"app-1": 1, "app-2": 9}
+	})
+
+	It("accepts counts in proportion to the weights", func() {
+		Expect(map[string]int{"app-1": 100, "app-2": 900}).To(matchers.HaveWeightedDistribution(weights))
+	})
+
+	It("accepts counts within sampling noise of the weights", func() {
+		Expect(map[string]int{"app-1": 85, "app-2": 915}).To(matchers.HaveWeightedDistribution(weights))
+	})
+
+	It("rejects a route that sends nothing to the lightly weighted app", func() {
+		n := matchers.SampleSize(weights, matchers.DefaultConfidence)
+		Expect(map[string]int{"app-1": 0, "app-2": n}).NotTo(matchers.HaveWeightedDistribution(weights))
+	})
+
+	It("rejects a route that ignores the weights", func() {
+		Expect(map[string]int{"app-1": 500, "app-2": 500}).NotTo(matchers.HaveWeightedDistribution(weights))
+	})
+
+	It("rejects requests that reached an unweighted destination", func() {
+		Expect(map[string]int{"app-1": 100, "app-2": 900, "": 1}).NotTo(matchers.HaveWeightedDistribution(weights))
+	})
+
+	It("uses the given confidence level", func() {
+		// chi-square = 4.0 with one degree of freedom, so p ~= 0.0455
+		observed := map[string]int{"app-1": 60, "app-2": 40}
+		evenWeights := map[string]int{"app-1": 1, "app-2": 1}
+
+		Expect(observed).To(matchers.HaveWeightedDistribution(evenWeights).WithConfidence(0.99))
+		Expect(observed).NotTo(matchers.HaveWeightedDistribution(evenWeights).WithConfidence(0.9))
+	})
+
+	It("prints the observed distribution on failure", func() {
+		matcher := matchers.HaveWeightedDistribution(weights)
+		observed := map[string]int{"app-1": 0, "app-2": 1000}
+
+		success, err := matcher.Match(observed)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(success).To(BeFalse())
+
+		message := matcher.FailureMessage(observed)
+		Expect(message).To(ContainSubstring("chi-square"))
+		Expect(message).To(MatchRegexp(`app-1\s+1\s+10.0%\s+0 \(0.0%\)`))
+		Expect(message).To(MatchRegexp(`app-2\s+9\s+90.0%\s+1000 \(100.0%\)`))
+	})
+
+	It("errors when given something other than counts", func() {
+		_, err := matchers.HaveWeightedDistribution(weights).Match([]int{1, 9})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("errors when no requests were observed", func() {
+		_, err := matchers.HaveWeightedDistribution(weights).Match(map[string]int{})
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("SampleSize", func() {
+	It("needs more samples for stricter confidence levels", func() {
+		weights := map[string]int{"app-1": 1, "app-2": 9}
+		Expect(matchers.SampleSize(weights, 0.999)).To(BeNumerically(">", matchers.SampleSize(weights, 0.95)))
+	})
+
+	It("needs more samples for smaller shares", func() {
+		Expect(matchers.SampleSize(map[string]int{"a": 1, "b": 99}, 0.999)).
+			To(BeNumerically(">", matchers.SampleSize(map[string]int{"a": 1, "b": 9}, 0.999)))
+	})
+
+	It("expects at least five requests per destination", func() {
+		Expect(matchers.SampleSize(map[string]int{"a": 1, "b": 1}, 0.5)).To(BeNumerically(">=", 10))
+	})
+})
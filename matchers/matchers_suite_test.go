@@ -0,0 +1,14 @@
+package matchers_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestMatchers(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Matchers Suite")
+}
@@ -0,0 +1,261 @@
+// Package preflight checks that the foundation in a config is reachable and
+// set up the way the suites expect, so that a misconfigured run fails with a
+// reason before any suite starts.
+package preflight
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"code.cloudfoundry.org/istio-acceptance-tests/httpclient"
+)
+
+type Status string
+
+const (
+	Pass Status = "PASS"
+	// Warn is for something the suites can live with, such as a domain they
+	// create themselves.
+	Warn Status = "WARN"
+	Fail Status = "FAIL"
+)
+
+type Result struct {
+	Check  string
+	Status Status
+	Detail string
+}
+
+// Resolver looks up host names. *net.Resolver is one.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// Checker runs the checks against the foundation in Config.
+type Checker struct {
+	Config          config.Config
+	CloudController *capi.Client
+	Resolver        Resolver
+	// ProbeHost is a host on the istio domain that no app uses, resolved
+	// to check the wildcard DNS record.
+	ProbeHost string
+	// TLSAddress is where the wildcard certificate is fetched from, by
+	// default port 443 of ProbeHost.
+	TLSAddress string
+}
+
+func NewChecker(c config.Config, cc *capi.Client) Checker {
+	probeHost := fmt.Sprintf("iats-preflight-%s.%s", randomLabel(), c.IstioDomain)
+	return Checker{
+		Config:          c,
+		CloudController: cc,
+		Resolver:        net.DefaultResolver,
+		ProbeHost:       probeHost,
+		TLSAddress:      net.JoinHostPort(probeHost, "443"),
+	}
+}
+
+// errNotChecked is returned by checks of the Cloud Controller once logging
+// in to it has failed.
+var errNotChecked = errors.New("not checked, the admin login failed")
+
+// Run runs every check, in order.
+func (ch Checker) Run() []Result {
+	results := []Result{}
+	loggedIn := false
+	check := func(name string, needsLogin bool, run func() (Status, string)) {
+		if needsLogin && !loggedIn {
+			results = append(results, Result{Check: name, Status: Fail, Detail: errNotChecked.Error()})
+			return
+		}
+		status, detail := run()
+		results = append(results, Result{Check: name, Status: status, Detail: detail})
+	}
+
+	check("api endpoint", false, ch.checkAPI)
+	check("admin login", false, func() (Status, string) {
+		status, detail := ch.checkLogin()
+		loggedIn = status == Pass
+		return status, detail
+	})
+	check("istio domain", true, ch.checkIstioDomain)
+	check("internal apps domain", true, ch.checkInternalAppsDomain)
+	check("internal istio domain", true, ch.checkInternalIstioDomain)
+	check("cflinuxfs3 stack", true, ch.checkStack)
+	check("diego_docker feature flag", true, ch.checkDocker)
+	check("istio domain DNS", false, ch.checkDNS)
+	check("wildcard certificate", false, ch.checkCertificate)
+	return results
+}
+
+// Passed returns whether none of results failed.
+func Passed(results []Result) bool {
+	for _, result := range results {
+		if result.Status == Fail {
+			return false
+		}
+	}
+	return true
+}
+
+// Table formats results as a table with one check per line.
+func Table(results []Result) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tSTATUS\tDETAIL")
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", result.Check, result.Status, result.Detail)
+	}
+	w.Flush()
+	return b.String()
+}
+
+func (ch Checker) checkAPI() (Status, string) {
+	info, err := ch.CloudController.Info()
+	if err != nil {
+		return Fail, fmt.Sprintf("%s: %s", ch.Config.GetApiEndpoint(), err)
+	}
+	return Pass, fmt.Sprintf("%s, API version %s", ch.Config.GetApiEndpoint(), info.APIVersion)
+}
+
+func (ch Checker) checkLogin() (Status, string) {
+	if err := ch.CloudController.Login(); err != nil {
+		return Fail, err.Error()
+	}
+	return Pass, fmt.Sprintf("logged in as %s", ch.Config.GetAdminUser())
+}
+
+func (ch Checker) checkIstioDomain() (Status, string) {
+	if ch.Config.IstioDomain == "" {
+		return Fail, "cf_istio_domain is not set"
+	}
+	return ch.checkDomain(ch.Config.IstioDomain, false)
+}
+
+func (ch Checker) checkInternalAppsDomain() (Status, string) {
+	name := ch.Config.CFInternalAppsDomain
+	if name == "" {
+		name = config.DefaultInternalAppsDomain
+	}
+
+	domain, err := ch.CloudController.DomainByName(name)
+	if _, ok := err.(*capi.NotFoundError); ok && ch.Config.CFInternalAppsDomain == "" {
+		return Warn, fmt.Sprintf("%s does not exist, the suites create it", name)
+	}
+	return domainStatus(name, domain, err, true)
+}
+
+// checkInternalIstioDomain only warns about a missing internal istio domain
+// when the internal route specs are skipped without it: when internal_routes
+// is declared off, or detected, which finds the domain missing too.
+func (ch Checker) checkInternalIstioDomain() (Status, string) {
+	name := ch.Config.CFInternalIstioDomain
+	if name == "" {
+		name = config.DefaultInternalIstioDomain
+	}
+
+	domain, err := ch.CloudController.DomainByName(name)
+	status, detail := domainStatus(name, domain, err, true)
+	if status != Fail {
+		return status, detail
+	}
+
+	capabilities := ch.Config.GetCapabilities()
+	_, notFound := err.(*capi.NotFoundError)
+	switch {
+	case capabilities.InternalRoutes != nil && !*capabilities.InternalRoutes:
+		return Warn, detail + ", internal_routes is declared unavailable"
+	case capabilities.InternalRoutes == nil && capabilities.AutoDetect && (err == nil || notFound):
+		return Warn, detail + ", the internal route specs are skipped"
+	}
+	return status, detail
+}
+
+func (ch Checker) checkDomain(name string, internal bool) (Status, string) {
+	domain, err := ch.CloudController.DomainByName(name)
+	return domainStatus(name, domain, err, internal)
+}
+
+func domainStatus(name string, domain capi.Domain, err error, internal bool) (Status, string) {
+	if err != nil {
+		return Fail, err.Error()
+	}
+	if domain.Internal != internal {
+		if internal {
+			return Fail, fmt.Sprintf("%s is not an internal domain", name)
+		}
+		return Fail, fmt.Sprintf("%s is an internal domain", name)
+	}
+	return Pass, name
+}
+
+func (ch Checker) checkStack() (Status, string) {
+	stack, err := ch.CloudController.StackByName("cflinuxfs3")
+	if err != nil {
+		return Fail, err.Error()
+	}
+	return Pass, stack.Name
+}
+
+func (ch Checker) checkDocker() (Status, string) {
+	flag, err := ch.CloudController.FeatureFlag("diego_docker")
+	if err != nil {
+		return Fail, err.Error()
+	}
+	if !flag.Enabled {
+		return Warn, "disabled, the bookinfo suite enables it"
+	}
+	return Pass, "enabled"
+}
+
+// checkDNS resolves a host no app uses, which only resolves through a
+// wildcard record for the istio domain.
+func (ch Checker) checkDNS() (Status, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	addrs, err := ch.Resolver.LookupHost(ctx, ch.ProbeHost)
+	if err != nil {
+		return Fail, fmt.Sprintf("*.%s does not resolve: %s", ch.Config.IstioDomain, err)
+	}
+	return Pass, fmt.Sprintf("*.%s resolves to %s", ch.Config.IstioDomain, strings.Join(addrs, ", "))
+}
+
+// checkCertificate verifies the certificate the istio routers present for
+// the istio domain against wildcard_ca.
+func (ch Checker) checkCertificate() (Status, string) {
+	if ch.Config.WildcardCa == "" {
+		return Warn, "no wildcard_ca is set, the TLS specs are skipped"
+	}
+	tlsConfig, err := httpclient.TLSConfig(ch.Config)
+	if err != nil {
+		return Fail, err.Error()
+	}
+	tlsConfig.ServerName = ch.ProbeHost
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", ch.TLSAddress, tlsConfig)
+	if err != nil {
+		return Fail, err.Error()
+	}
+	defer conn.Close()
+
+	chain := conn.ConnectionState().VerifiedChains[0]
+	return Pass, fmt.Sprintf("%s, issued by %s", chain[0].Subject.CommonName, chain[len(chain)-1].Subject.CommonName)
+}
+
+func randomLabel() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,14 @@
+package preflight_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestPreflight(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Preflight Suite")
+}
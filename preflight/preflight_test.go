@@ -0,0 +1,213 @@
+package preflight_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"code.cloudfoundry.org/istio-acceptance-tests/fakecapi"
+	"code.cloudfoundry.org/istio-acceptance-tests/preflight"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return addrs, nil
+}
+
+var _ = Describe("Preflight", func() {
+	var (
+		server    *fakecapi.Server
+		tlsServer *httptest.Server
+		c         config.Config
+		resolver  fakeResolver
+	)
+
+	BeforeEach(func() {
+		server = fakecapi.NewServer()
+		server.Password = "secret"
+		server.AddDomain("example.com", false)
+		server.AddDomain(config.DefaultInternalAppsDomain, true)
+		server.AddDomain(config.DefaultInternalIstioDomain, true)
+		server.AddStack("cflinuxfs3")
+		server.SetFeatureFlag("diego_docker", true)
+
+		tlsServer = httptest.NewTLSServer(http.NotFoundHandler())
+		resolver = fakeResolver{"probe.example.com": {"10.0.0.1"}}
+
+		c = config.Config{
+			CFApi:         server.URL(),
+			AdminUser:     "admin",
+			AdminPassword: "secret",
+			IstioDomain:   "example.com",
+			WildcardCa:    string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw})),
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		tlsServer.Close()
+	})
+
+	run := func() []preflight.Result {
+		checker := preflight.NewChecker(c, capi.NewClient(c))
+		checker.Resolver = resolver
+		checker.ProbeHost = "probe.example.com"
+		checker.TLSAddress = tlsServer.Listener.Addr().String()
+		return checker.Run()
+	}
+
+	statuses := func(results []preflight.Result) map[string]preflight.Status {
+		statuses := map[string]preflight.Status{}
+		for _, result := range results {
+			statuses[result.Check] = result.Status
+		}
+		return statuses
+	}
+
+	It("passes on a foundation set up the way the suites expect", func() {
+		results := run()
+
+		Expect(results).To(HaveLen(9))
+		for _, result := range results {
+			Expect(result.Status).To(Equal(preflight.Pass), result.Check+": "+result.Detail)
+		}
+		Expect(preflight.Passed(results)).To(BeTrue())
+	})
+
+	It("fails every Cloud Controller check when the admin login fails", func() {
+		c.AdminPassword = "wrong"
+
+		results := run()
+		Expect(preflight.Passed(results)).To(BeFalse())
+		Expect(statuses(results)).To(Equal(map[string]preflight.Status{
+			"api endpoint":              preflight.Pass,
+			"admin login":               preflight.Fail,
+			"istio domain":              preflight.Fail,
+			"internal apps domain":      preflight.Fail,
+			"internal istio domain":     preflight.Fail,
+			"cflinuxfs3 stack":          preflight.Fail,
+			"diego_docker feature flag": preflight.Fail,
+			"istio domain DNS":          preflight.Pass,
+			"wildcard certificate":      preflight.Pass,
+		}))
+	})
+
+	It("reports what is missing from the foundation", func() {
+		c.CFInternalIstioDomain = "mesh.apps.internal"
+		c.IstioDomain = "istio.example.com"
+		resolver = fakeResolver{}
+
+		results := run()
+		Expect(statuses(results)).To(Equal(map[string]preflight.Status{
+			"api endpoint":              preflight.Pass,
+			"admin login":               preflight.Pass,
+			"istio domain":              preflight.Fail,
+			"internal apps domain":      preflight.Pass,
+			"internal istio domain":     preflight.Fail,
+			"cflinuxfs3 stack":          preflight.Pass,
+			"diego_docker feature flag": preflight.Pass,
+			"istio domain DNS":          preflight.Fail,
+			"wildcard certificate":      preflight.Pass,
+		}))
+		Expect(results[2].Detail).To(Equal(`domain "istio.example.com" not found`))
+		Expect(results[7].Detail).To(HavePrefix("*.istio.example.com does not resolve"))
+	})
+
+	Context("when the internal istio domain is missing", func() {
+		BeforeEach(func() {
+			c.CFInternalIstioDomain = "mesh.apps.internal"
+		})
+
+		It("warns when internal routes are declared unavailable", func() {
+			internalRoutes := false
+			c.Capabilities.InternalRoutes = &internalRoutes
+
+			results := run()
+			Expect(statuses(results)["internal istio domain"]).To(Equal(preflight.Warn))
+			Expect(preflight.Passed(results)).To(BeTrue())
+		})
+
+		It("warns when internal routes are detected", func() {
+			c.Capabilities.AutoDetect = true
+
+			results := run()
+			Expect(statuses(results)["internal istio domain"]).To(Equal(preflight.Warn))
+			Expect(results[4].Detail).To(ContainSubstring("the internal route specs are skipped"))
+		})
+
+		It("fails when internal routes are declared available", func() {
+			internalRoutes := true
+			c.Capabilities.AutoDetect = true
+			c.Capabilities.InternalRoutes = &internalRoutes
+
+			Expect(statuses(run())["internal istio domain"]).To(Equal(preflight.Fail))
+		})
+	})
+
+	It("fails when the routers present a certificate the wildcard CA did not issue", func() {
+		c.WildcardCa = otherCA()
+
+		results := run()
+		Expect(statuses(results)["wildcard certificate"]).To(Equal(preflight.Fail))
+		Expect(results[8].Detail).To(ContainSubstring("certificate signed by unknown authority"))
+	})
+
+	It("warns about what the suites can live with", func() {
+		server.SetFeatureFlag("diego_docker", false)
+		c.WildcardCa = ""
+
+		results := run()
+		Expect(preflight.Passed(results)).To(BeTrue())
+		Expect(statuses(results)["diego_docker feature flag"]).To(Equal(preflight.Warn))
+		Expect(statuses(results)["wildcard certificate"]).To(Equal(preflight.Warn))
+	})
+
+	It("formats the results as a table", func() {
+		table := preflight.Table([]preflight.Result{
+			{Check: "api endpoint", Status: preflight.Pass, Detail: "api.example.com"},
+			{Check: "wildcard certificate", Status: preflight.Fail, Detail: "x509: certificate signed by unknown authority"},
+		})
+
+		Expect(table).To(Equal("" +
+			"CHECK                 STATUS  DETAIL\n" +
+			"api endpoint          PASS    api.example.com\n" +
+			"wildcard certificate  FAIL    x509: certificate signed by unknown authority\n"))
+	})
+})
+
+func otherCA() string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "other-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"time"
 
+	"code.cloudfoundry.org/istio-acceptance-tests/capability"
 	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
 	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
 	. "github.com/onsi/ginkgo"
@@ -14,23 +15,24 @@ import (
 
 var _ = Describe("Automatic Retries: Internal Routes", func() {
 	var (
-		domain               string
-		internalDomain       string
-		proxy                string
-		flakyBackend         string
-		proxyDroplet         = "../assets/proxy.tgz"
-		flakyBackendApp      = "../assets/flaky-backend"
-		flakyBackendManifest = "../assets/flaky-backend/manifest.yml"
+		domain         string
+		internalDomain string
+		proxy          string
+		flakyBackend   string
+		proxyDroplet   = "../assets/proxy.tgz"
+		adminURL       string
 
 		internalRoute string
 		routeURL      string
 	)
 
 	BeforeEach(func() {
+		requireCapabilities(capability.InternalRoutes, capability.SidecarProxying)
+
 		domain = istioDomain()
 		internalDomain = internalIstioDomain()
 
-		proxy = generator.PrefixedRandomName("iats", "app1")
+		proxy = generator.PrefixedRandomName(Config.GetNamePrefix(), "app1")
 		Expect(cf.Cf("push", proxy,
 			"-d", domain,
 			"-s", "cflinuxfs3",
@@ -38,40 +40,35 @@ var _ = Describe("Automatic Retries: Internal Routes", func() {
 			"--droplet", proxyDroplet,
 			"-i", "1",
 			"-m", "32M",
-			"-k", "75M").Wait(defaultTimeout)).To(Exit(0))
+			"-k", "75M").Wait(pushTimeout)).To(Exit(0))
 
-		flakyBackend = generator.PrefixedRandomName("iats", "app2")
-		Expect(cf.Cf("push", flakyBackend,
-			"-s", "cflinuxfs3",
-			"-d", internalDomain,
-			"--hostname", flakyBackend,
-			"-f", flakyBackendManifest,
-			"-p", flakyBackendApp).Wait(defaultTimeout)).To(Exit(0))
+		flakyBackend = generator.PrefixedRandomName(Config.GetNamePrefix(), "app2")
+		adminURL = pushConfigurableBackend(flakyBackend, internalDomain)
 
 		Expect(cf.Cf("add-network-policy",
-			proxy, "--destination-app", flakyBackend).Wait(defaultTimeout)).To(Exit(0))
+			proxy, "--destination-app", flakyBackend).Wait(cfTimeout)).To(Exit(0))
 
 		internalRoute = fmt.Sprintf("%s.%s:8080", flakyBackend, internalDomain)
-		routeURL = fmt.Sprintf("http://%s.%s/proxy/%s", proxy, domain, internalRoute)
+		routeURL = fmt.Sprintf("%s://%s.%s/proxy/%s", scheme(), proxy, domain, internalRoute)
 
 		By("waiting for the app to start and become reachable")
 		Eventually(func() (int, error) {
 			return getStatusCode(routeURL)
-		}, defaultTimeout, time.Second).Should(Equal(http.StatusOK))
+		}, convergenceTimeout, time.Second).Should(Equal(http.StatusOK))
 	})
 
 	It("automatically retries for the client if a request fails", func() {
-		By("ensuring the request succeeds multiple times with a flaky backend")
-		res, err := http.Get(routeURL)
-		Expect(err).ToNot(HaveOccurred())
-		Expect(res.StatusCode).To(Equal(http.StatusOK))
+		By("failing the first two attempts at each request")
+		for i := 0; i < 3; i++ {
+			key := fmt.Sprintf("request-%d", i)
+			programBackend(adminURL, key, backendStep{Status: http.StatusServiceUnavailable, Count: 2})
 
-		res, err = http.Get(routeURL)
-		Expect(err).ToNot(HaveOccurred())
-		Expect(res.StatusCode).To(Equal(http.StatusOK))
+			res, err := httpClient.Get(fmt.Sprintf("%s/%s", routeURL, key))
+			Expect(err).ToNot(HaveOccurred())
+			res.Body.Close()
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
 
-		res, err = http.Get(routeURL)
-		Expect(err).ToNot(HaveOccurred())
-		Expect(res.StatusCode).To(Equal(http.StatusOK))
+			Expect(backendAttempts(adminURL, key).Attempts).To(Equal(3), "expected two retries after two failures")
+		}
 	})
 })
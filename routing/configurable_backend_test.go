@@ -0,0 +1,158 @@
+package routing_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+)
+
+const (
+	configurableBackendApp      = "../assets/configurable-backend"
+	configurableBackendManifest = "../assets/configurable-backend/manifest.yml"
+)
+
+// backendStep programs how the configurable backend answers one request.
+type backendStep struct {
+	Status   int    `json:"status,omitempty"`
+	Delay    string `json:"delay,omitempty"`
+	Reset    bool   `json:"reset,omitempty"`
+	Abort    bool   `json:"abort,omitempty"`
+	Chunks   int    `json:"chunks,omitempty"`
+	Interval string `json:"interval,omitempty"`
+	Count    int    `json:"count,omitempty"`
+}
+
+type backendAttempt struct {
+	Number int    `json:"number"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Action string `json:"action"`
+}
+
+type backendKey struct {
+	Key      string           `json:"key"`
+	Attempts int              `json:"attempts"`
+	Log      []backendAttempt `json:"log"`
+}
+
+// pushConfigurableBackend pushes the configurable backend with a route on
+// domain, and a route on the istio domain for its admin API. It returns the
+// base URL of the admin API.
+func pushConfigurableBackend(name, domain string) string {
+	Expect(cf.Cf("push", name,
+		"-s", "cflinuxfs3",
+		"-d", domain,
+		"--hostname", name,
+		"-f", configurableBackendManifest,
+		"-p", configurableBackendApp).Wait(pushTimeout)).To(Exit(0))
+
+	if domain != istioDomain() {
+		Expect(cf.Cf("map-route", name, istioDomain(), "--hostname", name).Wait(cfTimeout)).To(Exit(0))
+	}
+
+	return fmt.Sprintf("%s://%s.%s/admin", scheme(), name, istioDomain())
+}
+
+func programBackend(adminURL, key string, steps ...backendStep) {
+	body, err := json.Marshal(steps)
+	Expect(err).NotTo(HaveOccurred())
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("%s/keys/%s/steps", adminURL, key), bytes.NewReader(body))
+	Expect(err).NotTo(HaveOccurred())
+
+	res, err := httpClient.Do(req)
+	Expect(err).NotTo(HaveOccurred())
+	defer res.Body.Close()
+	Expect(res.StatusCode).To(Equal(http.StatusOK))
+}
+
+func backendAttempts(adminURL, key string) backendKey {
+	res, err := httpClient.Get(fmt.Sprintf("%s/keys/%s", adminURL, key))
+	Expect(err).NotTo(HaveOccurred())
+	defer res.Body.Close()
+	Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+	var state backendKey
+	Expect(json.NewDecoder(res.Body).Decode(&state)).To(Succeed())
+	return state
+}
+
+type relayResponse struct {
+	Status     int     `json:"status"`
+	Body       string  `json:"body"`
+	Error      string  `json:"error"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// relayRequest asks the configurable backend behind adminURL to send a
+// request itself, so that it passes through the backend's sidecar.
+func relayRequest(adminURL, method, url string) relayResponse {
+	body, err := json.Marshal(map[string]string{"method": method, "url": url})
+	Expect(err).NotTo(HaveOccurred())
+
+	res, err := httpClient.Post(adminURL+"/request", "application/json", bytes.NewReader(body))
+	Expect(err).NotTo(HaveOccurred())
+	defer res.Body.Close()
+	Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+	var relayed relayResponse
+	Expect(json.NewDecoder(res.Body).Decode(&relayed)).To(Succeed())
+	return relayed
+}
+
+type instanceFailure struct {
+	backendStep
+	Duration string `json:"duration,omitempty"`
+}
+
+// failInstance makes one instance of the configurable backend fail every
+// request. Admin requests are load balanced like any other, so it retries
+// until the request reaches the right instance.
+func failInstance(adminURL, index string, failure instanceFailure) {
+	body, err := json.Marshal(failure)
+	Expect(err).NotTo(HaveOccurred())
+
+	Eventually(func() (int, error) {
+		req, err := http.NewRequest("PUT", fmt.Sprintf("%s/instances/%s/failure", adminURL, index), bytes.NewReader(body))
+		if err != nil {
+			return 0, err
+		}
+		res, err := httpClient.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		res.Body.Close()
+		return res.StatusCode, nil
+	}, convergenceTimeout, 100*time.Millisecond).Should(Equal(http.StatusOK))
+}
+
+type failureReport struct {
+	StartedAt      time.Time   `json:"started_at"`
+	Until          time.Time   `json:"until"`
+	FailedRequests []time.Time `json:"failed_requests"`
+}
+
+// instanceFailureReport fetches when an instance started failing and when it
+// received requests since, retrying until the request reaches the instance.
+func instanceFailureReport(adminURL, index string) failureReport {
+	var report failureReport
+	Eventually(func() (int, error) {
+		res, err := httpClient.Get(fmt.Sprintf("%s/instances/%s/failure", adminURL, index))
+		if err != nil {
+			return 0, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode == http.StatusOK {
+			err = json.NewDecoder(res.Body).Decode(&report)
+		}
+		return res.StatusCode, err
+	}, convergenceTimeout, 100*time.Millisecond).Should(Equal(http.StatusOK))
+	return report
+}
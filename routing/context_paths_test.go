@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"time"
 
+	"code.cloudfoundry.org/istio-acceptance-tests/latency"
 	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
 	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
 
@@ -31,10 +32,10 @@ var _ = Describe("Context Paths", func() {
 
 	BeforeEach(func() {
 		domain = istioDomain()
-		hostname = generator.PrefixedRandomName("IATS", "host")
+		hostname = generator.PrefixedRandomName(Config.GetNamePrefix(), "host")
 		contextPath = "/nothing/matters"
 
-		app = generator.PrefixedRandomName("IATS", "APP")
+		app = generator.PrefixedRandomName(Config.GetNamePrefix(), "APP")
 		Expect(cf.Cf("push", app,
 			"-n", hostname,
 			"-d", domain,
@@ -43,13 +44,13 @@ var _ = Describe("Context Paths", func() {
 			"--droplet", helloRoutingDroplet,
 			"-i", "1",
 			"-m", "16M",
-			"-k", "75M").Wait(defaultTimeout)).To(Exit(0))
+			"-k", "75M").Wait(pushTimeout)).To(Exit(0))
 	})
 
 	Context("when using a context path", func() {
 		It("should route to the appropriate app", func() {
-			baseURL := fmt.Sprintf("http://%s.%s", hostname, domain)
-			contextPathURL := fmt.Sprintf("http://%s.%s%s", hostname, domain, contextPath)
+			baseURL := fmt.Sprintf("%s://%s.%s", scheme(), hostname, domain)
+			contextPathURL := fmt.Sprintf("%s://%s.%s%s", scheme(), hostname, domain, contextPath)
 
 			Consistently(func() (int, error) {
 				return getStatusCode(baseURL)
@@ -57,9 +58,9 @@ var _ = Describe("Context Paths", func() {
 
 			Eventually(func() (int, error) {
 				return getStatusCode(contextPathURL)
-			}, defaultTimeout, time.Second).Should(Equal(http.StatusOK))
+			}, convergenceTimeout, time.Second).Should(Equal(http.StatusOK))
 
-			res, err := http.Get(contextPathURL)
+			res, err := httpClient.Get(contextPathURL)
 			Expect(err).ToNot(HaveOccurred())
 
 			body, err := ioutil.ReadAll(res.Body)
@@ -76,37 +77,37 @@ var _ = Describe("Context Paths", func() {
 	Context("when manipulating a route with a context path", func() {
 		It("routes continues to route", func() {
 			By("unmapping the route")
-			contextPathURL := fmt.Sprintf("http://%s.%s%s", hostname, domain, contextPath)
+			contextPathURL := fmt.Sprintf("%s://%s.%s%s", scheme(), hostname, domain, contextPath)
 
 			Eventually(func() (int, error) {
 				return getStatusCode(contextPathURL)
-			}, defaultTimeout, time.Second).Should(Equal(http.StatusOK))
+			}, convergenceTimeout, time.Second).Should(Equal(http.StatusOK))
 
+			unmapped := time.Now()
 			Expect(cf.Cf("unmap-route", app, domain,
 				"--hostname", hostname,
-				"--path", contextPath).Wait(defaultTimeout)).To(Exit(0))
+				"--path", contextPath).Wait(cfTimeout)).To(Exit(0))
 
-			Eventually(func() (int, error) {
-				return getStatusCode(contextPathURL)
-			}, defaultTimeout, time.Second).Should(Equal(http.StatusNotFound))
+			waitForPropagationSince(unmapped, latency.UnmapRoute, contextPathURL, http.StatusNotFound)
 
+			// The route already returns 404 once unmapped, so deleting it is
+			// not timed.
 			By("deleting the route")
 			Expect(cf.Cf("delete-route", domain,
 				"-f",
 				"--hostname", hostname,
-				"--path", contextPath).Wait(defaultTimeout)).To(Exit(0))
+				"--path", contextPath).Wait(cfTimeout)).To(Exit(0))
 
 			Eventually(func() (int, error) {
 				return getStatusCode(contextPathURL)
-			}, defaultTimeout, time.Second).Should(Equal(http.StatusNotFound))
+			}, convergenceTimeout, time.Second).Should(Equal(http.StatusNotFound))
 
 			By("verifying context path still routes to best match")
+			mapped := time.Now()
 			Expect(cf.Cf("map-route", app, domain,
-				"--hostname", hostname).Wait(defaultTimeout)).To(Exit(0))
+				"--hostname", hostname).Wait(cfTimeout)).To(Exit(0))
 
-			Eventually(func() (int, error) {
-				return getStatusCode(contextPathURL)
-			}, defaultTimeout, time.Second).Should(Equal(http.StatusOK))
+			waitForPropagationSince(mapped, latency.MapRoute, contextPathURL, http.StatusOK)
 		})
 	})
 
@@ -114,22 +115,20 @@ var _ = Describe("Context Paths", func() {
 		It("routes successfully", func() {
 			By("mapping a second context path")
 			contextPathTwo := "/nothing/matters/again"
+			mapped := time.Now()
 			Expect(cf.Cf("map-route", app, domain,
 				"--hostname", hostname,
-				"--path", contextPathTwo).Wait(defaultTimeout)).To(Exit(0))
+				"--path", contextPathTwo).Wait(cfTimeout)).To(Exit(0))
 
-			Eventually(func() (int, error) {
-				return getStatusCode(fmt.Sprintf("http://%s.%s%s", hostname, domain, contextPathTwo))
-			}, defaultTimeout, time.Second).Should(Equal(http.StatusOK))
+			waitForPropagationSince(mapped, latency.MapRoute, fmt.Sprintf("%s://%s.%s%s", scheme(), hostname, domain, contextPathTwo), http.StatusOK)
 
 			By("mapping a second hostname")
-			otherHostname := generator.PrefixedRandomName("IATS", "otherhost")
+			otherHostname := generator.PrefixedRandomName(Config.GetNamePrefix(), "otherhost")
+			mapped = time.Now()
 			Expect(cf.Cf("map-route", app, domain,
-				"--hostname", otherHostname).Wait(defaultTimeout)).To(Exit(0))
+				"--hostname", otherHostname).Wait(cfTimeout)).To(Exit(0))
 
-			Eventually(func() (int, error) {
-				return getStatusCode(fmt.Sprintf("http://%s.%s", otherHostname, domain))
-			}, defaultTimeout, time.Second).Should(Equal(http.StatusOK))
+			waitForPropagationSince(mapped, latency.MapRoute, fmt.Sprintf("%s://%s.%s", scheme(), otherHostname, domain), http.StatusOK)
 		})
 	})
 
@@ -142,7 +141,7 @@ var _ = Describe("Context Paths", func() {
 		BeforeEach(func() {
 			otherContextPath = "/everything/matters"
 
-			otherApp = generator.PrefixedRandomName("IATS", "APP")
+			otherApp = generator.PrefixedRandomName(Config.GetNamePrefix(), "APP")
 			Expect(cf.Cf("push", otherApp,
 				"-n", hostname,
 				"-d", domain,
@@ -151,14 +150,14 @@ var _ = Describe("Context Paths", func() {
 				"--droplet", helloRoutingDroplet,
 				"-i", "1",
 				"-m", "16M",
-				"-k", "75M").Wait(defaultTimeout)).To(Exit(0))
+				"-k", "75M").Wait(pushTimeout)).To(Exit(0))
 		})
 
 		Context("when multiple apps have the same hostname", func() {
 			It("routes succesfully to each app", func() {
 				var instanceGuid string
 				Eventually(func() (int, error) {
-					res, err := http.Get(fmt.Sprintf("http://%s.%s%s", hostname, domain, otherContextPath))
+					res, err := httpClient.Get(fmt.Sprintf("%s://%s.%s%s", scheme(), hostname, domain, otherContextPath))
 					if err != nil {
 						return 0, err
 					}
@@ -176,10 +175,10 @@ var _ = Describe("Context Paths", func() {
 					instanceGuid = appResponse.InstanceGUID
 
 					return res.StatusCode, nil
-				}, defaultTimeout, time.Second).Should(Equal(http.StatusOK))
+				}, convergenceTimeout, time.Second).Should(Equal(http.StatusOK))
 
 				Consistently(func() (bool, error) {
-					res, err := http.Get(fmt.Sprintf("http://%s.%s%s", hostname, domain, contextPath))
+					res, err := httpClient.Get(fmt.Sprintf("%s://%s.%s%s", scheme(), hostname, domain, contextPath))
 					if err != nil {
 						return false, err
 					}
@@ -204,11 +203,11 @@ var _ = Describe("Context Paths", func() {
 			It("load balances between them", func() {
 				Expect(cf.Cf("map-route", otherApp, domain,
 					"--hostname", hostname,
-					"--path", contextPath).Wait(defaultTimeout)).To(Exit(0))
+					"--path", contextPath).Wait(cfTimeout)).To(Exit(0))
 
 				var instanceGuid string
 				Eventually(func() (int, error) {
-					res, err := http.Get(fmt.Sprintf("http://%s.%s%s", hostname, domain, contextPath))
+					res, err := httpClient.Get(fmt.Sprintf("%s://%s.%s%s", scheme(), hostname, domain, contextPath))
 					if err != nil {
 						return 0, err
 					}
@@ -226,10 +225,10 @@ var _ = Describe("Context Paths", func() {
 					instanceGuid = appResponse.InstanceGUID
 
 					return res.StatusCode, nil
-				}, defaultTimeout, time.Second).Should(Equal(http.StatusOK))
+				}, convergenceTimeout, time.Second).Should(Equal(http.StatusOK))
 
 				Eventually(func() (bool, error) {
-					res, err := http.Get(fmt.Sprintf("http://%s.%s%s", hostname, domain, contextPath))
+					res, err := httpClient.Get(fmt.Sprintf("%s://%s.%s%s", scheme(), hostname, domain, contextPath))
 					if err != nil {
 						return false, err
 					}
@@ -246,7 +245,7 @@ var _ = Describe("Context Paths", func() {
 					}
 
 					return instanceGuid != appResponse.InstanceGUID, nil
-				}, defaultTimeout, time.Second).Should(BeTrue())
+				}, convergenceTimeout, time.Second).Should(BeTrue())
 			})
 		})
 	})
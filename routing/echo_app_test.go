@@ -0,0 +1,63 @@
+package routing_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+)
+
+const (
+	echoApp         = "../assets/echo-app"
+	echoAppManifest = "../assets/echo-app/manifest.yml"
+)
+
+// echoResponse is what the echo app reports about the request it received.
+type echoResponse struct {
+	Method               string              `json:"method"`
+	Host                 string              `json:"host"`
+	Path                 string              `json:"path"`
+	Proto                string              `json:"proto"`
+	RemoteAddr           string              `json:"remote_addr"`
+	Headers              http.Header         `json:"headers"`
+	ForwardedClientCerts []map[string]string `json:"forwarded_client_certs"`
+	InstanceIndex        string              `json:"instance_index"`
+	InstanceGUID         string              `json:"instance_guid"`
+	InstanceIP           string              `json:"instance_ip"`
+}
+
+func pushEchoApp(name, domain string) {
+	Expect(cf.Cf("push", name,
+		"-s", "cflinuxfs3",
+		"-d", domain,
+		"--hostname", name,
+		"-f", echoAppManifest,
+		"-p", echoApp).Wait(pushTimeout)).To(Exit(0))
+}
+
+// getEcho requests url and decodes the echo app's description of the
+// request. It fails unless the echo app answered.
+func getEcho(url string) echoResponse {
+	res, err := httpClient.Get(url)
+	Expect(err).NotTo(HaveOccurred())
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(res.StatusCode).To(Equal(http.StatusOK), fmt.Sprintf("GET %s: %s", url, body))
+
+	return decodeEcho(bytes.NewReader(body))
+}
+
+func decodeEcho(body io.Reader) echoResponse {
+	var echo echoResponse
+	Expect(json.NewDecoder(body).Decode(&echo)).To(Succeed())
+	return echo
+}
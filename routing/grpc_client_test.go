@@ -0,0 +1,224 @@
+package routing_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/httpclient"
+	"golang.org/x/net/http2"
+)
+
+const (
+	grpcEchoApp         = "../assets/grpc-echo"
+	grpcEchoAppManifest = "../assets/grpc-echo/manifest.yml"
+	grpcContentType     = "application/grpc+json"
+)
+
+// grpcRequest and grpcResponse are the messages of the grpc-echo app's
+// echo.Echo service, which encodes them as JSON.
+type grpcRequest struct {
+	Message       string `json:"message"`
+	Count         int    `json:"count,omitempty"`
+	StatusCode    int    `json:"status_code,omitempty"`
+	StatusMessage string `json:"status_message,omitempty"`
+	Target        string `json:"target,omitempty"`
+	Method        string `json:"method,omitempty"`
+}
+
+type grpcResponse struct {
+	Message       string       `json:"message"`
+	Sequence      int          `json:"sequence"`
+	InstanceIndex string       `json:"instance_index"`
+	InstanceGUID  string       `json:"instance_guid"`
+	Relayed       *grpcRelayed `json:"relayed"`
+	Proto         string       `json:"proto"`
+}
+
+type grpcRelayed struct {
+	Responses     []grpcResponse `json:"responses"`
+	StatusCode    int            `json:"status_code"`
+	StatusMessage string         `json:"status_message"`
+	Error         string         `json:"error"`
+}
+
+type grpcStatus struct {
+	Code    int
+	Message string
+}
+
+// grpcClient makes gRPC calls to the grpc-echo app and counts the
+// connections it opens.
+type grpcClient struct {
+	client *http.Client
+	dials  int32
+}
+
+// newH2CClient returns a client that speaks HTTP/2 with prior knowledge over
+// cleartext connections.
+func newH2CClient() *grpcClient {
+	c := &grpcClient{}
+	dial := c.countingDialer()
+	c.client = &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(context.Background(), network, addr)
+			},
+		},
+	}
+	return c
+}
+
+// newTLSClient returns a client that negotiates HTTP/2 with ALPN, with the
+// TLS settings from the config.
+func newTLSClient() (*grpcClient, error) {
+	tlsConfig, err := httpclient.TLSConfig(Config)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &grpcClient{}
+	transport := &http.Transport{
+		TLSClientConfig: tlsConfig,
+		DialContext:     c.countingDialer(),
+	}
+	if err := http2.ConfigureTransport(transport); err != nil {
+		return nil, err
+	}
+	c.client = &http.Client{
+		Timeout:   requestTimeout,
+		Transport: transport,
+	}
+	return c, nil
+}
+
+func (c *grpcClient) countingDialer() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		atomic.AddInt32(&c.dials, 1)
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
+
+func (c *grpcClient) Dials() int {
+	return int(atomic.LoadInt32(&c.dials))
+}
+
+// Call makes a call to method of the echo.Echo service at baseURL, sending
+// requests and reading every response.
+func (c *grpcClient) Call(baseURL, method string, requests ...grpcRequest) ([]grpcResponse, grpcStatus, *http.Response, error) {
+	var body bytes.Buffer
+	for _, req := range requests {
+		if err := writeGRPCMessage(&body, req); err != nil {
+			return nil, grpcStatus{}, nil, err
+		}
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/echo.Echo/%s", baseURL, method), &body)
+	if err != nil {
+		return nil, grpcStatus{}, nil, err
+	}
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, grpcStatus{}, nil, err
+	}
+	defer res.Body.Close()
+
+	responses := []grpcResponse{}
+	for {
+		var resp grpcResponse
+		ok, err := readGRPCMessage(res.Body, &resp)
+		if err != nil {
+			return nil, grpcStatus{}, res, err
+		}
+		if !ok {
+			break
+		}
+		responses = append(responses, resp)
+	}
+
+	status, err := responseGRPCStatus(res)
+	return responses, status, res, err
+}
+
+// Do sends a gRPC request whose body may still be being written, e.g. by a
+// bidirectional stream.
+func (c *grpcClient) Do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Content-Type", grpcContentType)
+	req.Header.Set("TE", "trailers")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.ProtoMajor != 2 {
+		res.Body.Close()
+		return nil, fmt.Errorf("the gRPC call was made over %s", res.Proto)
+	}
+	return res, nil
+}
+
+// responseGRPCStatus reads the status from the trailers, or from the headers
+// of a trailers-only response. The body must have been read to the end.
+func responseGRPCStatus(res *http.Response) (grpcStatus, error) {
+	code := res.Trailer.Get("Grpc-Status")
+	message := res.Trailer.Get("Grpc-Message")
+	if code == "" {
+		code = res.Header.Get("Grpc-Status")
+		message = res.Header.Get("Grpc-Message")
+	}
+	if code == "" {
+		return grpcStatus{}, fmt.Errorf("no grpc-status in response with HTTP status %d", res.StatusCode)
+	}
+
+	status, err := strconv.Atoi(code)
+	if err != nil {
+		return grpcStatus{}, fmt.Errorf("invalid grpc-status %q", code)
+	}
+	return grpcStatus{Code: status, Message: message}, nil
+}
+
+func writeGRPCMessage(w io.Writer, message interface{}) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	var prefix [5]byte
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(payload)))
+	_, err = w.Write(append(prefix[:], payload...))
+	return err
+}
+
+// readGRPCMessage reads one length-prefixed message, returning false at the
+// end of the stream.
+func readGRPCMessage(r io.Reader, message interface{}) (bool, error) {
+	var prefix [5]byte
+	_, err := io.ReadFull(r, prefix[:])
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("reading message prefix: %s", err)
+	}
+	if prefix[0] != 0 {
+		return false, fmt.Errorf("compressed gRPC messages are not supported")
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return false, fmt.Errorf("reading message: %s", err)
+	}
+	return true, json.Unmarshal(payload, message)
+}
@@ -0,0 +1,240 @@
+package routing_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capability"
+	"code.cloudfoundry.org/istio-acceptance-tests/helpers"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+)
+
+const (
+	grpcInstances = 3
+	grpcCalls     = 30
+)
+
+// grpcCaller makes a unary or server streaming call to the grpc-echo app
+// under test.
+type grpcCaller func(method string, req grpcRequest) ([]grpcResponse, grpcStatus, error)
+
+// The grpc-echo app and grpcClient approximate the gRPC wire protocol: calls
+// are length-prefixed messages on HTTP/2 streams that end with grpc-status
+// trailers, but the messages are JSON rather than protobuf. The suite covers
+// how the routers and sidecars carry that framing, not a grpc-go client or
+// server.
+var _ = Describe("HTTP/2 and gRPC", func() {
+	var (
+		server string
+		call   grpcCaller
+	)
+
+	BeforeEach(func() {
+		server = generator.PrefixedRandomName(Config.GetNamePrefix(), "grpc")
+	})
+
+	itServesGRPC := func() {
+		It("serves unary calls over HTTP/2 end to end", func() {
+			responses, status, err := call("Unary", grpcRequest{Message: "hello"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(status).To(Equal(grpcStatus{Code: 0}))
+			Expect(responses).To(HaveLen(1))
+			Expect(responses[0].Message).To(Equal("hello"))
+			Expect(responses[0].Proto).To(Equal("HTTP/2.0"))
+		})
+
+		It("serves server streaming calls", func() {
+			responses, status, err := call("ServerStream", grpcRequest{Message: "stream", Count: 5})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(status).To(Equal(grpcStatus{Code: 0}))
+			Expect(responses).To(HaveLen(5))
+			for i, response := range responses {
+				Expect(response.Message).To(Equal("stream"))
+				Expect(response.Sequence).To(Equal(i + 1))
+			}
+		})
+
+		It("propagates the gRPC status of failed calls", func() {
+			responses, status, err := call("Unary", grpcRequest{Message: "fail", StatusCode: 5, StatusMessage: "no such greeting"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(responses).To(BeEmpty())
+			Expect(status).To(Equal(grpcStatus{Code: 5, Message: "no such greeting"}))
+
+			responses, status, err = call("ServerStream", grpcRequest{Message: "fail", Count: 2, StatusCode: 9, StatusMessage: "stream aborted"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(responses).To(HaveLen(2))
+			Expect(status).To(Equal(grpcStatus{Code: 9, Message: "stream aborted"}))
+		})
+
+		It("balances individual calls, not connections, across instances", func() {
+			Eventually(func() map[string]int {
+				instances := map[string]int{}
+				for i := 0; i < grpcCalls; i++ {
+					responses, status, err := call("Unary", grpcRequest{Message: "balance"})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(status.Code).To(BeZero())
+					Expect(responses).To(HaveLen(1))
+					instances[responses[0].InstanceGUID]++
+				}
+				fmt.Fprintf(GinkgoWriter, "calls per instance: %v\n", instances)
+				return instances
+			}, convergenceTimeout).Should(HaveLen(grpcInstances))
+		})
+	}
+
+	Context("on the external istio domain", func() {
+		var (
+			serverURL string
+			client    *grpcClient
+		)
+
+		BeforeEach(func() {
+			pushGRPCEcho(server, istioDomain(), grpcInstances)
+			serverURL = fmt.Sprintf("http://%s.%s", server, istioDomain())
+
+			client = newH2CClient()
+			call = func(method string, req grpcRequest) ([]grpcResponse, grpcStatus, error) {
+				responses, status, _, err := client.Call(serverURL, method, req)
+				return responses, status, err
+			}
+			waitForGRPC(call)
+		})
+
+		itServesGRPC()
+
+		It("multiplexes calls over a single h2c connection", func() {
+			client = newH2CClient()
+			for i := 0; i < grpcCalls; i++ {
+				_, status, err := call("Unary", grpcRequest{Message: "multiplex"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(status.Code).To(BeZero())
+			}
+			Expect(client.Dials()).To(Equal(1))
+		})
+
+		It("serves bidirectional streaming calls", func() {
+			requests, requestWriter := io.Pipe()
+			req, err := http.NewRequest("POST", serverURL+"/echo.Echo/BidiStream", requests)
+			Expect(err).NotTo(HaveOccurred())
+
+			responses := make(chan *http.Response, 1)
+			go func() {
+				defer GinkgoRecover()
+				res, err := client.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+				responses <- res
+			}()
+
+			var res *http.Response
+			for i := 1; i <= 3; i++ {
+				Expect(writeGRPCMessage(requestWriter, grpcRequest{Message: fmt.Sprintf("ping %d", i)})).To(Succeed())
+				if res == nil {
+					Eventually(responses, convergenceTimeout).Should(Receive(&res))
+					defer res.Body.Close()
+				}
+
+				By(fmt.Sprintf("receiving the response to request %d before sending the next", i))
+				var response grpcResponse
+				ok, err := readGRPCMessage(res.Body, &response)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeTrue())
+				Expect(response.Message).To(Equal(fmt.Sprintf("ping %d", i)))
+				Expect(response.Sequence).To(Equal(i))
+			}
+			Expect(requestWriter.Close()).To(Succeed())
+
+			var response grpcResponse
+			ok, err := readGRPCMessage(res.Body, &response)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+
+			status, err := responseGRPCStatus(res)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(status).To(Equal(grpcStatus{Code: 0}))
+		})
+
+		Context("over TLS", func() {
+			BeforeEach(func() {
+				requireCapabilities(capability.TLSTermination)
+			})
+
+			It("negotiates HTTP/2 with ALPN", func() {
+				tlsClient, err := newTLSClient()
+				Expect(err).NotTo(HaveOccurred())
+				responses, status, res, err := tlsClient.Call(fmt.Sprintf("https://%s.%s", server, istioDomain()), "Unary", grpcRequest{Message: "over tls"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res.TLS).NotTo(BeNil())
+				Expect(res.TLS.NegotiatedProtocol).To(Equal("h2"))
+				Expect(status).To(Equal(grpcStatus{Code: 0}))
+				Expect(responses).To(HaveLen(1))
+				Expect(responses[0].Message).To(Equal("over tls"))
+			})
+		})
+	})
+
+	Context("on the internal istio domain", func() {
+		BeforeEach(func() {
+			requireCapabilities(capability.InternalRoutes, capability.SidecarProxying)
+
+			caller := generator.PrefixedRandomName(Config.GetNamePrefix(), "grpc-caller")
+			pushGRPCEcho(caller, istioDomain(), 1)
+			pushGRPCEcho(server, internalIstioDomain(), grpcInstances)
+			Expect(cf.Cf("add-network-policy", caller, "--destination-app", server).Wait(cfTimeout)).To(Exit(0))
+
+			callerURL := fmt.Sprintf("http://%s.%s", caller, istioDomain())
+			target := fmt.Sprintf("http://%s.%s:8080", server, internalIstioDomain())
+			client := newH2CClient()
+			call = func(method string, req grpcRequest) ([]grpcResponse, grpcStatus, error) {
+				req.Target = target
+				req.Method = method
+				responses, status, _, err := client.Call(callerURL, "Relay", req)
+				if err != nil {
+					return nil, grpcStatus{}, err
+				}
+				if status.Code != 0 || len(responses) != 1 || responses[0].Relayed == nil {
+					return nil, grpcStatus{}, fmt.Errorf("relay call failed with status %+v", status)
+				}
+
+				relayed := responses[0].Relayed
+				if relayed.Error != "" {
+					return nil, grpcStatus{}, fmt.Errorf("relayed call failed: %s", relayed.Error)
+				}
+				return relayed.Responses, grpcStatus{Code: relayed.StatusCode, Message: relayed.StatusMessage}, nil
+			}
+			waitForGRPC(call)
+		})
+
+		itServesGRPC()
+	})
+})
+
+// pushGRPCEcho pushes the grpc-echo app with a route on domain whose
+// destinations speak HTTP/2.
+func pushGRPCEcho(name, domain string, instances int) {
+	Expect(cf.Cf("push", name,
+		"-s", "cflinuxfs3",
+		"-d", domain,
+		"--hostname", name,
+		"-i", fmt.Sprintf("%d", instances),
+		"-f", grpcEchoAppManifest,
+		"-p", grpcEchoApp).Wait(pushTimeout)).To(Exit(0))
+
+	helpers.SetDestinationProtocol(CloudController, routeGuid(spaceName(), name), "http2")
+}
+
+// waitForGRPC waits until unary calls succeed, which they do not until the
+// route's HTTP/2 destinations have propagated.
+func waitForGRPC(call grpcCaller) {
+	Eventually(func() (grpcStatus, error) {
+		_, status, err := call("Unary", grpcRequest{Message: "ping"})
+		return status, err
+	}, convergenceTimeout, time.Second).Should(Equal(grpcStatus{Code: 0}))
+}
@@ -0,0 +1,301 @@
+package routing_test
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capability"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+)
+
+// headerBehavior describes what happened to a header between the client and
+// the app.
+type headerBehavior string
+
+const (
+	headerAbsent      headerBehavior = "absent"
+	headerAdded       headerBehavior = "added"
+	headerPreserved   headerBehavior = "preserved"
+	headerAppended    headerBehavior = "appended"
+	headerOverwritten headerBehavior = "overwritten"
+	headerStripped    headerBehavior = "stripped"
+
+	// headerNotForwarded is expected, never observed: it matches a sent
+	// header that was either stripped or overwritten.
+	headerNotForwarded headerBehavior = "stripped or overwritten"
+)
+
+// headerCase is one row of the header conformance tables.
+type headerCase struct {
+	Header string
+	// Sent is the value the client sends, if any.
+	Sent string
+	// Expected is what the istio routers and sidecars do to the header.
+	Expected headerBehavior
+	// Value, if set, matches the value the app receives. %s is replaced
+	// with the scheme of the request.
+	Value string
+	// Difference, if set, documents why gorouter treats the header
+	// differently, and Gorouter lists what gorouter is expected to do
+	// instead, which for some headers depends on its configuration.
+	Difference string
+	Gorouter   []headerBehavior
+}
+
+var uuidPattern = `^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`
+
+var externalHeaderCases = []TableEntry{
+	Entry("adds X-Forwarded-For", headerCase{
+		Header:   "X-Forwarded-For",
+		Expected: headerAdded,
+	}),
+	Entry("appends to X-Forwarded-For from the client", headerCase{
+		Header:   "X-Forwarded-For",
+		Sent:     "203.0.113.7",
+		Expected: headerAppended,
+	}),
+	Entry("sets X-Forwarded-Proto to the scheme of the request", headerCase{
+		Header:   "X-Forwarded-Proto",
+		Expected: headerAdded,
+		Value:    "^%s$",
+	}),
+	Entry("overwrites X-Forwarded-Proto from the client", headerCase{
+		Header:     "X-Forwarded-Proto",
+		Sent:       "ftp",
+		Expected:   headerOverwritten,
+		Value:      "^%s$",
+		Difference: "gorouter trusts X-Forwarded-Proto from clients unless sanitize_forwarded_proto is set",
+		Gorouter:   []headerBehavior{headerPreserved, headerOverwritten},
+	}),
+	Entry("adds X-Request-Id", headerCase{
+		Header:     "X-Request-Id",
+		Expected:   headerAdded,
+		Value:      uuidPattern,
+		Difference: "gorouter identifies requests with X-Vcap-Request-Id",
+		Gorouter:   []headerBehavior{headerAbsent},
+	}),
+	Entry("regenerates X-Request-Id from external clients", headerCase{
+		Header:     "X-Request-Id",
+		Sent:       "client-request-id",
+		Expected:   headerOverwritten,
+		Value:      uuidPattern,
+		Difference: "gorouter passes X-Request-Id through",
+		Gorouter:   []headerBehavior{headerPreserved},
+	}),
+	Entry("preserves X-B3-TraceId from the client", headerCase{
+		Header:   "X-B3-TraceId",
+		Sent:     "463ac35c9f6413ad48485a3953bb6124",
+		Expected: headerPreserved,
+	}),
+	Entry("preserves X-B3-Sampled from the client", headerCase{
+		Header:   "X-B3-Sampled",
+		Sent:     "1",
+		Expected: headerPreserved,
+	}),
+	Entry("preserves custom headers", headerCase{
+		Header:   "X-Iats-Custom",
+		Sent:     "custom value",
+		Expected: headerPreserved,
+	}),
+	Entry("strips X-Envoy-Internal from external clients", headerCase{
+		Header:     "X-Envoy-Internal",
+		Sent:       "true",
+		Expected:   headerStripped,
+		Difference: "gorouter does not know about Envoy's internal headers",
+		Gorouter:   []headerBehavior{headerPreserved},
+	}),
+	Entry("does not forward X-Forwarded-Client-Cert from external clients", headerCase{
+		Header:     "X-Forwarded-Client-Cert",
+		Sent:       "By=spiffe://attacker;URI=spiffe://attacker",
+		Expected:   headerNotForwarded,
+		Difference: "gorouter forwards it with always_forward, its default forwarded_client_cert setting, and strips it otherwise",
+		Gorouter:   []headerBehavior{headerPreserved, headerStripped},
+	}),
+}
+
+// The proxy droplet does not forward the headers of the request it relays,
+// so only headers added between the proxy and the app can be checked.
+var internalHeaderCases = []TableEntry{
+	Entry("adds X-Request-Id", headerCase{
+		Header:   "X-Request-Id",
+		Expected: headerAdded,
+		Value:    uuidPattern,
+	}),
+	Entry("sets X-Forwarded-Proto to http", headerCase{
+		Header:   "X-Forwarded-Proto",
+		Expected: headerAdded,
+		Value:    "^http$",
+	}),
+	Entry("adds X-Forwarded-Client-Cert", headerCase{
+		Header:   "X-Forwarded-Client-Cert",
+		Expected: headerAdded,
+		Value:    "URI=spiffe://",
+	}),
+}
+
+var _ = Describe("Request headers", func() {
+	var (
+		echo         string
+		proxy        string
+		proxyDroplet = "../assets/proxy.tgz"
+
+		// setupAttempted and setupFailed make every row fail straight away
+		// once pushing the shared apps has failed, rather than with errors
+		// from apps that are not there.
+		setupAttempted bool
+		setupFailed    bool
+	)
+
+	// The apps only echo requests, so they are pushed once per node and
+	// shared by every row of the tables. echo and proxy are only set once
+	// the apps are up, and the apps are deleted when the suite ends.
+	BeforeEach(func() {
+		if setupFailed {
+			Fail("pushing the request header apps failed in an earlier spec")
+		}
+		if setupAttempted {
+			return
+		}
+		setupAttempted = true
+		setupFailed = true
+
+		echoApp := generator.PrefixedRandomName(Config.GetNamePrefix(), "headers")
+		proxyApp := generator.PrefixedRandomName(Config.GetNamePrefix(), "proxy")
+		suiteCleanups = append(suiteCleanups, func() {
+			Expect(cf.Cf("delete", echoApp, "-f", "-r").Wait(cfTimeout)).To(Exit(0))
+			Expect(cf.Cf("delete", proxyApp, "-f", "-r").Wait(cfTimeout)).To(Exit(0))
+		})
+
+		pushEchoApp(echoApp, istioDomain())
+		Expect(cf.Cf("map-route", echoApp, systemDomain(), "--hostname", echoApp).Wait(cfTimeout)).To(Exit(0))
+		isUpAndRoutable(fmt.Sprintf("http://%s.%s", echoApp, istioDomain()))
+		isUpAndRoutable(fmt.Sprintf("http://%s.%s", echoApp, systemDomain()))
+
+		if hasCapabilities(capability.InternalRoutes, capability.SidecarProxying) {
+			Expect(cf.Cf("map-route", echoApp, internalIstioDomain(), "--hostname", echoApp).Wait(cfTimeout)).To(Exit(0))
+			Expect(cf.Cf("push", proxyApp,
+				"-d", istioDomain(),
+				"-s", "cflinuxfs3",
+				"--hostname", proxyApp,
+				"--droplet", proxyDroplet,
+				"-i", "1",
+				"-m", "32M",
+				"-k", "75M").Wait(pushTimeout)).To(Exit(0))
+			Expect(cf.Cf("add-network-policy", proxyApp, "--destination-app", echoApp).Wait(cfTimeout)).To(Exit(0))
+			isUpAndRoutable(fmt.Sprintf("http://%s.%s/proxy/%s.%s:8080", proxyApp, istioDomain(), echoApp, internalIstioDomain()))
+		}
+
+		echo, proxy = echoApp, proxyApp
+		setupFailed = false
+	})
+
+	DescribeTable("on the istio domain over HTTP",
+		func(c headerCase) {
+			received := echoHeaders(httpClient, fmt.Sprintf("http://%s.%s", echo, istioDomain()), c)
+			expectHeaderBehavior(c, received, "http")
+		},
+		externalHeaderCases...,
+	)
+
+	DescribeTable("on the istio domain over HTTPS",
+		func(c headerCase) {
+			requireCapabilities(capability.TLSTermination)
+			received := echoHeaders(httpClient, fmt.Sprintf("https://%s.%s", echo, istioDomain()), c)
+			expectHeaderBehavior(c, received, "https")
+		},
+		externalHeaderCases...,
+	)
+
+	DescribeTable("through the proxy to an internal route",
+		func(c headerCase) {
+			requireCapabilities(capability.InternalRoutes, capability.SidecarProxying)
+			received := echoHeaders(httpClient, fmt.Sprintf("http://%s.%s/proxy/%s.%s:8080", proxy, istioDomain(), echo, internalIstioDomain()), c)
+			expectHeaderBehavior(c, received, "http")
+		},
+		internalHeaderCases...,
+	)
+
+	DescribeTable("compared with gorouter on the system domain",
+		func(c headerCase) {
+			istioReceived := echoHeaders(httpClient, fmt.Sprintf("http://%s.%s", echo, istioDomain()), c)
+			istio := observeHeader(c, istioReceived)
+			gorouter := observeHeader(c, echoHeaders(httpClient, fmt.Sprintf("http://%s.%s", echo, systemDomain()), c))
+			fmt.Fprintf(GinkgoWriter, "%s (sent %q): istio %s, gorouter %s\n", c.Header, c.Sent, istio, gorouter)
+
+			if c.Difference != "" {
+				expectHeaderBehavior(c, istioReceived, "http")
+				Expect(c.Gorouter).To(ContainElement(gorouter), fmt.Sprintf("%s is %s by gorouter, expected one of %v: %s", c.Header, gorouter, c.Gorouter, c.Difference))
+				return
+			}
+			Expect(istio).To(Equal(gorouter), fmt.Sprintf("%s is %s by istio but %s by gorouter", c.Header, istio, gorouter))
+		},
+		externalHeaderCases...,
+	)
+})
+
+// echoHeaders sends a request to the echo app, with the header of c if it
+// has a value to send, and returns the headers the app received.
+func echoHeaders(client *http.Client, url string, c headerCase) http.Header {
+	req, err := http.NewRequest("GET", url, nil)
+	Expect(err).NotTo(HaveOccurred())
+	if c.Sent != "" {
+		req.Header.Set(c.Header, c.Sent)
+	}
+
+	res, err := client.Do(req)
+	Expect(err).NotTo(HaveOccurred())
+	defer res.Body.Close()
+	Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+	return decodeEcho(res.Body).Headers
+}
+
+// observeHeader works out what happened to the header of c from the values
+// the app received.
+func observeHeader(c headerCase, received http.Header) headerBehavior {
+	values := received[http.CanonicalHeaderKey(c.Header)]
+	value := strings.Join(values, ", ")
+
+	switch {
+	case c.Sent == "" && len(values) == 0:
+		return headerAbsent
+	case c.Sent == "":
+		return headerAdded
+	case len(values) == 0:
+		return headerStripped
+	case value == c.Sent:
+		return headerPreserved
+	case strings.HasPrefix(value, c.Sent+","):
+		return headerAppended
+	default:
+		return headerOverwritten
+	}
+}
+
+func expectHeaderBehavior(c headerCase, received http.Header, scheme string) {
+	observed := observeHeader(c, received)
+	value := strings.Join(received[http.CanonicalHeaderKey(c.Header)], ", ")
+	description := fmt.Sprintf("%s was %s, the app received %q", c.Header, observed, value)
+
+	if c.Expected == headerNotForwarded {
+		Expect([]headerBehavior{headerStripped, headerOverwritten}).To(ContainElement(observed), description)
+	} else {
+		Expect(observed).To(Equal(c.Expected), description)
+	}
+
+	if c.Value != "" {
+		pattern := c.Value
+		if strings.Contains(pattern, "%s") {
+			pattern = fmt.Sprintf(pattern, regexp.QuoteMeta(scheme))
+		}
+		Expect(value).To(MatchRegexp(pattern), description)
+	}
+}
@@ -0,0 +1,84 @@
+package routing_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capability"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Sidecar mTLS between apps", func() {
+	var (
+		proxy        string
+		echo         string
+		proxyURL     string
+		echoURL      string
+		proxyDroplet = "../assets/proxy.tgz"
+	)
+
+	BeforeEach(func() {
+		requireCapabilities(capability.InternalRoutes, capability.SidecarProxying)
+
+		proxy = generator.PrefixedRandomName(Config.GetNamePrefix(), "proxy")
+		echo = generator.PrefixedRandomName(Config.GetNamePrefix(), "echo")
+
+		Expect(cf.Cf("push", proxy,
+			"-d", istioDomain(),
+			"-s", "cflinuxfs3",
+			"--hostname", proxy,
+			"--droplet", proxyDroplet,
+			"-i", "1",
+			"-m", "32M",
+			"-k", "75M").Wait(pushTimeout)).To(Exit(0))
+
+		pushEchoApp(echo, internalIstioDomain())
+		Expect(cf.Cf("add-network-policy", proxy, "--destination-app", echo).Wait(cfTimeout)).To(Exit(0))
+
+		proxyURL = fmt.Sprintf("%s://%s.%s", scheme(), proxy, istioDomain())
+		echoURL = fmt.Sprintf("%s/proxy/%s.%s:8080", proxyURL, echo, internalIstioDomain())
+		isUpAndRoutable(echoURL)
+	})
+
+	It("forwards the SPIFFE identity of the calling app to the destination", func() {
+		proxyGUID := applicationGuid(proxy)
+
+		response := getEcho(echoURL)
+		Expect(response.Headers.Get("X-Forwarded-Client-Cert")).NotTo(BeEmpty(),
+			"the destination sidecar did not forward a client certificate, so the request was not sent over mTLS")
+		Expect(response.ForwardedClientCerts).NotTo(BeEmpty())
+
+		clientCert := response.ForwardedClientCerts[len(response.ForwardedClientCerts)-1]
+		Expect(clientCert).To(HaveKey("URI"))
+		Expect(clientCert["URI"]).To(HavePrefix("spiffe://"))
+		Expect(clientCert["URI"]).To(ContainSubstring(proxyGUID))
+	})
+
+	It("refuses plaintext connections that bypass the destination sidecar", func() {
+		instanceIP := getEcho(echoURL).InstanceIP
+		Expect(instanceIP).NotTo(BeEmpty())
+
+		bypassURL := fmt.Sprintf("%s/proxy/%s:8080", proxyURL, instanceIP)
+		Consistently(func() (int, error) {
+			res, err := httpClient.Get(bypassURL)
+			if err != nil {
+				return 0, err
+			}
+			defer res.Body.Close()
+
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				return 0, err
+			}
+			fmt.Fprintf(GinkgoWriter, "GET %s: %d %s\n", bypassURL, res.StatusCode, body)
+			return res.StatusCode, nil
+		}, 10*time.Second, time.Second).ShouldNot(Equal(http.StatusOK))
+	})
+})
@@ -0,0 +1,158 @@
+package routing_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Outlier Detection", func() {
+	const (
+		instances    = 3
+		failingIndex = "1"
+	)
+
+	var (
+		policy      config.OutlierDetectionPolicy
+		backend     string
+		adminURL    string
+		backendURL  string
+		instanceIDs map[string]string
+	)
+
+	BeforeEach(func() {
+		policy = Config.GetOutlierDetectionPolicy()
+
+		backend = generator.PrefixedRandomName(Config.GetNamePrefix(), "outlier")
+		adminURL = pushConfigurableBackend(backend, istioDomain())
+		backendURL = fmt.Sprintf("%s://%s.%s", scheme(), backend, istioDomain())
+		Expect(cf.Cf("scale", backend, "-i", fmt.Sprintf("%d", instances)).Wait(pushTimeout)).To(Exit(0))
+
+		By("waiting for every instance to receive traffic")
+		instanceIDs = map[string]string{}
+		Eventually(func() int {
+			if res, ok := getInstanceResponse(backendURL); ok && res.status == http.StatusOK {
+				instanceIDs[res.Index] = res.GUID
+			}
+			return len(instanceIDs)
+		}, convergenceTimeout, 100*time.Millisecond).Should(Equal(instances))
+	})
+
+	It("shifts traffic away from a failing instance and back once it recovers", func() {
+		failingGUID := instanceIDs[failingIndex]
+		failureDuration := 2 * policy.GetEjectionTimeout()
+
+		By("making one instance fail every request")
+		failInstance(adminURL, failingIndex, instanceFailure{
+			backendStep: backendStep{Status: http.StatusServiceUnavailable},
+			Duration:    failureDuration.String(),
+		})
+		failingSince := time.Now()
+
+		during := distribution{}
+		requestsAfterEjectionBound := 0
+		for time.Since(failingSince) < failureDuration {
+			res, ok := getInstanceResponse(backendURL)
+			during.add(res, ok)
+			if time.Since(failingSince) > policy.GetEjectionTimeout() {
+				requestsAfterEjectionBound++
+			}
+		}
+		fmt.Fprintf(GinkgoWriter, "while instance %s (%s) was failing:\n%s", failingIndex, failingGUID, during)
+
+		By("returning traffic to the instance once it recovers")
+		after := distribution{}
+		Eventually(func() int {
+			res, ok := getInstanceResponse(backendURL)
+			after.add(res, ok)
+			return after[instanceKey{GUID: failingGUID, Status: http.StatusOK}]
+		}, policy.GetRecoveryTimeout(), 100*time.Millisecond).Should(BeNumerically(">", 0))
+		fmt.Fprintf(GinkgoWriter, "after instance %s (%s) recovered:\n%s", failingIndex, failingGUID, after)
+
+		By("checking the failing instance stopped receiving traffic within the ejection bound")
+		report := instanceFailureReport(adminURL, failingIndex)
+		Expect(report.FailedRequests).NotTo(BeEmpty(), "the failing instance never received any requests")
+
+		failedAfterEjectionBound := 0
+		for _, failedAt := range report.FailedRequests {
+			if failedAt.Sub(report.StartedAt) > policy.GetEjectionTimeout() {
+				failedAfterEjectionBound++
+			}
+		}
+		// Without ejection the instance would get its fair share of requests,
+		// more with retries. Once ejected it only sees the occasional probe.
+		fairShare := float64(requestsAfterEjectionBound) / instances
+		Expect(float64(failedAfterEjectionBound)).To(BeNumerically("<", fairShare/2),
+			"the failing instance received %d of %d requests more than %s after it started failing",
+			failedAfterEjectionBound, requestsAfterEjectionBound, policy.GetEjectionTimeout())
+	})
+})
+
+type instanceResponse struct {
+	Index  string `json:"instance_index"`
+	GUID   string `json:"instance_guid"`
+	status int
+}
+
+func getInstanceResponse(url string) (instanceResponse, bool) {
+	var res instanceResponse
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return res, false
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return res, false
+	}
+	json.Unmarshal(body, &res)
+	res.status = resp.StatusCode
+	return res, true
+}
+
+type instanceKey struct {
+	GUID   string
+	Status int
+}
+
+// distribution counts responses per instance GUID and status code.
+type distribution map[instanceKey]int
+
+func (d distribution) add(res instanceResponse, ok bool) {
+	if !ok {
+		d[instanceKey{GUID: "connection error"}]++
+		return
+	}
+	d[instanceKey{GUID: res.GUID, Status: res.status}]++
+}
+
+func (d distribution) String() string {
+	keys := []instanceKey{}
+	for key := range d {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].GUID == keys[j].GUID {
+			return keys[i].Status < keys[j].Status
+		}
+		return keys[i].GUID < keys[j].GUID
+	})
+
+	out := ""
+	for _, key := range keys {
+		out += fmt.Sprintf("  %-36s %3d: %d\n", key.GUID, key.Status, d[key])
+	}
+	return out
+}
@@ -0,0 +1,186 @@
+package routing_test
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capability"
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+)
+
+// retryTarget sends a request to a key on the backend under test and returns
+// the status code the client saw.
+type retryTarget func(method, key string) (int, error)
+
+var _ = Describe("Retry Policy", func() {
+	var (
+		policy          config.RetryPolicy
+		backend         string
+		backendAdminURL string
+		send            retryTarget
+	)
+
+	BeforeEach(func() {
+		policy = Config.GetRetryPolicy()
+		backend = generator.PrefixedRandomName(Config.GetNamePrefix(), "backend")
+	})
+
+	itConformsToTheRetryPolicy := func() {
+		It("makes the configured number of attempts when every attempt fails", func() {
+			failure := 0
+			for status := 500; status <= 504; status++ {
+				if policy.RetriesStatus(status) {
+					failure = status
+					break
+				}
+			}
+			if failure == 0 {
+				Skip("the configured retry policy does not retry any 5xx status")
+			}
+			programBackend(backendAdminURL, "always-fails", backendStep{Status: failure, Count: 100})
+
+			status, err := send("GET", "always-fails")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(status).To(Equal(failure))
+			Expect(backendAttempts(backendAdminURL, "always-fails").Attempts).To(Equal(policy.Attempts()))
+		})
+
+		It("retries the status codes in the policy", func() {
+			for status := 500; status <= 504; status++ {
+				if !policy.RetriesStatus(status) {
+					continue
+				}
+				key := fmt.Sprintf("retries-%d", status)
+				programBackend(backendAdminURL, key, backendStep{Status: status, Count: policy.GetNumRetries()})
+
+				received, err := send("GET", key)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(received).To(Equal(http.StatusOK), "expected %d to be retried", status)
+				Expect(backendAttempts(backendAdminURL, key).Attempts).To(Equal(policy.Attempts()))
+			}
+		})
+
+		It("does not retry other status codes", func() {
+			for _, status := range []int{400, 404, 429, 500, 501, 502, 503, 504} {
+				if policy.RetriesStatus(status) {
+					continue
+				}
+				key := fmt.Sprintf("no-retries-%d", status)
+				programBackend(backendAdminURL, key, backendStep{Status: status})
+
+				received, err := send("GET", key)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(received).To(Equal(status))
+				Expect(backendAttempts(backendAdminURL, key).Attempts).To(Equal(1), "expected %d not to be retried", status)
+			}
+		})
+
+		It("retries connection resets according to the policy", func() {
+			programBackend(backendAdminURL, "reset", backendStep{Reset: true})
+
+			status, err := send("GET", "reset")
+			Expect(err).NotTo(HaveOccurred())
+
+			if policy.RetriesResets() {
+				Expect(status).To(Equal(http.StatusOK))
+				Expect(backendAttempts(backendAdminURL, "reset").Attempts).To(Equal(2))
+			} else {
+				Expect(status).To(Equal(http.StatusServiceUnavailable))
+				Expect(backendAttempts(backendAdminURL, "reset").Attempts).To(Equal(1))
+			}
+		})
+
+		It("retries non-idempotent requests according to the policy", func() {
+			if !policy.RetriesStatus(http.StatusServiceUnavailable) {
+				Skip("the configured retry policy does not retry 503")
+			}
+			programBackend(backendAdminURL, "post", backendStep{Status: http.StatusServiceUnavailable})
+
+			status, err := send("POST", "post")
+			Expect(err).NotTo(HaveOccurred())
+
+			expectedStatus, expectedAttempts := http.StatusServiceUnavailable, 1
+			if policy.RetriesNonIdempotent() {
+				expectedStatus, expectedAttempts = http.StatusOK, 2
+			}
+			attempts := backendAttempts(backendAdminURL, "post")
+			Expect(status).To(Equal(expectedStatus))
+			Expect(attempts.Attempts).To(Equal(expectedAttempts))
+			Expect(attempts.Log).To(HaveLen(expectedAttempts))
+			for _, attempt := range attempts.Log {
+				Expect(attempt.Method).To(Equal("POST"))
+			}
+		})
+
+		It("retries attempts that exceed the per-try timeout", func() {
+			perTryTimeout := policy.GetPerTryTimeout()
+			if perTryTimeout == 0 || policy.GetNumRetries() == 0 {
+				Skip("no per_try_timeout or no retries in the configured retry policy")
+			}
+			programBackend(backendAdminURL, "slow", backendStep{Delay: (2 * perTryTimeout).String()})
+
+			start := time.Now()
+			status, err := send("GET", "slow")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(time.Since(start)).To(BeNumerically(">=", perTryTimeout))
+			Expect(backendAttempts(backendAdminURL, "slow").Attempts).To(Equal(2))
+		})
+	}
+
+	Context("on the external istio domain", func() {
+		BeforeEach(func() {
+			backendAdminURL = pushConfigurableBackend(backend, istioDomain())
+			backendURL := fmt.Sprintf("%s://%s.%s", scheme(), backend, istioDomain())
+			isUpAndRoutable(backendURL)
+
+			send = func(method, key string) (int, error) {
+				req, err := http.NewRequest(method, fmt.Sprintf("%s/%s", backendURL, key), nil)
+				if err != nil {
+					return 0, err
+				}
+				res, err := httpClient.Do(req)
+				if err != nil {
+					return 0, err
+				}
+				res.Body.Close()
+				return res.StatusCode, nil
+			}
+		})
+
+		itConformsToTheRetryPolicy()
+	})
+
+	Context("on the internal istio domain", func() {
+		BeforeEach(func() {
+			requireCapabilities(capability.InternalRoutes, capability.SidecarProxying)
+
+			caller := generator.PrefixedRandomName(Config.GetNamePrefix(), "caller")
+			callerAdminURL := pushConfigurableBackend(caller, istioDomain())
+			backendAdminURL = pushConfigurableBackend(backend, internalIstioDomain())
+			Expect(cf.Cf("add-network-policy", caller, "--destination-app", backend).Wait(cfTimeout)).To(Exit(0))
+
+			backendURL := fmt.Sprintf("http://%s.%s:8080", backend, internalIstioDomain())
+			Eventually(func() int {
+				return relayRequest(callerAdminURL, "GET", backendURL).Status
+			}, convergenceTimeout, time.Second).Should(Equal(http.StatusOK))
+
+			send = func(method, key string) (int, error) {
+				relayed := relayRequest(callerAdminURL, method, fmt.Sprintf("%s/%s", backendURL, key))
+				if relayed.Error != "" {
+					return 0, fmt.Errorf("relayed request failed: %s", relayed.Error)
+				}
+				return relayed.Status, nil
+			}
+		})
+
+		itConformsToTheRetryPolicy()
+	})
+})
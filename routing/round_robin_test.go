@@ -26,32 +26,32 @@ var _ = Describe("Round Robin", func() {
 	BeforeEach(func() {
 		domain = istioDomain()
 
-		app = generator.PrefixedRandomName("IATS", "APP")
+		app = generator.PrefixedRandomName(Config.GetNamePrefix(), "APP")
 		Expect(cf.Cf("push", app,
 			"-d", domain,
 			"-s", "cflinuxfs3",
 			"--droplet", helloRoutingDroplet,
 			"-i", "1",
 			"-m", "16M",
-			"-k", "75M").Wait(defaultTimeout)).To(Exit(0))
-		appURL = fmt.Sprintf("http://%s.%s", app, domain)
+			"-k", "75M").Wait(pushTimeout)).To(Exit(0))
+		appURL = fmt.Sprintf("%s://%s.%s", scheme(), app, domain)
 
 		Eventually(func() (int, error) {
 			return getStatusCode(appURL)
-		}, defaultTimeout, time.Second).Should(Equal(http.StatusOK))
+		}, convergenceTimeout, time.Second).Should(Equal(http.StatusOK))
 	})
 
 	Context("when the app has many instances", func() {
 		BeforeEach(func() {
-			Expect(cf.Cf("scale", app, "-i", "2").Wait(defaultTimeout)).To(Exit(0))
+			Expect(cf.Cf("scale", app, "-i", "2").Wait(pushTimeout)).To(Exit(0))
 
 			Eventually(func() (int, error) {
 				return getStatusCode(appURL)
-			}, defaultTimeout, time.Second).Should(Equal(http.StatusOK))
+			}, convergenceTimeout, time.Second).Should(Equal(http.StatusOK))
 		})
 
 		It("successfully load balances between instances", func() {
-			resp, err := http.Get(appURL)
+			resp, err := httpClient.Get(appURL)
 			Expect(err).NotTo(HaveOccurred())
 
 			body, err := ioutil.ReadAll(resp.Body)
@@ -66,7 +66,7 @@ var _ = Describe("Round Robin", func() {
 			Expect(err).NotTo(HaveOccurred())
 
 			Eventually(func() (Instance, error) {
-				res, err := http.Get(appURL)
+				res, err := httpClient.Get(appURL)
 				if err != nil {
 					return Instance{}, err
 				}
@@ -82,7 +82,7 @@ var _ = Describe("Round Robin", func() {
 					return Instance{}, err
 				}
 				return instanceTwo, nil
-			}, defaultTimeout, time.Second).ShouldNot(Equal(instanceOne))
+			}, convergenceTimeout, time.Second).ShouldNot(Equal(instanceOne))
 		})
 	})
 
@@ -102,22 +102,22 @@ var _ = Describe("Round Robin", func() {
 				"--droplet", holaRoutingDroplet,
 				"-i", "1",
 				"-m", "16M",
-				"-k", "75M").Wait(defaultTimeout)).To(Exit(0))
-			appTwoURL = fmt.Sprintf("http://%s.%s", appTwo, domain)
+				"-k", "75M").Wait(pushTimeout)).To(Exit(0))
+			appTwoURL = fmt.Sprintf("%s://%s.%s", scheme(), appTwo, domain)
 
 			Eventually(func() (int, error) {
 				return getStatusCode(appTwoURL)
-			}, defaultTimeout).Should(Equal(http.StatusOK))
+			}, convergenceTimeout).Should(Equal(http.StatusOK))
 
-			hostname = generator.PrefixedRandomName("greetings", "app")
+			hostname = generator.PrefixedRandomName(Config.GetNamePrefix(), "greetings")
 
-			Expect(cf.Cf("create-route", spaceName(), domain, "--hostname", hostname).Wait(defaultTimeout)).To(Exit(0))
-			Expect(cf.Cf("map-route", app, domain, "--hostname", hostname).Wait(defaultTimeout)).To(Exit(0))
-			Expect(cf.Cf("map-route", appTwo, domain, "--hostname", hostname).Wait(defaultTimeout)).To(Exit(0))
+			Expect(cf.Cf("create-route", spaceName(), domain, "--hostname", hostname).Wait(cfTimeout)).To(Exit(0))
+			Expect(cf.Cf("map-route", app, domain, "--hostname", hostname).Wait(cfTimeout)).To(Exit(0))
+			Expect(cf.Cf("map-route", appTwo, domain, "--hostname", hostname).Wait(cfTimeout)).To(Exit(0))
 		})
 
 		It("successfully load balances requests to the apps", func() {
-			res, err := http.Get(appURL)
+			res, err := httpClient.Get(appURL)
 			Expect(err).ToNot(HaveOccurred())
 
 			body, err := ioutil.ReadAll(res.Body)
@@ -132,7 +132,7 @@ var _ = Describe("Round Robin", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			Eventually(func() (AppResponse, error) {
-				res, err := http.Get(appTwoURL)
+				res, err := httpClient.Get(appTwoURL)
 				if err != nil {
 					return AppResponse{}, err
 				}
@@ -149,7 +149,7 @@ var _ = Describe("Round Robin", func() {
 				}
 
 				return appTwoResp, nil
-			}, defaultTimeout, time.Second).ShouldNot(Equal(appOneResp))
+			}, convergenceTimeout, time.Second).ShouldNot(Equal(appOneResp))
 		})
 	})
 })
@@ -7,28 +7,54 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
-	"regexp"
-	"strings"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"code.cloudfoundry.org/istio-acceptance-tests/capability"
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
 	"code.cloudfoundry.org/istio-acceptance-tests/config"
-	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"code.cloudfoundry.org/istio-acceptance-tests/helpers"
+	"code.cloudfoundry.org/istio-acceptance-tests/httpclient"
+	"code.cloudfoundry.org/istio-acceptance-tests/latency"
+	"code.cloudfoundry.org/istio-acceptance-tests/matchers"
 	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
 
 	. "github.com/onsi/ginkgo"
+	ginkgoconfig "github.com/onsi/ginkgo/config"
 	. "github.com/onsi/gomega"
-	. "github.com/onsi/gomega/gexec"
 )
 
 const (
-	DEFAULT_MEMORY_LIMIT = "256M"
+	DEFAULT_MEMORY_LIMIT    = "256M"
+	propagationPollInterval = 250 * time.Millisecond
 )
 
 var (
-	Config         config.Config
-	TestSetup      *workflowhelpers.ReproducibleTestSuiteSetup
-	defaultTimeout = 240 * time.Second
+	Config          config.Config
+	TestSetup       *workflowhelpers.ReproducibleTestSuiteSetup
+	CloudController *capi.Client
+	Propagation     = latency.NewRecorder()
+	Capabilities    capability.Set
+
+	// Timeouts for each kind of operation, set from the config before any
+	// spec runs.
+	pushTimeout        time.Duration
+	cfTimeout          time.Duration
+	convergenceTimeout time.Duration
+	requestTimeout     time.Duration
+
+	// httpClient makes every request to apps, with the TLS settings and
+	// request timeout from the config.
+	httpClient *http.Client
+
+	// existingSpaceContents is what the existing space held before the
+	// suite ran, when the config names one. It is only set on node 1.
+	existingSpaceContents *helpers.SpaceContents
+
+	// suiteCleanups delete what specs on this node share across the suite.
+	// They run before the node tears down its test space.
+	suiteCleanups []func()
 )
 
 func TestRouting(t *testing.T) {
@@ -37,7 +63,16 @@ func TestRouting(t *testing.T) {
 	RunSpecs(t, "Routing Suite")
 }
 
-var _ = BeforeSuite(func() {
+var _ = SynchronizedBeforeSuite(func() []byte {
+	c, err := config.NewConfig(os.Getenv("CONFIG"))
+	Expect(err).ToNot(HaveOccurred())
+	if c.GetUseExistingSpace() {
+		cc := capi.NewClient(c)
+		contents := helpers.SnapshotSpace(cc, existingSpaceGuid(cc, c))
+		existingSpaceContents = &contents
+	}
+	return []byte{}
+}, func([]byte) {
 	var err error
 	configPath := os.Getenv("CONFIG")
 	Expect(configPath).NotTo(BeEmpty())
@@ -45,21 +80,79 @@ var _ = BeforeSuite(func() {
 	Config, err = config.NewConfig(configPath)
 	Expect(err).ToNot(HaveOccurred())
 	Expect(Config.Validate()).To(Succeed())
-	if Config.CFInternalAppsDomain == "" {
-		createCmd := cf.Cf("curl", "/v2/shared_domains", "-d", fmt.Sprintf("{\"name\": \"%s\", \"internal\": true}", config.DefaultInternalAppsDomain))
-		Expect(createCmd.Wait(defaultTimeout)).To(Exit(0))
-	}
+	setTimeouts(Config)
+	httpClient, err = httpclient.New(Config)
+	Expect(err).NotTo(HaveOccurred())
+	CloudController = capi.NewClient(Config)
+	Capabilities = capability.NewDetector(Config, CloudController).Resolve()
+	helpers.EnsureInternalAppsDomain(Config, cfTimeout)
 
 	TestSetup = workflowhelpers.NewTestSuiteSetup(Config)
 	TestSetup.Setup()
 })
 
-var _ = AfterSuite(func() {
+var _ = SynchronizedAfterSuite(func() {
+	for _, cleanup := range suiteCleanups {
+		cleanup()
+	}
+
+	if Config.PropagationReportDir != "" {
+		Expect(os.MkdirAll(Config.PropagationReportDir, 0755)).To(Succeed())
+		Expect(Propagation.WriteSamples(propagationSamplesPath(fmt.Sprintf("%d", ginkgoconfig.GinkgoConfig.ParallelNode)))).To(Succeed())
+	}
+
 	if TestSetup != nil {
 		TestSetup.Teardown()
 	}
+}, func() {
+	fmt.Println(Capabilities.Summary())
+
+	if existingSpaceContents != nil {
+		helpers.DeleteCreatedSince(CloudController, existingSpaceGuid(CloudController, Config), *existingSpaceContents, helpers.HasNamePrefix(Config.GetNamePrefix()))
+	}
+
+	if Config.PropagationReportDir == "" {
+		return
+	}
+
+	samples, err := latency.ReadSamples(propagationSamplesPath("*"))
+	Expect(err).NotTo(HaveOccurred())
+	report := latency.NewReport("routing", samples)
+	fmt.Println(report)
+	Expect(report.Write(filepath.Join(Config.PropagationReportDir, "routing-propagation.json"))).To(Succeed())
+
+	paths, _ := filepath.Glob(propagationSamplesPath("*"))
+	for _, path := range paths {
+		os.Remove(path)
+	}
 })
 
+// propagationSamplesPath is where each parallel node leaves its samples for
+// the final report. The random seed is shared by every node of a run, so runs
+// do not pick up each other's samples.
+func propagationSamplesPath(node string) string {
+	return filepath.Join(Config.PropagationReportDir, fmt.Sprintf("routing-%d-node-%s.json", ginkgoconfig.GinkgoConfig.RandomSeed, node))
+}
+
+func setTimeouts(c config.Config) {
+	pushTimeout = c.GetPushTimeout()
+	cfTimeout = c.GetCFAPITimeout()
+	convergenceTimeout = c.GetRouteConvergenceTimeout()
+	requestTimeout = c.GetHTTPRequestTimeout()
+}
+
+// requireCapabilities skips the spec unless the foundation has all of names.
+func requireCapabilities(names ...capability.Name) {
+	if err := Capabilities.Require(names...); err != nil {
+		Skip(err.Error())
+	}
+}
+
+// hasCapabilities returns whether the foundation has all of names.
+func hasCapabilities(names ...capability.Name) bool {
+	return Capabilities.Require(names...) == nil
+}
+
 func adminUserContext() workflowhelpers.UserContext {
 	return TestSetup.AdminUserContext()
 }
@@ -90,6 +183,19 @@ func internalIstioDomain() string {
 	return Config.CFInternalIstioDomain
 }
 
+func weightedRoutingConfidence() float64 {
+	if Config.WeightedRoutingConfidence == 0 {
+		return matchers.DefaultConfidence
+	}
+	return Config.WeightedRoutingConfidence
+}
+
+// scheme is the scheme of requests to app routes: https when the config
+// prefers it, http otherwise.
+func scheme() string {
+	return Config.GetAppScheme()
+}
+
 func systemDomain() string {
 	return Config.CFSystemDomain
 }
@@ -105,7 +211,7 @@ func getAppResponse(resp io.ReadCloser) Instance {
 }
 
 func getStatusCode(appURL string) (int, error) {
-	res, err := http.Get(appURL)
+	res, err := httpClient.Get(appURL)
 	if err != nil {
 		return 0, err
 	}
@@ -113,35 +219,35 @@ func getStatusCode(appURL string) (int, error) {
 	return res.StatusCode, nil
 }
 
+// waitForPropagationSince waits for a route change made at start to take
+// effect, recording how long it took for the propagation report. start is
+// taken before the cf command making the change runs, so that the time the
+// CLI takes to exit is not left out.
+func waitForPropagationSince(start time.Time, event latency.Event, url string, expectedStatus int) {
+	err := Propagation.WaitForStatusSince(start, event, url, expectedStatus, func() (int, error) {
+		return getStatusCode(url)
+	}, convergenceTimeout, propagationPollInterval)
+	Expect(err).NotTo(HaveOccurred())
+}
+
 func applicationGuid(a string) string {
-	appGuidCmd := cf.Cf("app", a, "--guid")
-	Expect(appGuidCmd.Wait(defaultTimeout)).To(Exit(0))
-	appGuid := string(appGuidCmd.Out.Contents())
-	return strings.TrimSuffix(appGuid, "\n")
+	return helpers.AppGUID(CloudController, spaceGuid(spaceName()), a)
 }
 
+// spaceGuid resolves a space in the org the suite targets.
 func spaceGuid(s string) string {
-	spaceGuidCmd := cf.Cf("space", s, "--guid")
-	Expect(spaceGuidCmd.Wait(defaultTimeout)).To(Exit(0))
-	spaceGuid := string(spaceGuidCmd.Out.Contents())
-	return strings.TrimSuffix(spaceGuid, "\n")
+	return helpers.SpaceGUID(CloudController, organizationName(), s)
 }
 
-func domainGuid(d string) string {
-	domainGuidCmd := cf.Cf("curl", "/v2/domains?q=name:"+d)
-	Expect(domainGuidCmd.Wait(defaultTimeout)).To(Exit(0))
-	domainResp := string(domainGuidCmd.Out.Contents())
-	return getEntityGuid(domainResp)
+// existingSpaceGuid resolves the existing space the config names.
+func existingSpaceGuid(cc *capi.Client, c config.Config) string {
+	return helpers.SpaceGUID(cc, c.GetExistingOrganization(), c.GetExistingSpace())
 }
 
-func routeGuid(space string, hostname string) string {
-	routeGuidCmd := cf.Cf("curl", fmt.Sprintf("/v2/routes?q=host:%s", hostname))
-	Expect(routeGuidCmd.Wait(defaultTimeout)).To(Exit(0))
-	routeResp := string(routeGuidCmd.Out.Contents())
-	return getEntityGuid(routeResp)
+func domainGuid(d string) string {
+	return helpers.DomainGUID(CloudController, d)
 }
 
-func getEntityGuid(s string) string {
-	regex := regexp.MustCompile(`\s+"guid": "(.+)"`)
-	return regex.FindStringSubmatch(s)[1]
+func routeGuid(space string, hostname string) string {
+	return helpers.RouteGUID(CloudController, spaceGuid(space), hostname)
 }
@@ -1,12 +1,13 @@
 package routing_test
 
 import (
-	"crypto/tls"
-	"crypto/x509"
 	"fmt"
 	"net/http"
 	"time"
 
+	"code.cloudfoundry.org/istio-acceptance-tests/capability"
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
+	"code.cloudfoundry.org/istio-acceptance-tests/latency"
 	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
 	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
 
@@ -26,57 +27,43 @@ var _ = Describe("Routing", func() {
 	BeforeEach(func() {
 		domain = istioDomain()
 
-		app = generator.PrefixedRandomName("IATS", "APP")
+		app = generator.PrefixedRandomName(Config.GetNamePrefix(), "APP")
 		Expect(cf.Cf("push", app,
 			"-d", domain,
 			"-s", "cflinuxfs3",
 			"--droplet", helloRoutingDroplet,
 			"-i", "1",
 			"-m", "16M",
-			"-k", "75M").Wait(defaultTimeout)).To(Exit(0))
-		appURL = fmt.Sprintf("http://%s.%s", app, domain)
+			"-k", "75M").Wait(pushTimeout)).To(Exit(0))
+		appURL = fmt.Sprintf("%s://%s.%s", scheme(), app, domain)
 
 		Eventually(func() (int, error) {
 			return getStatusCode(appURL)
-		}, defaultTimeout).Should(Equal(http.StatusOK))
+		}, convergenceTimeout).Should(Equal(http.StatusOK))
 	})
 
 	Context("when an app is pushed to the istio domain with frontend certs", func() {
 		BeforeEach(func() {
-			if Config.WildcardCa == "" {
-				Skip("skipping tls termination test, no wildcard ca supplied")
-			}
+			requireCapabilities(capability.TLSTermination)
 		})
 
 		It("response to HTTPS requests", func() {
-			caCertPool := x509.NewCertPool()
-			caCertPool.AppendCertsFromPEM([]byte(Config.WildcardCa))
-
-			client := &http.Client{
-				Transport: &http.Transport{
-					TLSClientConfig: &tls.Config{
-						RootCAs: caCertPool,
-					},
-				},
-			}
 			// Frontend wildcard certs are setup in the manifest for the env
 			httpsAppURL := fmt.Sprintf("https://%s.%s", app, domain)
 
 			Eventually(func() (int, error) {
-				res, err := client.Get(httpsAppURL)
-				res.Body.Close()
-				return res.StatusCode, err
-			}, defaultTimeout, time.Second).Should(Equal(http.StatusOK))
+				return getStatusCode(httpsAppURL)
+			}, convergenceTimeout, time.Second).Should(Equal(http.StatusOK))
 		})
 	})
 
 	Context("when the app is stopped", func() {
 		It("returns a 503", func() {
-			Expect(cf.Cf("stop", app).Wait(defaultTimeout)).To(Exit(0))
+			Expect(cf.Cf("stop", app).Wait(cfTimeout)).To(Exit(0))
 
 			Eventually(func() (int, error) {
 				return getStatusCode(appURL)
-			}, defaultTimeout).Should(Equal(http.StatusServiceUnavailable))
+			}, convergenceTimeout).Should(Equal(http.StatusServiceUnavailable))
 		})
 	})
 
@@ -84,69 +71,82 @@ var _ = Describe("Routing", func() {
 		var (
 			hostnameOne string
 			hostnameTwo string
+			mappedOne   time.Time
+			mappedTwo   time.Time
 		)
 
 		BeforeEach(func() {
-			hostnameOne = generator.PrefixedRandomName("IATS", "host")
+			hostnameOne = generator.PrefixedRandomName(Config.GetNamePrefix(), "host")
 			hostnameTwo = hostnameOne + "-2"
 
+			mappedOne = time.Now()
 			mapRouteOneCmd := cf.Cf("map-route", app, domain, "--hostname", hostnameOne)
-			Expect(mapRouteOneCmd.Wait(defaultTimeout)).To(Exit(0))
+			Expect(mapRouteOneCmd.Wait(cfTimeout)).To(Exit(0))
+			mappedTwo = time.Now()
 			mapRouteTwoCmd := cf.Cf("map-route", app, domain, "--hostname", hostnameTwo)
-			Expect(mapRouteTwoCmd.Wait(defaultTimeout)).To(Exit(0))
+			Expect(mapRouteTwoCmd.Wait(cfTimeout)).To(Exit(0))
 		})
 
 		It("requests succeed to all routes", func() {
+			waitForPropagationSince(mappedOne, latency.MapRoute, fmt.Sprintf("%s://%s.%s", scheme(), hostnameOne, domain), http.StatusOK)
+			waitForPropagationSince(mappedTwo, latency.MapRoute, fmt.Sprintf("%s://%s.%s", scheme(), hostnameTwo, domain), http.StatusOK)
+		})
+
+		It("successfully unmaps routes and request continue to succeed for mapped routes", func() {
+			appURLOne := fmt.Sprintf("%s://%s.%s", scheme(), hostnameOne, domain)
 			Eventually(func() (int, error) {
-				appURLOne := fmt.Sprintf("http://%s.%s", hostnameOne, domain)
 				return getStatusCode(appURLOne)
-			}, defaultTimeout, time.Second).Should(Equal(http.StatusOK))
+			}, convergenceTimeout, time.Second).Should(Equal(http.StatusOK))
+
+			unmapped := time.Now()
+			unmapRouteOneCmd := cf.Cf("unmap-route", app, domain, "--hostname", hostnameOne)
+			Expect(unmapRouteOneCmd.Wait(cfTimeout)).To(Exit(0))
+
+			waitForPropagationSince(unmapped, latency.UnmapRoute, appURLOne, http.StatusNotFound)
 
 			Eventually(func() (int, error) {
-				appURLTwo := fmt.Sprintf("http://%s.%s", hostnameTwo, domain)
+				appURLTwo := fmt.Sprintf("%s://%s.%s", scheme(), hostnameTwo, domain)
 				return getStatusCode(appURLTwo)
-			}, defaultTimeout, time.Second).Should(Equal(http.StatusOK))
+			}, convergenceTimeout, time.Second).Should(Equal(http.StatusOK))
 		})
 
-		It("successfully unmaps routes and request continue to succeed for mapped routes", func() {
-			unmapRouteOneCmd := cf.Cf("unmap-route", app, domain, "--hostname", hostnameOne)
-			Expect(unmapRouteOneCmd.Wait(defaultTimeout)).To(Exit(0))
-
+		It("successfully deletes a mapped route and requests continue to succeed for other routes", func() {
+			appURLOne := fmt.Sprintf("%s://%s.%s", scheme(), hostnameOne, domain)
 			Eventually(func() (int, error) {
-				appURLOne := fmt.Sprintf("http://%s.%s", hostnameOne, domain)
 				return getStatusCode(appURLOne)
-			}, defaultTimeout).Should(Equal(http.StatusNotFound))
+			}, convergenceTimeout, time.Second).Should(Equal(http.StatusOK))
+
+			deleted := time.Now()
+			Expect(cf.Cf("delete-route", domain, "-f", "--hostname", hostnameOne).Wait(cfTimeout)).To(Exit(0))
+
+			waitForPropagationSince(deleted, latency.DeleteRoute, appURLOne, http.StatusNotFound)
 
 			Eventually(func() (int, error) {
-				appURLTwo := fmt.Sprintf("http://%s.%s", hostnameTwo, domain)
+				appURLTwo := fmt.Sprintf("%s://%s.%s", scheme(), hostnameTwo, domain)
 				return getStatusCode(appURLTwo)
-			}, defaultTimeout, time.Second).Should(Equal(http.StatusOK))
+			}, convergenceTimeout, time.Second).Should(Equal(http.StatusOK))
 		})
 	})
 
 	Context("when an app has a user-provided internal route", func() {
 		It("requests are not externally accessible to the internal route", func() {
-			timeout := time.Duration(time.Second * 10)
-			tr := &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-			}
-			client := &http.Client{Transport: tr, Timeout: timeout}
-			hostname := generator.PrefixedRandomName("IATS", "HOST")
+			hostname := generator.PrefixedRandomName(Config.GetNamePrefix(), "HOST")
 			mapRouteInternalCmd := cf.Cf("map-route", app, internalDomain(), "--hostname", hostname)
-			Expect(mapRouteInternalCmd.Wait(defaultTimeout)).To(Exit(0))
+			Expect(mapRouteInternalCmd.Wait(cfTimeout)).To(Exit(0))
 
-			req, err := http.NewRequest("GET", fmt.Sprintf("http://envoy.%s", domain), nil)
+			req, err := http.NewRequest("GET", fmt.Sprintf("%s://envoy.%s", scheme(), domain), nil)
 			Expect(err).NotTo(HaveOccurred())
 			req.Host = fmt.Sprintf("%s.%s", hostname, internalDomain())
 
 			Eventually(func() (int, error) {
-				resp, err := client.Do(req)
+				resp, err := httpClient.Do(req)
 				if err != nil {
 					return 0, err
 				}
+				resp.Body.Close()
 
 				return resp.StatusCode, err
-			}, defaultTimeout, time.Second).Should(Equal(http.StatusNotFound))
+			}, convergenceTimeout, time.Second).Should(Equal(http.StatusNotFound))
 		})
 	})
 
@@ -155,32 +155,50 @@ var _ = Describe("Routing", func() {
 			var (
 				appGuid  string
 				hostname string
+				routeURL string
+				created  time.Time
 			)
 
 			BeforeEach(func() {
 				appGuid = applicationGuid(app)
-				hostname = generator.PrefixedRandomName("iats", "host")
-				Expect(cf.Cf("create-route", spaceName(), domain, "--hostname", hostname).Wait(defaultTimeout)).To(Exit(0))
+				hostname = generator.PrefixedRandomName(Config.GetNamePrefix(), "host")
+				routeURL = fmt.Sprintf("%s://%s.%s", scheme(), hostname, domain)
+				created = time.Now()
+				Expect(cf.Cf("create-route", spaceName(), domain, "--hostname", hostname).Wait(cfTimeout)).To(Exit(0))
 			})
 
+			// Each mapping is timed from the mapping itself, and again from
+			// the create-route for the time to the first 200 of a new route.
+			// Once the first wait converges, the second one does straight away.
+
 			It("can map route using Apps API", func() {
 				routeGuid := routeGuid(spaceName(), hostname)
-				Expect(cf.Cf("curl", fmt.Sprintf("v2/apps/%s/routes/%s", appGuid, routeGuid), "-X", "PUT").Wait(defaultTimeout)).To(Exit(0))
+				mapped := time.Now()
+				Expect(cf.Cf("curl", fmt.Sprintf("v2/apps/%s/routes/%s", appGuid, routeGuid), "-X", "PUT").Wait(cfTimeout)).To(Exit(0))
 
-				Eventually(func() (int, error) {
-					appURL := fmt.Sprintf("http://%s.%s", hostname, domain)
-					return getStatusCode(appURL)
-				}, defaultTimeout, time.Second).Should(Equal(http.StatusOK))
+				waitForPropagationSince(mapped, latency.MapRoute, routeURL, http.StatusOK)
+				waitForPropagationSince(created, latency.CreateRoute, routeURL, http.StatusOK)
 			})
 
 			It("can map route using Routes API", func() {
 				routeGuid := routeGuid(spaceName(), hostname)
-				Expect(cf.Cf("curl", fmt.Sprintf("v2/routes/%s/apps/%s", routeGuid, appGuid), "-X", "PUT").Wait(defaultTimeout)).To(Exit(0))
+				mapped := time.Now()
+				Expect(cf.Cf("curl", fmt.Sprintf("v2/routes/%s/apps/%s", routeGuid, appGuid), "-X", "PUT").Wait(cfTimeout)).To(Exit(0))
 
-				Eventually(func() (int, error) {
-					appURL := fmt.Sprintf("http://%s.%s", hostname, domain)
-					return getStatusCode(appURL)
-				}, defaultTimeout, time.Second).Should(Equal(http.StatusOK))
+				waitForPropagationSince(mapped, latency.MapRoute, routeURL, http.StatusOK)
+				waitForPropagationSince(created, latency.CreateRoute, routeURL, http.StatusOK)
+			})
+
+			It("can map route using the v3 destinations API", func() {
+				routeGuid := routeGuid(spaceName(), hostname)
+				mapped := time.Now()
+				_, err := CloudController.InsertDestinations(routeGuid, []capi.Destination{
+					{App: capi.DestinationApp{GUID: appGuid}},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				waitForPropagationSince(mapped, latency.Destinations, routeURL, http.StatusOK)
+				waitForPropagationSince(created, latency.CreateRoute, routeURL, http.StatusOK)
 			})
 		})
 	})
@@ -0,0 +1,248 @@
+package routing_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+)
+
+const (
+	streamingApp         = "../assets/streaming-app"
+	streamingAppManifest = "../assets/streaming-app/manifest.yml"
+	streamedParts        = 5
+	streamInterval       = 2 * time.Second
+
+	// patternPeriod matches the streaming app's download pattern.
+	patternPeriod = 251
+
+	// minLargeBodyRate is the slowest rate, in bytes per second, that large
+	// bodies are expected to move at through the routers.
+	minLargeBodyRate = 1 << 20
+)
+
+var _ = Describe("Streaming", func() {
+	var (
+		policy config.StreamingPolicy
+		appURL string
+	)
+
+	BeforeEach(func() {
+		policy = Config.GetStreamingPolicy()
+
+		app := generator.PrefixedRandomName(Config.GetNamePrefix(), "streaming")
+		Expect(cf.Cf("push", app,
+			"-s", "cflinuxfs3",
+			"-d", istioDomain(),
+			"--hostname", app,
+			"-f", streamingAppManifest,
+			"-p", streamingApp).Wait(pushTimeout)).To(Exit(0))
+
+		appURL = fmt.Sprintf("%s://%s.%s", scheme(), app, istioDomain())
+		isUpAndRoutable(appURL)
+	})
+
+	It("delivers chunked responses incrementally", func() {
+		start := time.Now()
+		res, err := httpClient.Get(fmt.Sprintf("%s/chunked?chunks=%d&interval=%s", appURL, streamedParts, streamInterval))
+		Expect(err).NotTo(HaveOccurred())
+		defer res.Body.Close()
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+		Expect(res.TransferEncoding).To(Equal([]string{"chunked"}))
+
+		arrivals := readLines(res.Body, start, func(line string) bool { return line != "" })
+		Expect(arrivals).To(HaveLen(streamedParts))
+		for i, arrival := range arrivals {
+			Expect(arrival.text).To(Equal(fmt.Sprintf("chunk %d of %d", i+1, streamedParts)))
+		}
+		expectIncremental(arrivals, policy.GetMaxFirstByteLatency())
+	})
+
+	It("delivers Server-Sent Events as they are sent", func() {
+		start := time.Now()
+		res, err := httpClient.Get(fmt.Sprintf("%s/events?events=%d&interval=%s", appURL, streamedParts, streamInterval))
+		Expect(err).NotTo(HaveOccurred())
+		defer res.Body.Close()
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+		Expect(res.Header.Get("Content-Type")).To(HavePrefix("text/event-stream"))
+
+		arrivals := readLines(res.Body, start, func(line string) bool { return strings.HasPrefix(line, "data: ") })
+		Expect(arrivals).To(HaveLen(streamedParts))
+		for i, arrival := range arrivals {
+			Expect(arrival.text).To(Equal(fmt.Sprintf("data: event %d of %d", i+1, streamedParts)))
+		}
+		expectIncremental(arrivals, policy.GetMaxFirstByteLatency())
+	})
+
+	It("preserves trailers", func() {
+		res, err := httpClient.Get(fmt.Sprintf("%s/trailers?chunks=%d", appURL, streamedParts))
+		Expect(err).NotTo(HaveOccurred())
+		defer res.Body.Close()
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+		body, err := ioutil.ReadAll(res.Body)
+		Expect(err).NotTo(HaveOccurred())
+		sum := sha256.Sum256(body)
+		Expect(res.Trailer.Get("X-Body-Sha256")).To(Equal(hex.EncodeToString(sum[:])))
+	})
+
+	It("streams large downloads with their Content-Length", func() {
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s/download?bytes=%d", appURL, policy.LargeBodyBytes), nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		start := time.Now()
+		res, cancel, err := doLargeBodyRequest(req, policy.LargeBodyBytes)
+		Expect(err).NotTo(HaveOccurred())
+		defer cancel()
+		defer res.Body.Close()
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+		Expect(res.ContentLength).To(Equal(policy.LargeBodyBytes))
+
+		firstByte := make([]byte, 1)
+		_, err = io.ReadFull(res.Body, firstByte)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(time.Since(start)).To(BeNumerically("<", policy.GetMaxFirstByteLatency()))
+
+		verifier := &patternVerifier{}
+		_, err = verifier.Write(firstByte)
+		Expect(err).NotTo(HaveOccurred())
+		received, err := io.Copy(verifier, res.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(received + 1).To(Equal(policy.LargeBodyBytes))
+		fmt.Fprintf(GinkgoWriter, "downloaded %d bytes in %s\n", policy.LargeBodyBytes, time.Since(start))
+	})
+
+	It("accepts large uploads", func() {
+		hash := sha256.New()
+		body := io.TeeReader(io.LimitReader(&pattern{}, policy.LargeBodyBytes), hash)
+		req, err := http.NewRequest("POST", appURL+"/upload", body)
+		Expect(err).NotTo(HaveOccurred())
+		req.ContentLength = policy.LargeBodyBytes
+
+		start := time.Now()
+		res, cancel, err := doLargeBodyRequest(req, policy.LargeBodyBytes)
+		Expect(err).NotTo(HaveOccurred())
+		defer cancel()
+		defer res.Body.Close()
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+		fmt.Fprintf(GinkgoWriter, "uploaded %d bytes in %s\n", policy.LargeBodyBytes, time.Since(start))
+
+		var upload struct {
+			Bytes  int64  `json:"bytes"`
+			Sha256 string `json:"sha256"`
+		}
+		Expect(json.NewDecoder(res.Body).Decode(&upload)).To(Succeed())
+		Expect(upload.Bytes).To(Equal(policy.LargeBodyBytes))
+		Expect(upload.Sha256).To(Equal(hex.EncodeToString(hash.Sum(nil))))
+	})
+
+	It("rejects request bodies over the size limit", func() {
+		if policy.MaxRequestBodyBytes == 0 {
+			Skip("no max_request_body_bytes in the configured streaming policy")
+		}
+
+		size := policy.MaxRequestBodyBytes + 1
+		req, err := http.NewRequest("POST", appURL+"/upload", io.LimitReader(&pattern{}, size))
+		Expect(err).NotTo(HaveOccurred())
+		req.ContentLength = size
+
+		res, err := httpClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		defer res.Body.Close()
+		Expect(res.StatusCode).To(Equal(policy.BodyTooLargeStatusCode))
+	})
+})
+
+type lineArrival struct {
+	text string
+	at   time.Duration
+}
+
+// readLines reads the body line by line, recording when each line
+// accepted by keep arrived relative to start.
+func readLines(body io.Reader, start time.Time, keep func(string) bool) []lineArrival {
+	arrivals := []lineArrival{}
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		if keep(scanner.Text()) {
+			arrivals = append(arrivals, lineArrival{text: scanner.Text(), at: time.Since(start)})
+		}
+	}
+	Expect(scanner.Err()).NotTo(HaveOccurred())
+	return arrivals
+}
+
+// expectIncremental checks that the first part arrived promptly and that the
+// parts were not buffered and delivered together: they are streamInterval
+// apart at the source, so at least half of that must separate them.
+func expectIncremental(arrivals []lineArrival, maxFirstByteLatency time.Duration) {
+	fmt.Fprintf(GinkgoWriter, "arrivals: %v\n", arrivals)
+	Expect(arrivals[0].at).To(BeNumerically("<", maxFirstByteLatency), "the first part was delayed")
+	for i := 1; i < len(arrivals); i++ {
+		Expect(arrivals[i].at-arrivals[i-1].at).To(BeNumerically(">=", streamInterval/2),
+			fmt.Sprintf("part %d arrived together with part %d", i+1, i))
+	}
+}
+
+// pattern is an endless reader of the streaming app's download pattern.
+type pattern struct {
+	offset int64
+}
+
+func (p *pattern) Read(buf []byte) (int, error) {
+	for i := range buf {
+		buf[i] = byte(p.offset % patternPeriod)
+		p.offset++
+	}
+	return len(buf), nil
+}
+
+// patternVerifier fails on the first byte written to it that does not follow
+// the download pattern.
+type patternVerifier struct {
+	offset int64
+}
+
+func (v *patternVerifier) Write(buf []byte) (int, error) {
+	for i, b := range buf {
+		if b != byte(v.offset%patternPeriod) {
+			return i, fmt.Errorf("byte %d is %d, expected %d", v.offset, b, v.offset%patternPeriod)
+		}
+		v.offset++
+	}
+	return len(buf), nil
+}
+
+// doLargeBodyRequest sends req, which moves size bytes, without the overall
+// timeout of httpClient, which a large body on a slow link would outlast.
+// The transfer has a deadline of its own instead, from the request timeout
+// plus the time size takes at minLargeBodyRate. cancel releases the
+// deadline once the body has been read.
+func doLargeBodyRequest(req *http.Request, size int64) (*http.Response, context.CancelFunc, error) {
+	client := *httpClient
+	client.Timeout = 0
+
+	deadline := requestTimeout + time.Duration(size/minLargeBodyRate)*time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return res, cancel, nil
+}
@@ -0,0 +1,175 @@
+package routing_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capability"
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"code.cloudfoundry.org/istio-acceptance-tests/httpclient"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Timeouts", func() {
+	var (
+		policy          config.TimeoutPolicy
+		routeTimeout    time.Duration
+		backend         string
+		backendAdminURL string
+		backendURL      string
+		proxyDroplet    = "../assets/proxy.tgz"
+	)
+
+	BeforeEach(func() {
+		policy = Config.GetTimeoutPolicy()
+		routeTimeout = policy.GetRouteTimeout()
+		backend = generator.PrefixedRandomName(Config.GetNamePrefix(), "slow")
+	})
+
+	itHandlesSlowBackends := func() {
+		It("responds when the backend is slow but within the route timeout", func() {
+			programBackend(backendAdminURL, "slow", backendStep{Delay: (routeTimeout / 2).String()})
+
+			res, err := httpClient.Get(backendURL + "/slow")
+			Expect(err).NotTo(HaveOccurred())
+			defer res.Body.Close()
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("times out requests that exceed the route timeout", func() {
+			programBackend(backendAdminURL, "too-slow", backendStep{Delay: (routeTimeout + 10*time.Second).String()})
+
+			start := time.Now()
+			res, err := httpClient.Get(backendURL + "/too-slow")
+			elapsed := time.Since(start)
+			Expect(err).NotTo(HaveOccurred())
+			defer res.Body.Close()
+
+			body, err := ioutil.ReadAll(res.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.StatusCode).To(Equal(policy.TimeoutStatusCode))
+			Expect(string(body)).To(ContainSubstring(policy.TimeoutBody))
+			Expect(elapsed).To(BeNumerically(">=", routeTimeout))
+			Expect(elapsed).To(BeNumerically("<", routeTimeout+10*time.Second))
+		})
+
+		It("does not cut off a streaming response that finishes within the route timeout", func() {
+			// Stream for two thirds of the route timeout, a chunk about
+			// every second, and at least two chunks for short timeouts.
+			streamFor := routeTimeout * 2 / 3
+			chunks := int(streamFor/time.Second) + 1
+			if chunks < 2 {
+				chunks = 2
+			}
+			interval := streamFor / time.Duration(chunks-1)
+			programBackend(backendAdminURL, "stream", backendStep{Chunks: chunks, Interval: interval.String()})
+
+			res, err := httpClient.Get(backendURL + "/stream")
+			Expect(err).NotTo(HaveOccurred())
+			defer res.Body.Close()
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+			body, err := ioutil.ReadAll(res.Body)
+			Expect(err).NotTo(HaveOccurred())
+			lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+			Expect(lines).To(HaveLen(chunks))
+			Expect(lines[chunks-1]).To(Equal(fmt.Sprintf("chunk %d of %d", chunks, chunks)))
+		})
+	}
+
+	Context("on the external istio domain", func() {
+		BeforeEach(func() {
+			backendAdminURL = pushConfigurableBackend(backend, istioDomain())
+			backendURL = fmt.Sprintf("%s://%s.%s", scheme(), backend, istioDomain())
+			isUpAndRoutable(backendURL)
+		})
+
+		itHandlesSlowBackends()
+
+		It("closes idle client connections after the idle timeout", func() {
+			idleTimeout := policy.GetIdleTimeout()
+			if idleTimeout == 0 {
+				Skip("no idle_timeout in the configured timeout policy")
+			}
+
+			conn, err := dialApp(fmt.Sprintf("%s.%s", backend, istioDomain()))
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+
+			reader := bufio.NewReader(conn)
+			req, err := http.NewRequest("GET", backendURL+"/idle", nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(req.Write(conn)).To(Succeed())
+			res, err := http.ReadResponse(reader, req)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = ioutil.ReadAll(res.Body)
+			Expect(err).NotTo(HaveOccurred())
+			res.Body.Close()
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+			By("staying open while the connection is in use")
+			time.Sleep(idleTimeout / 2)
+			Expect(req.Write(conn)).To(Succeed())
+			res, err = http.ReadResponse(reader, req)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = ioutil.ReadAll(res.Body)
+			Expect(err).NotTo(HaveOccurred())
+			res.Body.Close()
+
+			By("closing the connection once it has been idle for the idle timeout")
+			Expect(conn.SetReadDeadline(time.Now().Add(idleTimeout + 10*time.Second))).To(Succeed())
+			_, err = reader.ReadByte()
+			Expect(err).To(Equal(io.EOF))
+		})
+	})
+
+	Context("on the internal istio domain", func() {
+		BeforeEach(func() {
+			requireCapabilities(capability.InternalRoutes, capability.SidecarProxying)
+
+			proxy := generator.PrefixedRandomName(Config.GetNamePrefix(), "proxy")
+			Expect(cf.Cf("push", proxy,
+				"-d", istioDomain(),
+				"-s", "cflinuxfs3",
+				"--hostname", proxy,
+				"--droplet", proxyDroplet,
+				"-i", "1",
+				"-m", "32M",
+				"-k", "75M").Wait(pushTimeout)).To(Exit(0))
+
+			backendAdminURL = pushConfigurableBackend(backend, internalIstioDomain())
+			Expect(cf.Cf("add-network-policy", proxy, "--destination-app", backend).Wait(cfTimeout)).To(Exit(0))
+
+			backendURL = fmt.Sprintf("%s://%s.%s/proxy/%s.%s:8080", scheme(), proxy, istioDomain(), backend, internalIstioDomain())
+			isUpAndRoutable(backendURL)
+		})
+
+		itHandlesSlowBackends()
+	})
+})
+
+// dialApp opens a connection to host on the listener that requests with
+// scheme() go to, with the TLS settings from the config for https.
+func dialApp(host string) (net.Conn, error) {
+	if scheme() != "https" {
+		return net.Dial("tcp", host+":80")
+	}
+
+	tlsConfig, err := httpclient.TLSConfig(Config)
+	if err != nil {
+		return nil, err
+	}
+	return tls.Dial("tcp", host+":443", tlsConfig)
+}
@@ -0,0 +1,191 @@
+package routing_test
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capability"
+	"code.cloudfoundry.org/istio-acceptance-tests/httpclient"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+	"golang.org/x/net/websocket"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+)
+
+const (
+	webSocketEchoApp         = "../assets/websocket-echo"
+	webSocketEchoAppManifest = "../assets/websocket-echo/manifest.yml"
+	webSocketMessages        = 5
+	maxWebSocketIdlePeriod   = time.Minute
+)
+
+var _ = Describe("WebSockets", func() {
+	var (
+		app          string
+		wsURL        string
+		stoppedApp   string
+		proxyDroplet = "../assets/proxy.tgz"
+	)
+
+	BeforeEach(func() {
+		app = generator.PrefixedRandomName(Config.GetNamePrefix(), "websocket")
+	})
+
+	itCarriesWebSockets := func() {
+		It("carries frames in both directions", func() {
+			ws := dialWebSocket(wsURL)
+			defer ws.Close()
+
+			exchangeMessages(ws, webSocketMessages)
+		})
+
+		It("keeps idle connections open", func() {
+			ws := dialWebSocket(wsURL)
+			defer ws.Close()
+			exchangeMessages(ws, 1)
+
+			idlePeriod := maxWebSocketIdlePeriod
+			if idleTimeout := Config.GetTimeoutPolicy().GetIdleTimeout(); idleTimeout > 0 && idleTimeout/2 < idlePeriod {
+				idlePeriod = idleTimeout / 2
+			}
+			By(fmt.Sprintf("leaving the connection idle for %s", idlePeriod))
+			time.Sleep(idlePeriod)
+
+			exchangeMessages(ws, 1)
+		})
+
+		It("closes connections cleanly when the app is stopped", func() {
+			ws := dialWebSocket(wsURL)
+			defer ws.Close()
+			exchangeMessages(ws, 1)
+
+			Expect(cf.Cf("stop", stoppedApp).Wait(cfTimeout)).To(Exit(0))
+
+			Expect(ws.SetReadDeadline(time.Now().Add(requestTimeout))).To(Succeed())
+			var message string
+			Expect(websocket.Message.Receive(ws, &message)).To(Equal(io.EOF))
+		})
+	}
+
+	Context("on the external istio domain", func() {
+		BeforeEach(func() {
+			pushWebSocketEcho(app, istioDomain())
+			isUpAndRoutable(fmt.Sprintf("%s://%s.%s", scheme(), app, istioDomain()))
+
+			wsURL = fmt.Sprintf("%s://%s.%s/echo", webSocketScheme(), app, istioDomain())
+			stoppedApp = app
+		})
+
+		itCarriesWebSockets()
+
+		Context("over HTTPS with frontend certs", func() {
+			BeforeEach(func() {
+				requireCapabilities(capability.TLSTermination)
+			})
+
+			It("carries frames in both directions", func() {
+				ws := dialWebSocket(fmt.Sprintf("wss://%s.%s/echo", app, istioDomain()))
+				defer ws.Close()
+
+				exchangeMessages(ws, webSocketMessages)
+			})
+		})
+	})
+
+	// The proxy droplet only relays plain GET requests, so it checks that the
+	// internal route is reachable and the upgrade itself goes through the
+	// relay endpoint of a websocket-echo app on the istio domain.
+	Context("on the internal istio domain", func() {
+		BeforeEach(func() {
+			requireCapabilities(capability.InternalRoutes, capability.SidecarProxying)
+
+			proxy := generator.PrefixedRandomName(Config.GetNamePrefix(), "proxy")
+			caller := generator.PrefixedRandomName(Config.GetNamePrefix(), "websocket-caller")
+
+			Expect(cf.Cf("push", proxy,
+				"-d", istioDomain(),
+				"-s", "cflinuxfs3",
+				"--hostname", proxy,
+				"--droplet", proxyDroplet,
+				"-i", "1",
+				"-m", "32M",
+				"-k", "75M").Wait(pushTimeout)).To(Exit(0))
+			pushWebSocketEcho(caller, istioDomain())
+			pushWebSocketEcho(app, internalIstioDomain())
+
+			Expect(cf.Cf("add-network-policy", proxy, "--destination-app", app).Wait(cfTimeout)).To(Exit(0))
+			Expect(cf.Cf("add-network-policy", caller, "--destination-app", app).Wait(cfTimeout)).To(Exit(0))
+
+			target := fmt.Sprintf("%s.%s:8080", app, internalIstioDomain())
+			isUpAndRoutable(fmt.Sprintf("%s://%s.%s/proxy/%s", scheme(), proxy, istioDomain(), target))
+
+			wsURL = fmt.Sprintf("%s://%s.%s/relay/%s", webSocketScheme(), caller, istioDomain(), target)
+			stoppedApp = app
+		})
+
+		itCarriesWebSockets()
+	})
+})
+
+func pushWebSocketEcho(name, domain string) {
+	Expect(cf.Cf("push", name,
+		"-s", "cflinuxfs3",
+		"-d", domain,
+		"--hostname", name,
+		"-f", webSocketEchoAppManifest,
+		"-p", webSocketEchoApp).Wait(pushTimeout)).To(Exit(0))
+}
+
+// webSocketScheme is the WebSocket scheme that matches scheme().
+func webSocketScheme() string {
+	if scheme() == "https" {
+		return "wss"
+	}
+	return "ws"
+}
+
+// dialWebSocket opens a WebSocket to wsURL, retrying until the upgrade
+// succeeds. wss URLs use the TLS settings from the config.
+func dialWebSocket(wsURL string) *websocket.Conn {
+	location, err := url.Parse(wsURL)
+	Expect(err).NotTo(HaveOccurred())
+
+	origin := "http"
+	if location.Scheme == "wss" {
+		origin = "https"
+	}
+	config, err := websocket.NewConfig(wsURL, fmt.Sprintf("%s://%s", origin, location.Host))
+	Expect(err).NotTo(HaveOccurred())
+	if location.Scheme == "wss" {
+		config.TlsConfig, err = httpclient.TLSConfig(Config)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	var ws *websocket.Conn
+	Eventually(func() error {
+		ws, err = websocket.DialConfig(config)
+		return err
+	}, convergenceTimeout, time.Second).Should(Succeed())
+	return ws
+}
+
+// exchangeMessages sends count messages, expecting each to be echoed back
+// before the next is sent.
+func exchangeMessages(ws *websocket.Conn, count int) {
+	Expect(ws.SetDeadline(time.Now().Add(requestTimeout))).To(Succeed())
+	defer ws.SetDeadline(time.Time{})
+
+	for i := 1; i <= count; i++ {
+		sent := fmt.Sprintf("message %d at %s", i, time.Now().Format(time.RFC3339Nano))
+		Expect(websocket.Message.Send(ws, sent)).To(Succeed())
+
+		var received string
+		Expect(websocket.Message.Receive(ws, &received)).To(Succeed())
+		Expect(received).To(Equal(sent))
+	}
+}
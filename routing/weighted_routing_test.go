@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"strings"
 	"time"
 
+	"code.cloudfoundry.org/istio-acceptance-tests/capability"
+	"code.cloudfoundry.org/istio-acceptance-tests/helpers"
+	"code.cloudfoundry.org/istio-acceptance-tests/matchers"
 	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
 	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
 
@@ -26,13 +28,20 @@ var _ = Describe("Weighted Routing", func() {
 		proxyDroplet        = "../assets/proxy.tgz"
 		helloRoutingDroplet = "../assets/hello-golang.tgz"
 		holaRoutingDroplet  = "../assets/hola-golang.tgz"
+
+		// internalRoutes is whether the internal routes can be weighted
+		// too, which takes the sidecar of the proxy.
+		internalRoutes bool
 	)
 
 	BeforeEach(func() {
+		requireCapabilities(capability.WeightedRouting)
+		internalRoutes = hasCapabilities(capability.InternalRoutes, capability.SidecarProxying)
+
 		domain = istioDomain()
 		internalDomain = internalIstioDomain()
 
-		proxyFrontend = generator.PrefixedRandomName("iats", "proxy1")
+		proxyFrontend = generator.PrefixedRandomName(Config.GetNamePrefix(), "proxy1")
 		Expect(cf.Cf("push", proxyFrontend,
 			"-s", "cflinuxfs3",
 			"-i", "1",
@@ -40,9 +49,9 @@ var _ = Describe("Weighted Routing", func() {
 			"-k", "75M",
 			"-d", domain,
 			"--hostname", proxyFrontend,
-			"--droplet", proxyDroplet).Wait(defaultTimeout)).To(Exit(0))
+			"--droplet", proxyDroplet).Wait(pushTimeout)).To(Exit(0))
 
-		app1 = generator.PrefixedRandomName("iats", "app1")
+		app1 = generator.PrefixedRandomName(Config.GetNamePrefix(), "app1")
 		Expect(cf.Cf("push", app1,
 			"-s", "cflinuxfs3",
 			"-i", "1",
@@ -51,10 +60,9 @@ var _ = Describe("Weighted Routing", func() {
 			"-d", domain,
 			"--hostname", app1,
 			"--droplet", helloRoutingDroplet,
-			"--no-start").Wait(defaultTimeout)).To(Exit(0))
-		Expect(cf.Cf("map-route", app1, internalDomain, "--hostname", app1).Wait(defaultTimeout)).To(Exit(0))
+			"--no-start").Wait(pushTimeout)).To(Exit(0))
 
-		app2 = generator.PrefixedRandomName("iats", "app2")
+		app2 = generator.PrefixedRandomName(Config.GetNamePrefix(), "app2")
 		Expect(cf.Cf("push", app2,
 			"-s", "cflinuxfs3",
 			"-i", "1",
@@ -63,11 +71,15 @@ var _ = Describe("Weighted Routing", func() {
 			"-d", domain,
 			"--hostname", app2,
 			"--droplet", holaRoutingDroplet,
-			"--no-start").Wait(defaultTimeout)).To(Exit(0))
-		Expect(cf.Cf("map-route", app2, internalDomain, "--hostname", app2).Wait(defaultTimeout)).To(Exit(0))
+			"--no-start").Wait(pushTimeout)).To(Exit(0))
 
-		Expect(cf.Cf("add-network-policy", proxyFrontend, "--destination-app", app1).Wait(defaultTimeout)).To(Exit(0))
-		Expect(cf.Cf("add-network-policy", proxyFrontend, "--destination-app", app2).Wait(defaultTimeout)).To(Exit(0))
+		if !internalRoutes {
+			return
+		}
+		Expect(cf.Cf("map-route", app1, internalDomain, "--hostname", app1).Wait(cfTimeout)).To(Exit(0))
+		Expect(cf.Cf("map-route", app2, internalDomain, "--hostname", app2).Wait(cfTimeout)).To(Exit(0))
+		Expect(cf.Cf("add-network-policy", proxyFrontend, "--destination-app", app1).Wait(cfTimeout)).To(Exit(0))
+		Expect(cf.Cf("add-network-policy", proxyFrontend, "--destination-app", app2).Wait(cfTimeout)).To(Exit(0))
 	})
 
 	Context("when weights are assigned to routes", func() {
@@ -81,116 +93,100 @@ var _ = Describe("Weighted Routing", func() {
 			externalRouteURL        string
 			proxiedInternalRouteURL string
 			destinationsToWeights   map[string]int
+			greetingsToApps         map[string]string
 		)
 
 		BeforeEach(func() {
-			externalHostname = generator.PrefixedRandomName("greetings", "app")
-			Expect(cf.Cf("create-route", spaceName(), domain, "--hostname", externalHostname).Wait(defaultTimeout)).To(Exit(0))
-
-			internalHostname = generator.PrefixedRandomName("greetings", "app")
-			Expect(cf.Cf("create-route", spaceName(), internalDomain, "--hostname", internalHostname).Wait(defaultTimeout)).To(Exit(0))
+			externalHostname = generator.PrefixedRandomName(Config.GetNamePrefix(), "greetings")
+			Expect(cf.Cf("create-route", spaceName(), domain, "--hostname", externalHostname).Wait(cfTimeout)).To(Exit(0))
 
-			guid1 := cf.Cf("app", app1, "--guid").Wait(defaultTimeout).Out.Contents()
-			appGuid1 = strings.TrimSpace(string(guid1))
-			guid2 := cf.Cf("app", app2, "--guid").Wait(defaultTimeout).Out.Contents()
-			appGuid2 = strings.TrimSpace(string(guid2))
+			appGuid1 = applicationGuid(app1)
+			appGuid2 = applicationGuid(app2)
 
 			externalRouteGuid = routeGuid(spaceName(), externalHostname)
-			externalRouteURL = fmt.Sprintf("http://%s.%s", externalHostname, domain)
+			externalRouteURL = fmt.Sprintf("%s://%s.%s", scheme(), externalHostname, domain)
 
-			internalRouteGuid = routeGuid(spaceName(), internalHostname)
-			proxiedInternalRouteURL = fmt.Sprintf("http://%s.%s/proxy/%s.%s:8080", proxyFrontend, domain, internalHostname, internalDomain)
+			if internalRoutes {
+				internalHostname = generator.PrefixedRandomName(Config.GetNamePrefix(), "greetings")
+				Expect(cf.Cf("create-route", spaceName(), internalDomain, "--hostname", internalHostname).Wait(cfTimeout)).To(Exit(0))
+				internalRouteGuid = routeGuid(spaceName(), internalHostname)
+				proxiedInternalRouteURL = fmt.Sprintf("%s://%s.%s/proxy/%s.%s:8080", scheme(), proxyFrontend, domain, internalHostname, internalDomain)
+			}
 
 			destinationsToWeights = make(map[string]int)
 			destinationsToWeights[appGuid1] = 1
 			destinationsToWeights[appGuid2] = 9
+
+			greetingsToApps = map[string]string{
+				"hello": appGuid1,
+				"hola":  appGuid2,
+			}
 		})
 
 		It("balances internal routes according to the weights assigned to them", func() {
+			requireCapabilities(capability.InternalRoutes, capability.SidecarProxying)
+
 			addWeightedDestinations(internalRouteGuid, destinationsToWeights)
 
-			Expect(cf.Cf("start", app1).Wait(defaultTimeout)).To(Exit(0))
-			Expect(cf.Cf("start", app2).Wait(defaultTimeout)).To(Exit(0))
+			Expect(cf.Cf("start", app1).Wait(pushTimeout)).To(Exit(0))
+			Expect(cf.Cf("start", app2).Wait(pushTimeout)).To(Exit(0))
 
 			// Make sure both apps are individually routable
 			// before checking the shared weighted route
-			isUpAndRoutable(fmt.Sprintf("http://%s.%s/proxy/%s.%s:8080", proxyFrontend, domain, app1, internalDomain))
-			isUpAndRoutable(fmt.Sprintf("http://%s.%s/proxy/%s.%s:8080", proxyFrontend, domain, app2, internalDomain))
+			isUpAndRoutable(fmt.Sprintf("%s://%s.%s/proxy/%s.%s:8080", scheme(), proxyFrontend, domain, app1, internalDomain))
+			isUpAndRoutable(fmt.Sprintf("%s://%s.%s/proxy/%s.%s:8080", scheme(), proxyFrontend, domain, app2, internalDomain))
 
 			time.Sleep(60 * time.Second)
-			var app1RespCount, app2RespCount int
-			for i := 0; i < 100; i++ {
-				switch greetingFromApp(proxiedInternalRouteURL) {
-				case "hello":
-					app1RespCount++
-				case "hola":
-					app2RespCount++
-				}
-			}
-
-			Expect(app1RespCount).To(BeNumerically("~", 10, 10), `given a 10%% route weight for app 1, the expected response count is ~10`)
-			Expect(app2RespCount).To(BeNumerically("~", 90, 10), `given a 90%% route weight for app 2, the expected response count is ~90`)
+			observed := observeDistribution(proxiedInternalRouteURL, greetingsToApps, destinationsToWeights)
+			Expect(observed).To(matchers.HaveWeightedDistribution(destinationsToWeights).WithConfidence(weightedRoutingConfidence()))
 		})
 
 		It("balances external routes according to the weights assigned to them", func() {
 			addWeightedDestinations(externalRouteGuid, destinationsToWeights)
 
-			Expect(cf.Cf("start", app1).Wait(defaultTimeout)).To(Exit(0))
-			Expect(cf.Cf("start", app2).Wait(defaultTimeout)).To(Exit(0))
+			Expect(cf.Cf("start", app1).Wait(pushTimeout)).To(Exit(0))
+			Expect(cf.Cf("start", app2).Wait(pushTimeout)).To(Exit(0))
 
 			// Make sure both apps are individually routable
 			// before checking the shared weighted route
-			isUpAndRoutable(fmt.Sprintf("http://%s.%s", app1, domain))
-			isUpAndRoutable(fmt.Sprintf("http://%s.%s", app2, domain))
-
-			var app1RespCount, app2RespCount int
-			for i := 0; i < 100; i++ {
-				switch greetingFromApp(externalRouteURL) {
-				case "hello":
-					app1RespCount++
-				case "hola":
-					app2RespCount++
-				}
-			}
+			isUpAndRoutable(fmt.Sprintf("%s://%s.%s", scheme(), app1, domain))
+			isUpAndRoutable(fmt.Sprintf("%s://%s.%s", scheme(), app2, domain))
 
-			Expect(app1RespCount).To(BeNumerically("~", 10, 10), `given a 10%% route weight for app 1, the expected response count is ~10`)
-			Expect(app2RespCount).To(BeNumerically("~", 90, 10), `given a 90%% route weight for app 2, the expected response count is ~90`)
+			observed := observeDistribution(externalRouteURL, greetingsToApps, destinationsToWeights)
+			Expect(observed).To(matchers.HaveWeightedDistribution(destinationsToWeights).WithConfidence(weightedRoutingConfidence()))
 		})
 	})
 })
 
 func addWeightedDestinations(routeGUID string, appGUIDToWeights map[string]int) {
-	Skip("This will need to be updated to support destinations!")
-	var destinationsBody strings.Builder
-	counter := len(appGUIDToWeights)
-	for appGUID, weight := range appGUIDToWeights {
-		destinationsBody.WriteString(fmt.Sprintf(`{"app":{"guid":"%s"},"weight":%d}`, appGUID, weight))
-		counter--
-		if counter > 0 {
-			destinationsBody.WriteString(",")
+	helpers.AddWeightedDestinations(CloudController, routeGUID, appGUIDToWeights)
+}
+
+// observeDistribution sends enough requests to route to test it against the
+// given weights, and counts the responses per app using the greeting each app
+// responds with. Unrecognised greetings are counted under their own name.
+func observeDistribution(route string, greetingsToApps map[string]string, appGUIDToWeights map[string]int) map[string]int {
+	observed := map[string]int{}
+	samples := matchers.SampleSize(appGUIDToWeights, weightedRoutingConfidence())
+	for i := 0; i < samples; i++ {
+		greeting := greetingFromApp(route)
+		if appGUID, ok := greetingsToApps[greeting]; ok {
+			observed[appGUID]++
+		} else {
+			observed[greeting]++
 		}
 	}
-
-	Expect(cf.Cf(
-		"curl",
-		"-f",
-		fmt.Sprintf("/v3/routes/%s/destinations", routeGUID),
-		"-H", "Content-type: application/json",
-		"-X", "PATCH",
-		"-d", fmt.Sprintf(`{
-					"destinations": [%s]
-				}`, destinationsBody.String()),
-	).Wait(defaultTimeout)).To(Exit(0))
+	return observed
 }
 
 func isUpAndRoutable(route string) {
 	Eventually(func() (int, error) {
 		return getStatusCode(route)
-	}, defaultTimeout, time.Second).Should(Equal(http.StatusOK))
+	}, convergenceTimeout, time.Second).Should(Equal(http.StatusOK))
 }
 
 func greetingFromApp(route string) string {
-	res, err := http.Get(route)
+	res, err := httpClient.Get(route)
 	Expect(err).ToNot(HaveOccurred())
 	defer res.Body.Close()
 	body, err := ioutil.ReadAll(res.Body)
@@ -0,0 +1,130 @@
+package soak_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capability"
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"code.cloudfoundry.org/istio-acceptance-tests/helpers"
+	"code.cloudfoundry.org/istio-acceptance-tests/httpclient"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var (
+	Config       config.Config
+	TestSetup    *workflowhelpers.ReproducibleTestSuiteSetup
+	Capabilities capability.Set
+
+	// Timeouts for each kind of operation, set from the config before any
+	// spec runs.
+	pushTimeout        time.Duration
+	cfTimeout          time.Duration
+	convergenceTimeout time.Duration
+
+	// httpClient makes every request to apps, with the TLS settings and
+	// request timeout from the config.
+	httpClient *http.Client
+)
+
+func TestSoak(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Soak Suite")
+}
+
+var _ = SynchronizedBeforeSuite(func() []byte {
+	return []byte{}
+}, func([]byte) {
+	var err error
+	configPath := os.Getenv("CONFIG")
+	Expect(configPath).NotTo(BeEmpty())
+	Config, err = config.NewConfig(configPath)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(Config.Validate()).To(Succeed())
+	if !soakEnabled() {
+		return
+	}
+
+	pushTimeout = Config.GetPushTimeout()
+	cfTimeout = Config.GetCFAPITimeout()
+	convergenceTimeout = Config.GetRouteConvergenceTimeout()
+	httpClient, err = httpclient.New(Config)
+	Expect(err).NotTo(HaveOccurred())
+	Capabilities = capability.NewDetector(Config, capi.NewClient(Config)).Resolve()
+	helpers.EnsureInternalAppsDomain(Config, cfTimeout)
+
+	TestSetup = workflowhelpers.NewTestSuiteSetup(Config)
+	TestSetup.Setup()
+})
+
+var _ = SynchronizedAfterSuite(func() {
+	if TestSetup != nil {
+		TestSetup.Teardown()
+	}
+}, func() {
+	if soakEnabled() {
+		fmt.Println(Capabilities.Summary())
+	}
+})
+
+// soakEnabled returns whether the config asks for a soak test. The suite
+// runs along with the others, so it does nothing unless asked to.
+func soakEnabled() bool {
+	return Config.Soak.Duration != ""
+}
+
+// hasCapabilities returns whether the foundation has all of names.
+func hasCapabilities(names ...capability.Name) bool {
+	return Capabilities.Require(names...) == nil
+}
+
+func istioDomain() string {
+	return Config.IstioDomain
+}
+
+func internalIstioDomain() string {
+	if Config.CFInternalIstioDomain == "" {
+		return config.DefaultInternalIstioDomain
+	}
+	return Config.CFInternalIstioDomain
+}
+
+func getStatusCode(appURL string) (int, error) {
+	res, err := httpClient.Get(appURL)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+	return res.StatusCode, nil
+}
+
+// cfRun runs a cf command for the churn, which happens away from the spec's
+// goroutine and so reports failures as errors instead of failing the spec.
+// It runs cf itself, since cf.Cf makes assertions, which would panic there.
+func cfRun(timeout time.Duration, args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "cf", args...)
+	cmd.Stdout = GinkgoWriter
+	cmd.Stderr = GinkgoWriter
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("cf %s did not exit within %s", strings.Join(args, " "), timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("cf %s: %s", strings.Join(args, " "), err)
+	}
+	return nil
+}
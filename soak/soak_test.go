@@ -0,0 +1,163 @@
+package soak_test
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capability"
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"code.cloudfoundry.org/istio-acceptance-tests/traffic"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Soak", func() {
+	var (
+		policy       config.SoakPolicy
+		helloDroplet = "../assets/hello-golang.tgz"
+		proxyDroplet = "../assets/proxy.tgz"
+		proxy        string
+		apps         []string
+		targets      []traffic.Target
+	)
+
+	push := func(app, droplet, memory string) {
+		Expect(cf.Cf("push", app,
+			"-d", istioDomain(),
+			"-s", "cflinuxfs3",
+			"--hostname", app,
+			"--droplet", droplet,
+			"-i", "1",
+			"-m", memory,
+			"-k", "75M").Wait(pushTimeout)).To(Exit(0))
+	}
+
+	BeforeEach(func() {
+		if !soakEnabled() {
+			Skip("soak.duration is not set")
+		}
+		policy = Config.GetSoakPolicy()
+		internalRoutes := hasCapabilities(capability.InternalRoutes, capability.SidecarProxying)
+
+		var proxyURL string
+		if internalRoutes {
+			proxy = generator.PrefixedRandomName(Config.GetNamePrefix(), "soakproxy")
+			push(proxy, proxyDroplet, "32M")
+			proxyURL = fmt.Sprintf("%s://%s.%s", Config.GetAppScheme(), proxy, istioDomain())
+		}
+
+		for i := 0; i < policy.Apps; i++ {
+			app := generator.PrefixedRandomName(Config.GetNamePrefix(), "soak")
+			push(app, helloDroplet, "16M")
+			apps = append(apps, app)
+			targets = append(targets, traffic.Target{
+				Name: "external " + app,
+				URL:  fmt.Sprintf("%s://%s.%s", Config.GetAppScheme(), app, istioDomain()),
+			})
+
+			if internalRoutes {
+				Expect(cf.Cf("map-route", app, internalIstioDomain(), "--hostname", app).Wait(cfTimeout)).To(Exit(0))
+				Expect(cf.Cf("add-network-policy", proxy, "--destination-app", app).Wait(cfTimeout)).To(Exit(0))
+				targets = append(targets, traffic.Target{
+					Name: "internal " + app,
+					URL:  fmt.Sprintf("%s/proxy/%s.%s:8080", proxyURL, app, internalIstioDomain()),
+				})
+			}
+		}
+	})
+
+	AfterEach(func() {
+		for _, app := range apps {
+			Expect(cf.Cf("delete", app, "-f", "-r").Wait(cfTimeout)).To(Exit(0))
+		}
+		if proxy != "" {
+			Expect(cf.Cf("delete", proxy, "-f", "-r").Wait(cfTimeout)).To(Exit(0))
+		}
+		proxy = ""
+		apps = nil
+		targets = nil
+	})
+
+	// churn returns the actions changing the fleet while traffic flows:
+	// scaling each app of the fleet up and down, and mapping and deleting an
+	// extra route to it, neither of which should disturb its other routes.
+	churn := func() []traffic.Action {
+		actions := []traffic.Action{}
+		for _, app := range apps {
+			app := app
+			extraHostname := app + "-churn"
+			actions = append(actions,
+				traffic.Action{
+					Name: fmt.Sprintf("scale %s to 2 instances", app),
+					Run:  func() error { return cfRun(pushTimeout, "scale", app, "-i", "2") },
+				},
+				traffic.Action{
+					Name: fmt.Sprintf("map %s.%s to %s", extraHostname, istioDomain(), app),
+					Run: func() error {
+						return cfRun(cfTimeout, "map-route", app, istioDomain(), "--hostname", extraHostname)
+					},
+				},
+				traffic.Action{
+					Name: fmt.Sprintf("scale %s to 1 instance", app),
+					Run:  func() error { return cfRun(pushTimeout, "scale", app, "-i", "1") },
+				},
+				traffic.Action{
+					Name: fmt.Sprintf("delete %s.%s", extraHostname, istioDomain()),
+					Run: func() error {
+						return cfRun(cfTimeout, "delete-route", istioDomain(), "--hostname", extraHostname, "-f")
+					},
+				},
+			)
+		}
+		return actions
+	}
+
+	It("keeps every route available while routes and instances churn", func() {
+		By("waiting for every route to respond")
+		for _, target := range targets {
+			Eventually(func() (int, error) {
+				return getStatusCode(target.URL)
+			}, convergenceTimeout, time.Second).Should(Equal(http.StatusOK), target.Name)
+		}
+
+		By(fmt.Sprintf("sending %v requests per second to %d routes for %s", policy.RequestsPerSecond, len(targets), policy.GetDuration()))
+		recorder := traffic.NewRecorder()
+		driver := traffic.Driver{RequestsPerSecond: policy.RequestsPerSecond, Get: getStatusCode, Recorder: recorder}
+		churner := traffic.Churner{Actions: churn(), Interval: policy.GetChurnInterval(), Recorder: recorder}
+
+		stop := make(chan struct{})
+		wg := sync.WaitGroup{}
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			driver.Run(targets, stop)
+		}()
+		go func() {
+			defer wg.Done()
+			churner.Run(stop)
+		}()
+		time.Sleep(policy.GetDuration())
+		close(stop)
+		wg.Wait()
+
+		report := recorder.Report(time.Now())
+		fmt.Println(report)
+		if policy.ReportDir != "" {
+			Expect(report.Write(filepath.Join(policy.ReportDir, "soak-availability.json"))).To(Succeed())
+		}
+
+		if policy.MinAvailability > 0 {
+			for name, summary := range report.Targets {
+				Expect(summary.Availability).To(BeNumerically(">=", policy.MinAvailability),
+					fmt.Sprintf("%s was available %.3f%% of the time, longest outage %s", name, summary.Availability, summary.LongestOutage.Duration))
+			}
+		}
+	})
+})
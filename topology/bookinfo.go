@@ -0,0 +1,93 @@
+package topology
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+)
+
+// BookinfoPort is the port every bookinfo service listens on.
+const BookinfoPort = 9080
+
+// Bookinfo is one deployment of the bookinfo apps. The product page is routed
+// on the istio domain and finds the other services by their hostnames on
+// ServicesDomain. Suffix tells several deployments in one space apart.
+type Bookinfo struct {
+	Suffix         string
+	ServicesDomain string
+}
+
+// NewBookinfo returns a deployment whose services are on internalAppsDomain,
+// or, when suffix is set, on a subdomain of it named after the suffix, so
+// that copies in one space do not share the services' routes.
+func NewBookinfo(suffix, internalAppsDomain string) Bookinfo {
+	servicesDomain := internalAppsDomain
+	if suffix != "" {
+		servicesDomain = suffix + "." + internalAppsDomain
+	}
+	return Bookinfo{Suffix: suffix, ServicesDomain: servicesDomain}
+}
+
+// App returns the name of one of the bookinfo apps in this deployment.
+func (b Bookinfo) App(name string) string {
+	if b.Suffix == "" {
+		return name
+	}
+	return name + "-" + b.Suffix
+}
+
+// Apps returns the names of the apps in this deployment.
+func (b Bookinfo) Apps() []string {
+	return []string{b.App("productpage"), b.App("details"), b.App("reviews"), b.App("ratings")}
+}
+
+// Push pushes the bookinfo apps from the images in c and points them at
+// each other. The apps cannot reach each other until AddPolicies is run.
+func (b Bookinfo) Push(cf CF, c config.Config) error {
+	productpage, reviews := b.App("productpage"), b.App("reviews")
+	return cf.run(
+		[]string{"push", productpage, "-o", c.ProductPageDockerWithTag, "-d", c.IstioDomain, "--hostname", productpage},
+		[]string{"push", b.App("ratings"), "-o", c.RatingsDockerWithTag, "-d", b.ServicesDomain, "--hostname", "ratings"},
+		[]string{"push", reviews, "-o", c.ReviewsDockerWithTag, "-d", b.ServicesDomain, "--hostname", "reviews", "-u", "none"},
+		[]string{"push", b.App("details"), "-o", c.DetailsDockerWithTag, "-d", b.ServicesDomain, "--hostname", "details"},
+		[]string{"set-env", productpage, "SERVICES_DOMAIN", b.ServicesDomain},
+		[]string{"restage", productpage},
+		[]string{"set-env", reviews, "SERVICES_DOMAIN", b.ServicesDomain},
+		[]string{"restage", reviews},
+	)
+}
+
+// AddPolicies allows the bookinfo services to call each other. It needs to
+// run as a user allowed to manage network policies.
+func (b Bookinfo) AddPolicies(cf CF) error {
+	port := fmt.Sprintf("%d", BookinfoPort)
+	return cf.run(
+		[]string{"add-network-policy", b.App("productpage"), "--destination-app", b.App("details"), "--protocol", "tcp", "--port", port},
+		[]string{"add-network-policy", b.App("productpage"), "--destination-app", b.App("reviews"), "--protocol", "tcp", "--port", port},
+		[]string{"add-network-policy", b.App("reviews"), "--destination-app", b.App("ratings"), "--protocol", "tcp", "--port", port},
+	)
+}
+
+// Teardown deletes the bookinfo apps along with their routes.
+func (b Bookinfo) Teardown(cf CF) error {
+	commands := [][]string{}
+	for _, app := range b.Apps() {
+		commands = append(commands, []string{"delete", app, "-f", "-r"})
+	}
+	return cf.run(commands...)
+}
+
+// ProductPageURL returns the external URL of the product page.
+func (b Bookinfo) ProductPageURL(c config.Config) string {
+	return fmt.Sprintf("%s://%s.%s/productpage", c.GetAppScheme(), b.App("productpage"), c.IstioDomain)
+}
+
+// InternalURLs returns the URLs the product page and reviews call the other
+// services on.
+func (b Bookinfo) InternalURLs() []string {
+	urls := []string{}
+	for _, service := range []string{"details", "reviews", "ratings"} {
+		urls = append(urls, fmt.Sprintf("http://%s.%s:%d", service, b.ServicesDomain, BookinfoPort))
+	}
+	return urls
+}
@@ -0,0 +1,68 @@
+// Package topology deploys the demo topologies the acceptance suites run
+// against, so that they can also be deployed and torn down outside of a test
+// run with the iats command.
+package topology
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
+)
+
+// CF runs a cf CLI command and returns an error if it fails.
+type CF func(args ...string) error
+
+// ExecCF returns a CF that runs the cf binary on the PATH and passes its
+// output through to stdout and stderr. env is added to the environment of
+// each command, such as CF_USERNAME and CF_PASSWORD for cf auth, which keeps
+// them out of the command line.
+func ExecCF(stdout, stderr io.Writer, env ...string) CF {
+	return func(args ...string) error {
+		cmd := exec.Command("cf", args...)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		if len(env) > 0 {
+			cmd.Env = append(os.Environ(), env...)
+		}
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("cf %s: %s", strings.Join(args, " "), err)
+		}
+		return nil
+	}
+}
+
+// run runs each command in turn, stopping at the first that fails.
+func (cf CF) run(commands ...[]string) error {
+	for _, args := range commands {
+		if err := cf(args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureInternalDomain creates the internal shared domain name unless it
+// already exists.
+func EnsureInternalDomain(cc *capi.Client, name string) error {
+	_, err := cc.DomainByName(name)
+	if _, ok := err.(*capi.NotFoundError); ok {
+		_, err = cc.CreateSharedDomain(name, true)
+	}
+	return err
+}
+
+// DeleteInternalDomain deletes the domain name unless it is already gone.
+func DeleteInternalDomain(cc *capi.Client, name string) error {
+	domain, err := cc.DomainByName(name)
+	if _, ok := err.(*capi.NotFoundError); ok {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return cc.DeleteDomain(domain.GUID)
+}
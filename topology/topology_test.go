@@ -0,0 +1,188 @@
+package topology_test
+
+import (
+	"errors"
+	"strings"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+	"code.cloudfoundry.org/istio-acceptance-tests/fakecapi"
+	"code.cloudfoundry.org/istio-acceptance-tests/topology"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Topology", func() {
+	var (
+		c        config.Config
+		commands []string
+		failOn   string
+		cf       topology.CF
+	)
+
+	BeforeEach(func() {
+		c = config.Config{
+			IstioDomain:              "istio.example.com",
+			ProductPageDockerWithTag: "productpage:1",
+			ReviewsDockerWithTag:     "reviews:1",
+			RatingsDockerWithTag:     "ratings:1",
+			DetailsDockerWithTag:     "details:1",
+		}
+		commands = nil
+		failOn = ""
+		cf = func(args ...string) error {
+			command := strings.Join(args, " ")
+			commands = append(commands, command)
+			if failOn != "" && strings.HasPrefix(command, failOn) {
+				return errors.New("exit status 1")
+			}
+			return nil
+		}
+	})
+
+	Describe("Bookinfo", func() {
+		It("points the services at each other on the services domain", func() {
+			bookinfo := topology.Bookinfo{Suffix: "copy", ServicesDomain: "copy.apps.internal"}
+			Expect(bookinfo.Push(cf, c)).To(Succeed())
+
+			Expect(commands).To(ContainElement("push productpage-copy -o productpage:1 -d istio.example.com --hostname productpage-copy"))
+			Expect(commands).To(ContainElement("push reviews-copy -o reviews:1 -d copy.apps.internal --hostname reviews -u none"))
+			Expect(commands).To(ContainElement("set-env productpage-copy SERVICES_DOMAIN copy.apps.internal"))
+			Expect(commands).To(ContainElement("set-env reviews-copy SERVICES_DOMAIN copy.apps.internal"))
+			Expect(bookinfo.ProductPageURL(c)).To(Equal("http://productpage-copy.istio.example.com/productpage"))
+			Expect(bookinfo.InternalURLs()).To(ContainElement("http://ratings.copy.apps.internal:9080"))
+		})
+
+		It("puts the services of each suffixed copy on a domain of its own", func() {
+			Expect(topology.NewBookinfo("", "apps.internal").ServicesDomain).To(Equal("apps.internal"))
+			Expect(topology.NewBookinfo("copy", "apps.internal").ServicesDomain).To(Equal("copy.apps.internal"))
+		})
+
+		It("allows productpage to call details and reviews, and reviews to call ratings", func() {
+			Expect(topology.Bookinfo{}.AddPolicies(cf)).To(Succeed())
+
+			Expect(commands).To(ConsistOf(
+				"add-network-policy productpage --destination-app details --protocol tcp --port 9080",
+				"add-network-policy productpage --destination-app reviews --protocol tcp --port 9080",
+				"add-network-policy reviews --destination-app ratings --protocol tcp --port 9080",
+			))
+		})
+
+		It("stops at the first command that fails", func() {
+			failOn = "push reviews"
+
+			Expect(topology.Bookinfo{ServicesDomain: "apps.internal"}.Push(cf, c)).To(MatchError(ContainSubstring("exit status 1")))
+			Expect(commands[len(commands)-1]).To(HavePrefix("push reviews"))
+		})
+	})
+
+	Describe("DeleteInternalDomain", func() {
+		var (
+			server *fakecapi.Server
+			cc     *capi.Client
+		)
+
+		BeforeEach(func() {
+			server = fakecapi.NewServer()
+			c.CFApi = server.URL()
+			c.AdminUser = "admin"
+			c.AdminPassword = "secret"
+			cc = capi.NewClient(c)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("deletes the domain", func() {
+			server.AddDomain("apps.internal", true)
+			server.AddDomain("copy.apps.internal", true)
+
+			Expect(topology.DeleteInternalDomain(cc, "copy.apps.internal")).To(Succeed())
+
+			domains := server.Domains()
+			Expect(domains).To(HaveLen(1))
+			Expect(domains[0].Name).To(Equal("apps.internal"))
+		})
+
+		It("succeeds when the domain is already gone", func() {
+			Expect(topology.DeleteInternalDomain(cc, "copy.apps.internal")).To(Succeed())
+		})
+	})
+
+	Describe("WeightedDemo", func() {
+		var (
+			server *fakecapi.Server
+			cc     *capi.Client
+			space  capi.Space
+			domain capi.Domain
+			demo   topology.WeightedDemo
+		)
+
+		BeforeEach(func() {
+			server = fakecapi.NewServer()
+			c.CFApi = server.URL()
+			c.AdminUser = "admin"
+			c.AdminPassword = "secret"
+			cc = capi.NewClient(c)
+
+			space = server.AddSpaceInOrganization("space", server.AddOrganization("org").GUID)
+			domain = server.AddDomain("istio.example.com", false)
+			demo = topology.WeightedDemo{
+				Hostname: "greetings",
+				Apps: []topology.WeightedApp{
+					{Name: "hello", Droplet: "hello.tgz", Weight: 1},
+					{Name: "hola", Droplet: "hola.tgz", Weight: 9},
+				},
+			}
+
+			cf = func(args ...string) error {
+				commands = append(commands, strings.Join(args, " "))
+				switch args[0] {
+				case "push":
+					server.AddApp(args[1], space.GUID)
+				case "create-route":
+					server.AddRoute(args[4], "", domain.GUID, space.GUID)
+				}
+				return nil
+			}
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("weights the shared route between the apps", func() {
+			Expect(demo.Deploy(cf, cc, c, "org", "space")).To(Succeed())
+
+			Expect(commands).To(ContainElement("map-route hola istio.apps.internal --hostname hola"))
+			routes := server.Routes()
+			Expect(routes).To(HaveLen(1))
+
+			weights := map[int]bool{}
+			for _, destination := range server.Destinations(routes[0].GUID) {
+				weights[*destination.Weight] = true
+			}
+			Expect(weights).To(Equal(map[int]bool{1: true, 9: true}))
+			Expect(demo.URL(c)).To(Equal("http://greetings.istio.example.com"))
+		})
+
+		It("links to the apps over https when the config prefers it", func() {
+			c.PreferHTTPS = true
+
+			Expect(demo.URL(c)).To(Equal("https://greetings.istio.example.com"))
+			Expect(demo.AppURLs(c)["hola"]).To(ContainElement("https://hola.istio.example.com"))
+		})
+
+		It("deletes the apps and the shared route", func() {
+			Expect(demo.Teardown(cf, c)).To(Succeed())
+
+			Expect(commands).To(Equal([]string{
+				"delete hello -f -r",
+				"delete hola -f -r",
+				"delete-route istio.example.com --hostname greetings -f",
+			}))
+		})
+	})
+})
@@ -0,0 +1,112 @@
+package topology
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/capi"
+	"code.cloudfoundry.org/istio-acceptance-tests/config"
+)
+
+// WeightedDemo is a set of apps sharing one route on the istio domain, with
+// the traffic split between them according to their weights. Each app also
+// keeps an external route and an internal route of its own name.
+type WeightedDemo struct {
+	Hostname string
+	Apps     []WeightedApp
+}
+
+type WeightedApp struct {
+	Name    string
+	Droplet string
+	Weight  int
+}
+
+// Deploy pushes the apps of the demo into spaceName in orgName, which cf
+// must be targeting, and weights the shared route through the v3
+// destinations API.
+func (d WeightedDemo) Deploy(cf CF, cc *capi.Client, c config.Config, orgName, spaceName string) error {
+	internalDomain := internalIstioDomain(c)
+	for _, app := range d.Apps {
+		err := cf.run(
+			[]string{"push", app.Name,
+				"-s", "cflinuxfs3",
+				"-i", "1",
+				"-m", "16M",
+				"-k", "75M",
+				"-d", c.IstioDomain,
+				"--hostname", app.Name,
+				"--droplet", app.Droplet},
+			[]string{"map-route", app.Name, internalDomain, "--hostname", app.Name},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	if err := cf("create-route", spaceName, c.IstioDomain, "--hostname", d.Hostname); err != nil {
+		return err
+	}
+
+	org, err := cc.OrganizationByName(orgName)
+	if err != nil {
+		return err
+	}
+	space, err := cc.SpaceByName(org.GUID, spaceName)
+	if err != nil {
+		return err
+	}
+	domain, err := cc.DomainByName(c.IstioDomain)
+	if err != nil {
+		return err
+	}
+	route, err := cc.RouteByHostInDomain(space.GUID, domain.GUID, d.Hostname)
+	if err != nil {
+		return err
+	}
+
+	appGUIDToWeights := map[string]int{}
+	for _, app := range d.Apps {
+		found, err := cc.AppByName(app.Name, space.GUID)
+		if err != nil {
+			return err
+		}
+		appGUIDToWeights[found.GUID] = app.Weight
+	}
+	_, err = cc.ReplaceDestinations(route.GUID, capi.WeightedDestinations(appGUIDToWeights))
+	return err
+}
+
+// Teardown deletes the apps of the demo along with their routes, and the
+// shared route.
+func (d WeightedDemo) Teardown(cf CF, c config.Config) error {
+	commands := [][]string{}
+	for _, app := range d.Apps {
+		commands = append(commands, []string{"delete", app.Name, "-f", "-r"})
+	}
+	commands = append(commands, []string{"delete-route", c.IstioDomain, "--hostname", d.Hostname, "-f"})
+	return cf.run(commands...)
+}
+
+// URL returns the external URL of the shared route.
+func (d WeightedDemo) URL(c config.Config) string {
+	return fmt.Sprintf("%s://%s.%s", c.GetAppScheme(), d.Hostname, c.IstioDomain)
+}
+
+// AppURLs returns the external and internal URLs of each app, keyed by app
+// name.
+func (d WeightedDemo) AppURLs(c config.Config) map[string][]string {
+	urls := map[string][]string{}
+	for _, app := range d.Apps {
+		urls[app.Name] = []string{
+			fmt.Sprintf("%s://%s.%s", c.GetAppScheme(), app.Name, c.IstioDomain),
+			fmt.Sprintf("http://%s.%s:8080", app.Name, internalIstioDomain(c)),
+		}
+	}
+	return urls
+}
+
+func internalIstioDomain(c config.Config) string {
+	if c.CFInternalIstioDomain == "" {
+		return config.DefaultInternalIstioDomain
+	}
+	return c.CFInternalIstioDomain
+}
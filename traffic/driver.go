@@ -0,0 +1,84 @@
+package traffic
+
+import (
+	"sync"
+	"time"
+)
+
+// Target is a URL to send traffic to, reported under Name.
+type Target struct {
+	Name string
+	URL  string
+}
+
+// Driver sends requests to each target at RequestsPerSecond. Requests to one
+// target are sent one after the other, so a request that hangs delays the
+// next ones instead of piling up, and is reported as part of the outage it
+// causes.
+type Driver struct {
+	RequestsPerSecond float64
+	// Get sends a request to url and returns the status of the response.
+	Get      func(url string) (int, error)
+	Recorder *Recorder
+}
+
+// Run sends traffic to targets until stop is closed, and returns once the
+// last requests have been recorded.
+func (d Driver) Run(targets []Target, stop <-chan struct{}) {
+	interval := time.Duration(float64(time.Second) / d.RequestsPerSecond)
+	wg := sync.WaitGroup{}
+	for _, t := range targets {
+		wg.Add(1)
+		go func(t Target) {
+			defer wg.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				at := time.Now()
+				status, err := d.Get(t.URL)
+				d.Recorder.Record(t.Name, at, status, err)
+
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+				}
+			}
+		}(t)
+	}
+	wg.Wait()
+}
+
+// Action is a change to the foundation made while traffic is flowing.
+type Action struct {
+	Name string
+	Run  func() error
+}
+
+// Churner runs its actions in turn, one every Interval, and records each.
+type Churner struct {
+	Actions  []Action
+	Interval time.Duration
+	Recorder *Recorder
+}
+
+// Run churns until stop is closed, and returns once the action in progress
+// has finished. Without actions, or a positive Interval, it only waits.
+func (c Churner) Run(stop <-chan struct{}) {
+	if len(c.Actions) == 0 || c.Interval <= 0 {
+		<-stop
+		return
+	}
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for i := 0; ; i++ {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		action := c.Actions[i%len(c.Actions)]
+		at := time.Now()
+		c.Recorder.RecordChurn(action.Name, at, action.Run())
+	}
+}
@@ -0,0 +1,110 @@
+package traffic_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/traffic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Driver", func() {
+	It("sends requests to every target until stopped", func() {
+		recorder := traffic.NewRecorder()
+		driver := traffic.Driver{
+			RequestsPerSecond: 100,
+			Recorder:          recorder,
+			Get: func(url string) (int, error) {
+				if url == "http://down.example.com" {
+					return 0, errors.New("connection refused")
+				}
+				return 200, nil
+			},
+		}
+
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			driver.Run([]traffic.Target{
+				{Name: "up", URL: "http://up.example.com"},
+				{Name: "down", URL: "http://down.example.com"},
+			}, stop)
+			close(done)
+		}()
+		time.Sleep(100 * time.Millisecond)
+		close(stop)
+		Eventually(done).Should(BeClosed())
+
+		report := recorder.Report(time.Now())
+		Expect(report.Targets["up"].Requests).To(BeNumerically(">", 3))
+		Expect(report.Targets["up"].Availability).To(Equal(100.0))
+		Expect(report.Targets["down"].Availability).To(Equal(0.0))
+		Expect(report.Targets["down"].LongestOutage.Ongoing).To(BeTrue())
+	})
+})
+
+var _ = Describe("Churner", func() {
+	It("runs its actions in turn until stopped", func() {
+		var (
+			lock sync.Mutex
+			runs []string
+		)
+		action := func(name string, err error) traffic.Action {
+			return traffic.Action{Name: name, Run: func() error {
+				lock.Lock()
+				defer lock.Unlock()
+				runs = append(runs, name)
+				return err
+			}}
+		}
+
+		recorder := traffic.NewRecorder()
+		churner := traffic.Churner{
+			Actions:  []traffic.Action{action("scale", nil), action("map", errors.New("failed"))},
+			Interval: 10 * time.Millisecond,
+			Recorder: recorder,
+		}
+
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			churner.Run(stop)
+			close(done)
+		}()
+		Eventually(func() int {
+			lock.Lock()
+			defer lock.Unlock()
+			return len(runs)
+		}).Should(BeNumerically(">=", 3))
+		close(stop)
+		Eventually(done).Should(BeClosed())
+
+		churn := recorder.Report(time.Now()).Churn
+		Expect(churn[0].Action).To(Equal("scale"))
+		Expect(churn[1].Action).To(Equal("map"))
+		Expect(churn[1].Error).To(Equal("failed"))
+		Expect(churn[2].Action).To(Equal("scale"))
+	})
+
+	It("only waits to be stopped without a positive interval", func() {
+		recorder := traffic.NewRecorder()
+		churner := traffic.Churner{
+			Actions:  []traffic.Action{{Name: "scale", Run: func() error { return nil }}},
+			Recorder: recorder,
+		}
+
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			churner.Run(stop)
+			close(done)
+		}()
+		Consistently(done, 50*time.Millisecond).ShouldNot(BeClosed())
+		close(stop)
+		Eventually(done).Should(BeClosed())
+		Expect(recorder.Report(time.Now()).Churn).To(BeEmpty())
+	})
+})
@@ -0,0 +1,220 @@
+// Package traffic sends steady traffic to routes over long periods, and
+// reports how available each route was and how long its outages lasted.
+package traffic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Failure is a request that got a non-2xx status, or no response at all.
+type Failure struct {
+	Target string    `json:"target"`
+	At     time.Time `json:"at"`
+	Status int       `json:"status,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// Outage is a run of failed requests to one target. It lasts from the first
+// failed request until the next successful one, or until the end of the run
+// if it is still Ongoing.
+type Outage struct {
+	Target   string        `json:"target"`
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"duration_ns"`
+	Failures int           `json:"failures"`
+	Ongoing  bool          `json:"ongoing"`
+}
+
+// ChurnEvent is a change made to the foundation during the run, recorded so
+// that outages can be matched with what caused them.
+type ChurnEvent struct {
+	Action string    `json:"action"`
+	At     time.Time `json:"at"`
+	Error  string    `json:"error,omitempty"`
+}
+
+type target struct {
+	requests int
+	failures int
+	outages  []Outage
+	open     *Outage
+}
+
+// Recorder keeps a count of the requests to each target, every failure and
+// every outage. Results for one target must be recorded in the order the
+// requests were sent.
+type Recorder struct {
+	lock      sync.Mutex
+	startedAt time.Time
+	targets   map[string]*target
+	failures  []Failure
+	churn     []ChurnEvent
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{startedAt: time.Now(), targets: map[string]*target{}}
+}
+
+// Record records the result of a request to name sent at at. Any 2xx status
+// is a success.
+func (r *Recorder) Record(name string, at time.Time, status int, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	t := r.target(name)
+	t.requests++
+	if err == nil && status >= 200 && status < 300 {
+		if t.open != nil {
+			t.open.End = at
+			t.open.Duration = at.Sub(t.open.Start)
+			t.outages = append(t.outages, *t.open)
+			t.open = nil
+		}
+		return
+	}
+
+	failure := Failure{Target: name, At: at, Status: status}
+	if err != nil {
+		failure.Error = err.Error()
+	}
+	r.failures = append(r.failures, failure)
+	t.failures++
+	if t.open == nil {
+		t.open = &Outage{Target: name, Start: at}
+	}
+	t.open.Failures++
+}
+
+// RecordChurn records a churn action done at at.
+func (r *Recorder) RecordChurn(action string, at time.Time, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	event := ChurnEvent{Action: action, At: at}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	r.churn = append(r.churn, event)
+}
+
+func (r *Recorder) target(name string) *target {
+	t, ok := r.targets[name]
+	if !ok {
+		t = &target{}
+		r.targets[name] = t
+	}
+	return t
+}
+
+type Summary struct {
+	Requests     int     `json:"requests"`
+	Failures     int     `json:"failures"`
+	Availability float64 `json:"availability_percent"`
+	Outages      int     `json:"outages"`
+	// LongestOutage is the zero Outage when there was none.
+	LongestOutage Outage `json:"longest_outage"`
+}
+
+type Report struct {
+	StartedAt time.Time          `json:"started_at"`
+	EndedAt   time.Time          `json:"ended_at"`
+	Targets   map[string]Summary `json:"targets"`
+	Outages   []Outage           `json:"outages"`
+	Failures  []Failure          `json:"failures"`
+	Churn     []ChurnEvent       `json:"churn"`
+}
+
+// Report summarizes what was recorded until end, which closes any outage
+// still in progress.
+func (r *Recorder) Report(end time.Time) Report {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	report := Report{
+		StartedAt: r.startedAt,
+		EndedAt:   end,
+		Targets:   map[string]Summary{},
+		Outages:   []Outage{},
+		Failures:  append([]Failure{}, r.failures...),
+		Churn:     append([]ChurnEvent{}, r.churn...),
+	}
+	for name, t := range r.targets {
+		outages := append([]Outage{}, t.outages...)
+		if t.open != nil {
+			open := *t.open
+			open.End = end
+			open.Duration = end.Sub(open.Start)
+			open.Ongoing = true
+			outages = append(outages, open)
+		}
+
+		summary := Summary{Requests: t.requests, Failures: t.failures, Outages: len(outages)}
+		if t.requests > 0 {
+			summary.Availability = 100 * float64(t.requests-t.failures) / float64(t.requests)
+		}
+		for _, outage := range outages {
+			if outage.Duration > summary.LongestOutage.Duration {
+				summary.LongestOutage = outage
+			}
+		}
+		report.Targets[name] = summary
+		report.Outages = append(report.Outages, outages...)
+	}
+	sort.Slice(report.Outages, func(i, j int) bool {
+		return report.Outages[i].Start.Before(report.Outages[j].Start)
+	})
+	return report
+}
+
+func (r Report) Write(path string) error {
+	encoded, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, encoded, 0644)
+}
+
+func (r Report) String() string {
+	names := []string{}
+	for name := range r.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	failedChurn := 0
+	for _, event := range r.Churn {
+		if event.Error != "" {
+			failedChurn++
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Availability over %s (%d churn actions, %d failed):\n",
+		r.EndedAt.Sub(r.StartedAt).Round(time.Second), len(r.Churn), failedChurn)
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "  TARGET\tREQUESTS\tFAILURES\tAVAILABILITY\tOUTAGES\tLONGEST OUTAGE")
+	for _, name := range names {
+		summary := r.Targets[name]
+		longest := "-"
+		if summary.Outages > 0 {
+			longest = fmt.Sprintf("%s at %s", summary.LongestOutage.Duration.Round(time.Millisecond), summary.LongestOutage.Start.Format(time.RFC3339))
+		}
+		fmt.Fprintf(w, "  %s\t%d\t%d\t%.3f%%\t%d\t%s\n",
+			name, summary.Requests, summary.Failures, summary.Availability, summary.Outages, longest)
+	}
+	w.Flush()
+	return b.String()
+}
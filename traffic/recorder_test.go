@@ -0,0 +1,116 @@
+package traffic_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/istio-acceptance-tests/traffic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Recorder", func() {
+	var (
+		recorder *traffic.Recorder
+		start    time.Time
+	)
+
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+
+	BeforeEach(func() {
+		recorder = traffic.NewRecorder()
+		start = time.Date(2019, 1, 30, 12, 0, 0, 0, time.UTC)
+	})
+
+	It("reports the availability and outages of each target", func() {
+		recorder.Record("app", at(0), 200, nil)
+		recorder.Record("app", at(1), 502, nil)
+		recorder.Record("app", at(2), 0, errors.New("connection refused"))
+		recorder.Record("app", at(3), 204, nil)
+		recorder.Record("app", at(4), 503, nil)
+		recorder.Record("app", at(5), 200, nil)
+		recorder.Record("other", at(0), 200, nil)
+
+		report := recorder.Report(at(6))
+
+		Expect(report.Targets["app"].Requests).To(Equal(6))
+		Expect(report.Targets["app"].Failures).To(Equal(3))
+		Expect(report.Targets["app"].Availability).To(Equal(50.0))
+		Expect(report.Targets["app"].Outages).To(Equal(2))
+		Expect(report.Targets["app"].LongestOutage).To(Equal(traffic.Outage{
+			Target:   "app",
+			Start:    at(1),
+			End:      at(3),
+			Duration: 2 * time.Second,
+			Failures: 2,
+		}))
+		Expect(report.Targets["other"]).To(Equal(traffic.Summary{Requests: 1, Availability: 100}))
+
+		Expect(report.Failures).To(Equal([]traffic.Failure{
+			{Target: "app", At: at(1), Status: 502},
+			{Target: "app", At: at(2), Error: "connection refused"},
+			{Target: "app", At: at(4), Status: 503},
+		}))
+		Expect(report.Outages).To(HaveLen(2))
+		Expect(report.Outages[1].Start).To(Equal(at(4)))
+	})
+
+	It("closes outages still in progress at the end of the report", func() {
+		recorder.Record("app", at(0), 200, nil)
+		recorder.Record("app", at(10), 404, nil)
+
+		report := recorder.Report(at(30))
+		Expect(report.Targets["app"].LongestOutage.Duration).To(Equal(20 * time.Second))
+		Expect(report.Targets["app"].LongestOutage.Ongoing).To(BeTrue())
+
+		recorder.Record("app", at(40), 200, nil)
+		Expect(recorder.Report(at(50)).Targets["app"].LongestOutage.Ongoing).To(BeFalse())
+	})
+
+	It("records churn", func() {
+		recorder.RecordChurn("scale app", at(1), nil)
+		recorder.RecordChurn("map route", at(2), errors.New("exited with 1"))
+
+		report := recorder.Report(at(3))
+		Expect(report.Churn).To(Equal([]traffic.ChurnEvent{
+			{Action: "scale app", At: at(1)},
+			{Action: "map route", At: at(2), Error: "exited with 1"},
+		}))
+		Expect(report.String()).To(ContainSubstring("2 churn actions, 1 failed"))
+	})
+
+	It("summarizes each target in a table", func() {
+		recorder.Record("external app", at(0), 200, nil)
+		recorder.Record("external app", at(1), 503, nil)
+		recorder.Record("external app", at(2), 200, nil)
+		recorder.Record("internal app", at(0), 200, nil)
+
+		out := recorder.Report(at(3)).String()
+		Expect(out).To(MatchRegexp(`external app\s+3\s+1\s+66.667%\s+1\s+1s at 2019-01-30T12:00:01Z`))
+		Expect(out).To(MatchRegexp(`internal app\s+1\s+0\s+100.000%\s+0\s+-`))
+	})
+
+	It("writes the report as JSON", func() {
+		dir, err := ioutil.TempDir("", "traffic")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		recorder.Record("app", at(0), 500, nil)
+		path := filepath.Join(dir, "reports", "soak.json")
+		Expect(recorder.Report(at(1)).Write(path)).To(Succeed())
+
+		contents, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		var report traffic.Report
+		Expect(json.Unmarshal(contents, &report)).To(Succeed())
+		Expect(report.Targets["app"].Failures).To(Equal(1))
+		Expect(report.Outages[0].Ongoing).To(BeTrue())
+	})
+})
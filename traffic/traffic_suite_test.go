@@ -0,0 +1,14 @@
+package traffic_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestTraffic(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Traffic Suite")
+}